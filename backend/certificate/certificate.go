@@ -0,0 +1,203 @@
+// Package certificate émet et vérifie les certificats de diagnostic signés
+// avec la clé Ed25519 du serveur. La vérification n'utilise que le fichier
+// du certificat et la clé publique : elle fonctionne hors ligne.
+package certificate
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+const (
+	// Version est la version du format de certificat
+	Version = 1
+	// Issuer identifie l'émetteur des certificats
+	Issuer = "diagnostic-backend"
+	// Algorithm est l'algorithme de signature
+	Algorithm = "Ed25519"
+)
+
+// LoadOrCreateKey lit la clé privée PEM (PKCS#8) ou la génère si le fichier
+// n'existe pas (created = true). Le fichier créé n'est lisible que par son propriétaire.
+func LoadOrCreateKey(path string) (key ed25519.PrivateKey, created bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err = generateKey(path)
+		return key, err == nil, err
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("lecture de la clé de signature: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, false, fmt.Errorf("clé de signature %s invalide (PEM PRIVATE KEY attendu)", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, false, fmt.Errorf("clé de signature %s invalide: %v", path, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, false, fmt.Errorf("clé de signature %s invalide (Ed25519 attendue)", path)
+	}
+	return key, false, nil
+}
+
+// generateKey crée une clé et l'écrit dans path
+func generateKey(path string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("création de la clé de signature: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return nil, fmt.Errorf("création de la clé de signature: %v", err)
+	}
+	return key, nil
+}
+
+// KeyID identifie une clé publique : 16 premiers caractères hexadécimaux de son SHA-256
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// EncodePublicKeyPEM retourne la clé publique au format PEM (PKIX)
+func EncodePublicKeyPEM(pub ed25519.PublicKey) []byte {
+	der, _ := x509.MarshalPKIXPublicKey(pub) // n'échoue pas pour une clé Ed25519
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// ParsePublicKeyPEM lit une clé publique Ed25519 au format PEM
+func ParsePublicKeyPEM(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("clé publique invalide (PEM PUBLIC KEY attendu)")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("clé publique invalide: %v", err)
+	}
+	pub, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("clé publique invalide (Ed25519 attendue)")
+	}
+	return pub, nil
+}
+
+// Issue produit le certificat signé d'un diagnostic
+func Issue(key ed25519.PrivateKey, d models.Diagnostic, issuedAt time.Time) (*models.Certificate, error) {
+	pub := key.Public().(ed25519.PublicKey)
+
+	payload := models.CertificatePayload{
+		Version:  Version,
+		Issuer:   Issuer,
+		KeyID:    KeyID(pub),
+		IssuedAt: issuedAt.UTC().Truncate(time.Second),
+		Diagnostic: models.CertifiedDiagnostic{
+			ID:         d.ID,
+			RunID:      d.RunID,
+			SystemInfo: d.SystemInfo,
+			CPU:        d.CPU,
+			RAM:        d.RAM,
+			Storage:    d.Storage,
+			Battery:    d.Battery,
+			Status:     d.Status,
+			Duration:   d.Duration,
+			Tests:      d.Tests,
+			Timestamp:  d.Timestamp.UTC(),
+			ChainHash:  d.Hash,
+		},
+	}
+
+	canonical, err := models.CanonicalJSON(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Certificate{
+		Payload:   payload,
+		Algorithm: Algorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, canonical)),
+	}, nil
+}
+
+// Verify vérifie un fichier de certificat et retourne son contenu signé.
+// Sans clé de confiance (trusted nil), la clé publique incluse dans le
+// certificat est utilisée : cela prouve l'intégrité du document, mais pas
+// son origine tant que son key_id n'est pas comparé à la clé publiée.
+func Verify(data []byte, trusted ed25519.PublicKey) (*models.CertificatePayload, error) {
+	var doc struct {
+		Payload   json.RawMessage `json:"payload"`
+		Algorithm string          `json:"algorithm"`
+		PublicKey string          `json:"public_key"`
+		Signature string          `json:"signature"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("certificat illisible: %v", err)
+	}
+	if doc.Algorithm != Algorithm {
+		return nil, fmt.Errorf("algorithme non supporté %q (attendu: %s)", doc.Algorithm, Algorithm)
+	}
+	if len(doc.Payload) == 0 {
+		return nil, errors.New("certificat sans payload")
+	}
+
+	embedded, err := base64.StdEncoding.DecodeString(doc.PublicKey)
+	if err != nil || len(embedded) != ed25519.PublicKeySize {
+		return nil, errors.New("clé publique du certificat invalide")
+	}
+	pub := ed25519.PublicKey(embedded)
+	if trusted != nil {
+		if !pub.Equal(trusted) {
+			return nil, fmt.Errorf("certificat émis par une autre clé (key_id %s, attendu %s)", KeyID(pub), KeyID(trusted))
+		}
+		pub = trusted
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(doc.Signature)
+	if err != nil {
+		return nil, errors.New("signature du certificat illisible")
+	}
+
+	// La signature porte sur la forme canonique : l'indentation du fichier est sans effet
+	canonical, err := models.CanonicalJSON(doc.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("payload illisible: %v", err)
+	}
+	if !ed25519.Verify(pub, canonical, signature) {
+		return nil, errors.New("signature invalide : le certificat a été modifié ou n'a pas été émis avec cette clé")
+	}
+
+	var payload models.CertificatePayload
+	if err := json.Unmarshal(doc.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("payload illisible: %v", err)
+	}
+	if payload.KeyID != KeyID(pub) {
+		return nil, fmt.Errorf("key_id %s ne correspond pas à la clé de signature", payload.KeyID)
+	}
+	return &payload, nil
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"diagnostic-backend/certificate"
+	"diagnostic-backend/config"
+	"diagnostic-backend/database"
+	"diagnostic-backend/handlers"
+	"diagnostic-backend/models"
+)
+
+// runCommand exécute une sous-commande CLI (ex: "migrate status").
+// Sans argument, main démarre le serveur HTTP.
+func runCommand(args []string, cfg *config.Config) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(args[1:], cfg.Database)
+	case "config":
+		return runConfig(args[1:], cfg)
+	case "keys":
+		return runKeys(args[1:], cfg.Database)
+	case "users":
+		return runUsers(args[1:], cfg.Database)
+	case "stations":
+		return runStations(args[1:], cfg.Database)
+	case "chain":
+		return runChain(args[1:], cfg.Database)
+	case "verify":
+		return runVerify(args[1:])
+	case "import":
+		return runImport(args[1:], cfg.Database)
+	case "help", "-h", "--help":
+		printUsage()
+		return nil
+	default:
+		printUsage()
+		return fmt.Errorf("commande inconnue: %s", args[0])
+	}
+}
+
+// printUsage affiche l'aide des sous-commandes
+func printUsage() {
+	fmt.Fprintln(os.Stderr, `Usage: diagnostic-backend [commande]
+
+Sans commande, démarre le serveur HTTP.
+
+Commandes:
+  migrate status   Affiche l'état des migrations du schéma
+  migrate up       Applique les migrations manquantes
+  config print     Affiche la configuration effective (fichier + environnement)
+  chain verify     Vérifie la chaîne de hachage des diagnostics
+
+  import csv <fichier.csv> <mapping.yaml> [--dry-run]
+                                             Importe des diagnostics historiques (mapping des
+                                             colonnes: voir import_mapping.example.yaml) ;
+                                             --dry-run valide les lignes sans rien enregistrer
+
+  verify <certificat.json> [clé.pem]         Vérifie un certificat hors ligne (sans base),
+                                             avec la clé publique publiée si fournie
+
+  keys list                                  Liste les clés d'API
+  keys create <nom> <portées> [utilisateur]  Crée une clé (portées: ingest,read,admin),
+                                             rattachée à un utilisateur (ID) si précisé
+  keys rotate <id>                           Remplace la valeur d'une clé
+  keys revoke <id>                           Révoque une clé
+
+  users list                                 Liste les utilisateurs
+  users create <nom> <rôle>                  Crée un utilisateur (technician, manager, admin)
+  users set-role <id> <rôle>                 Change le rôle d'un utilisateur
+  users disable <id>                         Désactive un utilisateur et ses clés
+
+  stations list                              Liste les stations de diagnostic
+  stations create <nom>                      Enregistre une station et affiche son secret HMAC
+  stations rotate <id>                       Remplace le secret d'une station
+  stations revoke <id>                       Refuse les envois signés par une station
+
+La configuration est lue dans CONFIG_FILE, ou config.yaml s'il existe,
+puis surchargée par les variables d'environnement (voir .env.example).`)
+}
+
+// runMigrate gère "migrate status" et "migrate up"
+func runMigrate(args []string, dbCfg config.DatabaseConfig) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: migrate status|up")
+	}
+
+	db, err := database.Open(dbCfg.Path, dbCfg.Pragmas)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		states, err := database.GetMigrationStatus(db)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNOM\tÉTAT\tAPPLIQUÉE LE")
+		for _, st := range states {
+			state, appliedAt := "en attente", "-"
+			if st.Applied {
+				state = "appliquée"
+				appliedAt = st.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", st.Version, st.Name, state, appliedAt)
+		}
+		return tw.Flush()
+
+	case "up":
+		applied, err := database.Migrate(db)
+		if err != nil {
+			return err
+		}
+		version, err := database.SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migration(s) appliquée(s), schéma en version %d\n", applied, version)
+		return nil
+
+	default:
+		return fmt.Errorf("sous-commande migrate inconnue: %s (attendu: status|up)", args[0])
+	}
+}
+
+// runConfig gère "config print"
+func runConfig(args []string, cfg *config.Config) error {
+	if len(args) != 1 || args[0] != "print" {
+		return fmt.Errorf("usage: config print")
+	}
+
+	data, err := cfg.YAML()
+	if err != nil {
+		return err
+	}
+
+	source := cfg.Source
+	if source == "" {
+		source = "aucun fichier, valeurs par défaut"
+	}
+	fmt.Printf("# Configuration effective (%s + variables d'environnement)\n", source)
+	os.Stdout.Write(data)
+	return nil
+}
+
+// runKeys gère "keys list|create|rotate|revoke"
+func runKeys(args []string, dbCfg config.DatabaseConfig) error {
+	usage := fmt.Errorf("usage: keys list | keys create <nom> <portées> [utilisateur] | keys rotate <id> | keys revoke <id>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	store, err := database.NewSQLiteStore(dbCfg.Path, dbCfg.Pragmas)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		keys, err := store.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNOM\tPRÉFIXE\tPORTÉES\tUTILISATEUR\tCRÉÉE LE\tDERNIÈRE UTILISATION\tÉTAT")
+		for _, k := range keys {
+			lastUsed, state, user := "-", "active", "-"
+			if k.User != nil {
+				user = k.User.Username + " (" + k.User.Role + ")"
+			}
+			if k.LastUsedAt != nil {
+				lastUsed = k.LastUsedAt.Local().Format("2006-01-02 15:04")
+			}
+			if k.RevokedAt != nil {
+				state = "révoquée le " + k.RevokedAt.Local().Format("2006-01-02")
+			}
+			fmt.Fprintf(tw, "%d\t%s\tdk_%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Prefix,
+				strings.Join(k.Scopes, ","), user, k.CreatedAt.Local().Format("2006-01-02 15:04"), lastUsed, state)
+		}
+		return tw.Flush()
+
+	case args[0] == "create" && (len(args) == 3 || len(args) == 4):
+		scopes, err := models.ParseScopes(strings.Split(args[2], ","))
+		if err != nil {
+			return err
+		}
+		var userID *int64
+		if len(args) == 4 {
+			id, err := strconv.ParseInt(args[3], 10, 64)
+			if err != nil {
+				return fmt.Errorf("identifiant d'utilisateur invalide: %s", args[3])
+			}
+			userID = &id
+		}
+		key, plain, err := store.CreateAPIKey(ctx, args[1], scopes, userID)
+		if errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("utilisateur %d inconnu", *userID)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Clé %d (%s) créée avec les portées %s.\n", key.ID, key.Name, strings.Join(key.Scopes, ","))
+		fmt.Println("Conservez-la, elle ne sera plus affichée :")
+		fmt.Println(plain)
+		return nil
+
+	case (args[0] == "rotate" || args[0] == "revoke") && len(args) == 2:
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("identifiant de clé invalide: %s", args[1])
+		}
+
+		if args[0] == "revoke" {
+			if err := store.RevokeAPIKey(ctx, id); err != nil {
+				return fmt.Errorf("révocation de la clé %d: %v", id, err)
+			}
+			fmt.Printf("Clé %d révoquée.\n", id)
+			return nil
+		}
+
+		key, plain, err := store.RotateAPIKey(ctx, id)
+		if err != nil {
+			return fmt.Errorf("renouvellement de la clé %d: %v", id, err)
+		}
+		fmt.Printf("Clé %d (%s) renouvelée, l'ancienne valeur est refusée dès maintenant.\n", key.ID, key.Name)
+		fmt.Println("Conservez-la, elle ne sera plus affichée :")
+		fmt.Println(plain)
+		return nil
+
+	default:
+		return usage
+	}
+}
+
+// runUsers gère "users list|create|set-role|disable"
+func runUsers(args []string, dbCfg config.DatabaseConfig) error {
+	usage := fmt.Errorf("usage: users list | users create <nom> <rôle> | users set-role <id> <rôle> | users disable <id>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	store, err := database.NewSQLiteStore(dbCfg.Path, dbCfg.Pragmas)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		users, err := store.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNOM\tRÔLE\tCRÉÉ LE\tÉTAT")
+		for _, u := range users {
+			state := "actif"
+			if u.DisabledAt != nil {
+				state = "désactivé le " + u.DisabledAt.Local().Format("2006-01-02")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", u.ID, u.Username, u.Role,
+				u.CreatedAt.Local().Format("2006-01-02 15:04"), state)
+		}
+		return tw.Flush()
+
+	case args[0] == "create" && len(args) == 3:
+		role, err := models.ParseRole(args[2])
+		if err != nil {
+			return err
+		}
+		user, err := store.CreateUser(ctx, args[1], "", role)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Utilisateur %d (%s) créé avec le rôle %s.\n", user.ID, user.Username, user.Role)
+		fmt.Printf("Créez-lui une clé avec: keys create <nom> <portées> %d\n", user.ID)
+		return nil
+
+	case args[0] == "set-role" && len(args) == 3:
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("identifiant d'utilisateur invalide: %s", args[1])
+		}
+		role, err := models.ParseRole(args[2])
+		if err != nil {
+			return err
+		}
+		user, err := store.SetUserRole(ctx, id, role)
+		if err != nil {
+			return fmt.Errorf("modification du rôle de l'utilisateur %d: %v", id, err)
+		}
+		fmt.Printf("Utilisateur %d (%s) : rôle %s.\n", user.ID, user.Username, user.Role)
+		return nil
+
+	case args[0] == "disable" && len(args) == 2:
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("identifiant d'utilisateur invalide: %s", args[1])
+		}
+		if err := store.DisableUser(ctx, id); err != nil {
+			return fmt.Errorf("désactivation de l'utilisateur %d: %v", id, err)
+		}
+		fmt.Printf("Utilisateur %d désactivé, ses clés sont refusées.\n", id)
+		return nil
+
+	default:
+		return usage
+	}
+}
+
+// runStations gère "stations list|create|rotate|revoke"
+func runStations(args []string, dbCfg config.DatabaseConfig) error {
+	usage := fmt.Errorf("usage: stations list | stations create <nom> | stations rotate <id> | stations revoke <id>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	store, err := database.NewSQLiteStore(dbCfg.Path, dbCfg.Pragmas)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		stations, err := store.ListStations(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNOM\tCRÉÉE LE\tDERNIER ENVOI\tÉTAT")
+		for _, st := range stations {
+			lastSeen, state := "-", "active"
+			if st.LastSeenAt != nil {
+				lastSeen = st.LastSeenAt.Local().Format("2006-01-02 15:04")
+			}
+			if st.RevokedAt != nil {
+				state = "révoquée le " + st.RevokedAt.Local().Format("2006-01-02")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", st.ID, st.Name,
+				st.CreatedAt.Local().Format("2006-01-02 15:04"), lastSeen, state)
+		}
+		return tw.Flush()
+
+	case args[0] == "create" && len(args) == 2:
+		name, err := models.ParseStationName(args[1])
+		if err != nil {
+			return err
+		}
+		station, secret, err := store.CreateStation(ctx, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Station %d (%s) enregistrée.\n", station.ID, station.Name)
+		fmt.Println("Secret HMAC à installer sur la station, il ne sera plus affiché :")
+		fmt.Println(secret)
+		return nil
+
+	case (args[0] == "rotate" || args[0] == "revoke") && len(args) == 2:
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("identifiant de station invalide: %s", args[1])
+		}
+
+		if args[0] == "revoke" {
+			if err := store.RevokeStation(ctx, id); err != nil {
+				return fmt.Errorf("révocation de la station %d: %v", id, err)
+			}
+			fmt.Printf("Station %d révoquée, ses envois signés sont refusés.\n", id)
+			return nil
+		}
+
+		station, secret, err := store.RotateStationSecret(ctx, id)
+		if err != nil {
+			return fmt.Errorf("renouvellement du secret de la station %d: %v", id, err)
+		}
+		fmt.Printf("Station %d (%s) : secret renouvelé, l'ancien est refusé dès maintenant.\n", station.ID, station.Name)
+		fmt.Println("Secret HMAC à installer sur la station, il ne sera plus affiché :")
+		fmt.Println(secret)
+		return nil
+
+	default:
+		return usage
+	}
+}
+
+// runChain gère "chain verify" ; retourne une erreur si la chaîne est rompue
+func runChain(args []string, dbCfg config.DatabaseConfig) error {
+	if len(args) != 1 || args[0] != "verify" {
+		return fmt.Errorf("usage: chain verify")
+	}
+
+	store, err := database.NewSQLiteStore(dbCfg.Path, dbCfg.Pragmas)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	report, err := store.VerifyChain(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d diagnostic(s) vérifié(s), %d supprimé(s) par l'API.\n", report.Checked, report.Tombstones)
+	if report.Head != nil {
+		fmt.Printf("Dernier maillon valide : diagnostic %d, %s\n", report.Head.ID, report.Head.Hash)
+	}
+	if b := report.Break; b != nil {
+		fmt.Printf("Chaîne rompue au diagnostic %d : %s\n", b.ID, b.Reason)
+		fmt.Printf("  attendu : %s\n  trouvé  : %s\n", b.Expected, b.Found)
+		return fmt.Errorf("chaîne de hachage rompue au diagnostic %d", b.ID)
+	}
+	fmt.Println("Chaîne intègre.")
+	return nil
+}
+
+// runImport gère "import csv <fichier.csv> <mapping.yaml> [--dry-run]"
+func runImport(args []string, dbCfg config.DatabaseConfig) error {
+	dryRun := len(args) == 4 && args[3] == "--dry-run"
+	if len(args) < 3 || args[0] != "csv" || (len(args) == 4 && !dryRun) || len(args) > 4 {
+		return fmt.Errorf("usage: import csv <fichier.csv> <mapping.yaml> [--dry-run]")
+	}
+
+	data, err := os.ReadFile(args[2])
+	if err != nil {
+		return err
+	}
+	mapping, err := handlers.ParseImportMapping(data)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	store, err := database.NewSQLiteStore(dbCfg.Path, dbCfg.Pragmas)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	report, err := handlers.ImportCSV(context.Background(), store, file, mapping, handlers.ImportOptions{DryRun: dryRun})
+	if err != nil && report.Total == 0 {
+		return err
+	}
+
+	for _, e := range report.Errors {
+		fmt.Printf("Ligne %d : %s\n", e.Line, e.Error)
+	}
+	if len(report.Errors) < report.Failed {
+		fmt.Printf("… et %d autre(s) ligne(s) refusée(s)\n", report.Failed-len(report.Errors))
+	}
+	if err != nil {
+		fmt.Printf("%d diagnostic(s) importé(s) avant l'erreur.\n", report.Imported)
+		return err
+	}
+
+	fmt.Printf("%d ligne(s) lue(s) : %d valide(s), %d refusée(s).\n", report.Total, report.Valid, report.Failed)
+	if dryRun {
+		fmt.Println("Simulation : rien n'a été enregistré.")
+	} else {
+		fmt.Printf("%d diagnostic(s) importé(s), %d déjà présent(s).\n", report.Imported, report.Duplicates)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d ligne(s) refusée(s)", report.Failed)
+	}
+	return nil
+}
+
+// runVerify gère "verify <certificat.json> [clé.pem]" : n'utilise ni la base
+// ni la clé privée, seulement le fichier et éventuellement la clé publique
+func runVerify(args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return fmt.Errorf("usage: verify <certificat.json> [clé_publique.pem]")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	var trusted ed25519.PublicKey
+	if len(args) == 2 {
+		pemData, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		if trusted, err = certificate.ParsePublicKeyPEM(pemData); err != nil {
+			return err
+		}
+	}
+
+	payload, err := certificate.Verify(data, trusted)
+	if err != nil {
+		return fmt.Errorf("certificat invalide: %v", err)
+	}
+
+	d := payload.Diagnostic
+	passed := 0
+	for _, t := range d.Tests {
+		if t.Outcome == models.TestOutcomePassed {
+			passed++
+		}
+	}
+
+	fmt.Println("Certificat valide.")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Diagnostic\t%d (%s)\n", d.ID, d.Status)
+	fmt.Fprintf(tw, "Machine\t%s, n° de série %s\n", d.SystemInfo.Model, d.SystemInfo.SerialNumber)
+	fmt.Fprintf(tw, "Date du test\t%s\n", d.Timestamp.Local().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(tw, "Étapes réussies\t%d/%d\n", passed, len(d.Tests))
+	fmt.Fprintf(tw, "Émis le\t%s par %s\n", payload.IssuedAt.Local().Format("2006-01-02 15:04:05"), payload.Issuer)
+	fmt.Fprintf(tw, "Clé\t%s\n", payload.KeyID)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if trusted == nil {
+		fmt.Println("Attention : vérifié avec la clé incluse dans le certificat. Comparez son key_id")
+		fmt.Println("à celui de /api/v1/certificates/public-key, ou fournissez la clé publique.")
+	}
+	return nil
+}
@@ -0,0 +1,491 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath est le fichier lu au démarrage s'il existe et si CONFIG_FILE n'est pas défini
+const DefaultPath = "config.yaml"
+
+// Config regroupe la configuration du backend.
+// Ordre de priorité: valeurs par défaut < fichier YAML < variables d'environnement.
+type Config struct {
+	Server       ServerConfig       `yaml:"server"`
+	TLS          TLSConfig          `yaml:"tls"`
+	CORS         CORSConfig         `yaml:"cors"`
+	Auth         AuthConfig         `yaml:"auth"`
+	Limits       LimitsConfig       `yaml:"limits"`
+	Signing      SigningConfig      `yaml:"signing"`
+	Certificates CertificatesConfig `yaml:"certificates"`
+	Database     DatabaseConfig     `yaml:"database"`
+	Retention    RetentionConfig    `yaml:"retention"`
+	Log          LogConfig          `yaml:"log"`
+
+	// Source est le fichier chargé ("" si aucun)
+	Source string `yaml:"-"`
+}
+
+// ServerConfig règle le serveur HTTP
+type ServerConfig struct {
+	Listen          string   `yaml:"listen"` // adresse d'écoute, ex: ":8080" ou "127.0.0.1:8080"
+	ReadTimeout     Duration `yaml:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout"`
+	IdleTimeout     Duration `yaml:"idle_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout"` // délai de grâce à l'arrêt
+	MinFreeDiskMB   uint64   `yaml:"min_free_disk_mb"` // espace libre minimal pour /readyz
+}
+
+// TLSConfig active HTTPS quand le certificat et la clé sont fournis
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ClientCAFile est la CA interne qui signe les certificats des stations (mTLS)
+	ClientCAFile string `yaml:"client_ca_file"`
+	// ClientAuth : "none", "optional" (certificat vérifié s'il est présenté) ou
+	// "require" (certificat exigé pour l'envoi de diagnostics ; les sondes,
+	// /metrics et la consultation restent accessibles sans certificat)
+	ClientAuth string `yaml:"client_auth"`
+	// ReloadInterval est la fréquence de vérification des fichiers : un
+	// certificat renouvelé sur disque est pris en compte sans redémarrage
+	ReloadInterval Duration `yaml:"reload_interval"`
+}
+
+// Enabled indique si le serveur doit écouter en HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// clientAuthModes liste les valeurs acceptées pour tls.client_auth
+var clientAuthModes = []string{"none", "optional", "require"}
+
+// CORSConfig règle les origines autorisées à appeler l'API depuis un navigateur
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           Duration `yaml:"max_age"`
+}
+
+// AuthConfig règle l'authentification des clients
+type AuthConfig struct {
+	// Required refuse les requêtes sans clé d'API (Authorization: Bearer).
+	// Désactivé par défaut pour ne pas couper les stations existantes.
+	Required bool `yaml:"required"`
+}
+
+// LimitsConfig protège l'envoi de diagnostics contre les requêtes trop
+// volumineuses et les clients qui bouclent
+type LimitsConfig struct {
+	MaxBodyMB      int `yaml:"max_body_mb"`       // POST /diagnostics
+	MaxBatchBodyMB int `yaml:"max_batch_body_mb"` // POST /diagnostics/batch
+	// RatePerSecond est le débit soutenu autorisé par adresse IP, et en plus
+	// par clé d'API ; Burst est le nombre de requêtes acceptées d'affilée.
+	// 0 désactive la limitation de débit.
+	RatePerSecond float64 `yaml:"rate_per_second"`
+	Burst         int     `yaml:"burst"`
+}
+
+// SigningConfig règle la vérification des envois signés par les stations (HMAC)
+type SigningConfig struct {
+	// Required refuse les diagnostics non signés par une station enregistrée
+	Required bool `yaml:"required"`
+	// MaxSkew est l'écart toléré entre l'horodatage signé et l'heure du serveur
+	MaxSkew Duration `yaml:"max_skew"`
+}
+
+// CertificatesConfig règle la signature des certificats de diagnostic (Ed25519)
+type CertificatesConfig struct {
+	// KeyFile est la clé privée PEM, générée au premier démarrage si absente
+	KeyFile string `yaml:"key_file"`
+}
+
+// DatabaseConfig règle la base SQLite
+type DatabaseConfig struct {
+	Path string `yaml:"path"`
+	// Pragmas appliqués à chaque connexion (ex: journal_mode: WAL)
+	Pragmas map[string]string `yaml:"pragmas"`
+}
+
+// RetentionConfig règle la purge périodique des anciennes données (0 = conserver)
+type RetentionConfig struct {
+	Diagnostics     Duration `yaml:"diagnostics"` // compté depuis la réception par le serveur
+	IdempotencyKeys Duration `yaml:"idempotency_keys"`
+	Interval        Duration `yaml:"interval"` // fréquence de la purge
+}
+
+// Enabled indique si une purge est configurée
+func (r RetentionConfig) Enabled() bool {
+	return r.Diagnostics > 0 || r.IdempotencyKeys > 0
+}
+
+// LogConfig règle les logs structurés
+type LogConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn, error
+	Format string `yaml:"format"` // json, text
+}
+
+// SupportedPragmas liste les pragmas SQLite configurables
+var SupportedPragmas = []string{
+	"auto_vacuum", "busy_timeout", "cache_size", "foreign_keys",
+	"journal_mode", "locking_mode", "secure_delete", "synchronous",
+}
+
+// Default retourne la configuration par défaut
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Listen:          ":8080",
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(60 * time.Second), // un lot de 1000 diagnostics peut être long à enregistrer
+			IdleTimeout:     Duration(120 * time.Second),
+			ShutdownTimeout: Duration(20 * time.Second),
+			MinFreeDiskMB:   100,
+		},
+		TLS: TLSConfig{
+			ClientAuth:     "none",
+			ReloadInterval: Duration(time.Minute),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"}, // en production, spécifier les origines exactes
+			MaxAge:         Duration(5 * time.Minute),
+		},
+		Limits: LimitsConfig{
+			MaxBodyMB:      10,
+			MaxBatchBodyMB: 100, // jusqu'à MaxBatchSize diagnostics
+			RatePerSecond:  5,
+			Burst:          50,
+		},
+		Signing: SigningConfig{
+			MaxSkew: Duration(5 * time.Minute),
+		},
+		Certificates: CertificatesConfig{
+			KeyFile: "./certificate_key.pem",
+		},
+		Database: DatabaseConfig{
+			Path: "./diagnostics.db",
+			Pragmas: map[string]string{
+				"journal_mode": "WAL", // les lectures ne bloquent plus les écritures
+				"busy_timeout": "5000",
+			},
+		},
+		Retention: RetentionConfig{
+			Interval: Duration(time.Hour),
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+}
+
+// Load lit le fichier de configuration (CONFIG_FILE, sinon config.yaml s'il
+// existe), applique les variables d'environnement puis valide le résultat
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		if _, err := os.Stat(DefaultPath); err == nil {
+			path = DefaultPath
+		}
+	}
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile fusionne le fichier YAML dans la configuration (les clés absentes
+// gardent leur valeur par défaut, les clés inconnues sont refusées)
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("lecture de la configuration: %v", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("configuration %s invalide: %v", path, err)
+	}
+
+	c.Source = path
+	return nil
+}
+
+// envOverrides associe chaque variable d'environnement au champ qu'elle remplace
+func (c *Config) envOverrides() map[string]func(string) error {
+	return map[string]func(string) error{
+		"LISTEN_ADDR": setString(&c.Server.Listen),
+		"PORT": func(v string) error {
+			c.Server.Listen = ":" + v
+			return nil
+		},
+		"HTTP_READ_TIMEOUT":          setDuration(&c.Server.ReadTimeout),
+		"HTTP_WRITE_TIMEOUT":         setDuration(&c.Server.WriteTimeout),
+		"HTTP_IDLE_TIMEOUT":          setDuration(&c.Server.IdleTimeout),
+		"SHUTDOWN_TIMEOUT":           setDuration(&c.Server.ShutdownTimeout),
+		"MIN_FREE_DISK_MB":           setUint(&c.Server.MinFreeDiskMB),
+		"TLS_CERT_FILE":              setString(&c.TLS.CertFile),
+		"TLS_KEY_FILE":               setString(&c.TLS.KeyFile),
+		"TLS_CLIENT_CA_FILE":         setString(&c.TLS.ClientCAFile),
+		"TLS_CLIENT_AUTH":            setString(&c.TLS.ClientAuth),
+		"TLS_RELOAD_INTERVAL":        setDuration(&c.TLS.ReloadInterval),
+		"CORS_ALLOWED_ORIGINS":       setList(&c.CORS.AllowedOrigins),
+		"AUTH_REQUIRED":              setBool(&c.Auth.Required),
+		"MAX_BODY_MB":                setInt(&c.Limits.MaxBodyMB),
+		"MAX_BATCH_BODY_MB":          setInt(&c.Limits.MaxBatchBodyMB),
+		"RATE_LIMIT_PER_SECOND":      setFloat(&c.Limits.RatePerSecond),
+		"RATE_LIMIT_BURST":           setInt(&c.Limits.Burst),
+		"SIGNING_REQUIRED":           setBool(&c.Signing.Required),
+		"SIGNING_MAX_SKEW":           setDuration(&c.Signing.MaxSkew),
+		"CERTIFICATE_KEY_FILE":       setString(&c.Certificates.KeyFile),
+		"DB_PATH":                    setString(&c.Database.Path),
+		"RETENTION_DIAGNOSTICS":      setDuration(&c.Retention.Diagnostics),
+		"RETENTION_IDEMPOTENCY_KEYS": setDuration(&c.Retention.IdempotencyKeys),
+		"LOG_LEVEL":                  setString(&c.Log.Level),
+		"LOG_FORMAT":                 setString(&c.Log.Format),
+	}
+}
+
+// applyEnv remplace les valeurs par celles des variables d'environnement définies.
+// LISTEN_ADDR est prioritaire sur PORT.
+func (c *Config) applyEnv() error {
+	overrides := c.envOverrides()
+	names := []string{"PORT", "LISTEN_ADDR"}
+	for name := range overrides {
+		if name != "PORT" && name != "LISTEN_ADDR" {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := overrides[name](value); err != nil {
+			return fmt.Errorf("variable %s invalide (%q): %v", name, value, err)
+		}
+	}
+	return nil
+}
+
+// Validate vérifie la cohérence de la configuration et retourne toutes les erreurs d'un coup
+func (c *Config) Validate() error {
+	var errs []string
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.Listen); err != nil {
+		fail("server.listen", "adresse invalide %q (attendu: \":8080\" ou \"hôte:port\")", c.Server.Listen)
+	}
+	for field, d := range map[string]Duration{
+		"server.read_timeout":     c.Server.ReadTimeout,
+		"server.write_timeout":    c.Server.WriteTimeout,
+		"server.idle_timeout":     c.Server.IdleTimeout,
+		"server.shutdown_timeout": c.Server.ShutdownTimeout,
+	} {
+		if d <= 0 {
+			fail(field, "doit être une durée positive (ex: 30s)")
+		}
+	}
+
+	if c.TLS.Enabled() {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			fail("tls", "cert_file et key_file doivent être fournis ensemble")
+		}
+		for field, file := range map[string]string{"tls.cert_file": c.TLS.CertFile, "tls.key_file": c.TLS.KeyFile, "tls.client_ca_file": c.TLS.ClientCAFile} {
+			if file == "" {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				fail(field, "fichier illisible: %v", err)
+			}
+		}
+	}
+
+	if !slices.Contains(clientAuthModes, c.TLS.ClientAuth) {
+		fail("tls.client_auth", "valeur inconnue %q (attendu: %s)", c.TLS.ClientAuth, strings.Join(clientAuthModes, ", "))
+	} else if c.TLS.ClientAuth != "none" {
+		if !c.TLS.Enabled() {
+			fail("tls.client_auth", "nécessite HTTPS (cert_file et key_file)")
+		}
+		if c.TLS.ClientCAFile == "" {
+			fail("tls.client_ca_file", "requis quand client_auth vaut %q", c.TLS.ClientAuth)
+		}
+	}
+	if c.TLS.ClientCAFile != "" && !c.TLS.Enabled() {
+		fail("tls.client_ca_file", "nécessite HTTPS (cert_file et key_file)")
+	}
+	if c.TLS.ReloadInterval <= 0 {
+		fail("tls.reload_interval", "doit être une durée positive (ex: 1m)")
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" && c.CORS.AllowCredentials {
+			fail("cors.allow_credentials", "incompatible avec l'origine \"*\"")
+		}
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			fail("cors.allowed_origins", "origine invalide %q (attendu: https://hôte[:port] ou *)", origin)
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		fail("cors.max_age", "ne peut pas être négatif")
+	}
+
+	if c.Limits.MaxBodyMB <= 0 {
+		fail("limits.max_body_mb", "doit être positif")
+	}
+	if c.Limits.MaxBatchBodyMB <= 0 {
+		fail("limits.max_batch_body_mb", "doit être positif")
+	}
+	if c.Limits.RatePerSecond < 0 {
+		fail("limits.rate_per_second", "ne peut pas être négatif (0 = pas de limite)")
+	}
+	if c.Limits.RatePerSecond > 0 && c.Limits.Burst < 1 {
+		fail("limits.burst", "doit être au moins 1 quand rate_per_second est défini")
+	}
+
+	if c.Signing.MaxSkew <= 0 {
+		fail("signing.max_skew", "doit être une durée positive (ex: 5m)")
+	}
+
+	if c.Certificates.KeyFile == "" {
+		fail("certificates.key_file", "est requis")
+	}
+
+	if c.Database.Path == "" {
+		fail("database.path", "est requis")
+	}
+	for name, value := range c.Database.Pragmas {
+		if !slices.Contains(SupportedPragmas, name) {
+			fail("database.pragmas", "pragma %q non supporté (valeurs possibles: %s)", name, strings.Join(SupportedPragmas, ", "))
+		}
+		if value == "" || strings.ContainsAny(value, "&=?#") {
+			fail("database.pragmas."+name, "valeur invalide %q", value)
+		}
+	}
+
+	if c.Retention.Diagnostics < 0 || c.Retention.IdempotencyKeys < 0 {
+		fail("retention", "les durées ne peuvent pas être négatives (0 = conserver)")
+	}
+	if c.Retention.Enabled() && c.Retention.Interval <= 0 {
+		fail("retention.interval", "doit être une durée positive (ex: 1h)")
+	}
+
+	if !slices.Contains([]string{"debug", "info", "warn", "warning", "error"}, strings.ToLower(c.Log.Level)) {
+		fail("log.level", "niveau inconnu %q (debug, info, warn, error)", c.Log.Level)
+	}
+	if !slices.Contains([]string{"json", "text"}, strings.ToLower(c.Log.Format)) {
+		fail("log.format", "format inconnu %q (json, text)", c.Log.Format)
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("configuration invalide:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+	return nil
+}
+
+// YAML retourne la configuration effective au format YAML
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+func setString(dst *string) func(string) error {
+	return func(v string) error {
+		*dst = v
+		return nil
+	}
+}
+
+func setList(dst *[]string) func(string) error {
+	return func(v string) error {
+		*dst = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*dst = append(*dst, item)
+			}
+		}
+		return nil
+	}
+}
+
+func setBool(dst *bool) func(string) error {
+	return func(v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return errors.New("booléen attendu (true, false)")
+		}
+		*dst = b
+		return nil
+	}
+}
+
+func setUint(dst *uint64) func(string) error {
+	return func(v string) error {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return errors.New("entier positif attendu")
+		}
+		*dst = n
+		return nil
+	}
+}
+
+func setInt(dst *int) func(string) error {
+	return func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.New("entier attendu")
+		}
+		*dst = n
+		return nil
+	}
+}
+
+func setFloat(dst *float64) func(string) error {
+	return func(v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return errors.New("nombre attendu (ex: 2.5)")
+		}
+		*dst = f
+		return nil
+	}
+}
+
+func setDuration(dst *Duration) func(string) error {
+	return func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return errors.New("durée attendue (ex: 30s, 5m, 720h)")
+		}
+		*dst = Duration(d)
+		return nil
+	}
+}
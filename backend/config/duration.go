@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration est une durée écrite sous forme lisible dans le YAML ("30s", "720h")
+type Duration time.Duration
+
+// D retourne la valeur en time.Duration
+func (d Duration) D() time.Duration {
+	return time.Duration(d)
+}
+
+// String retourne la forme acceptée par time.ParseDuration
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// UnmarshalYAML accepte "30s", "5m", ... (0 pour désactiver)
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	if s == "0" {
+		*d = 0
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("ligne %d: durée invalide %q (ex: 30s, 5m, 720h)", node.Line, s)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalYAML écrit la durée sous sa forme lisible
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"diagnostic-backend/logging"
+	"diagnostic-backend/metrics"
+	"diagnostic-backend/models"
+)
+
+// ErrInvalidAPIKey est retournée pour une clé inconnue, mal formée ou révoquée
+// (ou dont l'utilisateur est désactivé)
+var ErrInvalidAPIKey = errors.New("clé d'API invalide ou révoquée")
+
+const (
+	// apiKeyPrefix permet de reconnaître une clé (ex: dans un dépôt de code)
+	apiKeyPrefix = "dk_"
+	// lastUsedResolution évite une écriture en base à chaque requête authentifiée
+	lastUsedResolution = time.Minute
+)
+
+// generateAPIKey retourne une clé "dk_<préfixe>_<secret>" et son préfixe public
+func generateAPIKey() (key, prefix string, err error) {
+	var id [4]byte
+	var secret [24]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret[:]); err != nil {
+		return "", "", err
+	}
+
+	prefix = hex.EncodeToString(id[:])
+	return apiKeyPrefix + prefix + "_" + hex.EncodeToString(secret[:]), prefix, nil
+}
+
+// hashAPIKey retourne l'empreinte stockée en base (la clé est aléatoire,
+// un hachage lent n'apporterait rien)
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyColumns liste les colonnes lues par scanAPIKey, dans l'ordre
+// (k = api_keys, u = utilisateur rattaché en LEFT JOIN)
+const apiKeyColumns = `
+		k.id, k.name, k.prefix, k.scopes, k.created_at, k.rotated_at, k.last_used_at, k.revoked_at,
+		u.id, u.username, u.display_name, u.role, u.created_at, u.disabled_at`
+
+// apiKeyFrom est la clause FROM associée à apiKeyColumns
+const apiKeyFrom = `
+	FROM api_keys k
+	LEFT JOIN users u ON u.id = k.user_id`
+
+// scanAPIKey lit une ligne sélectionnée avec apiKeyColumns
+func scanAPIKey(row rowScanner, extra ...interface{}) (*models.APIKey, error) {
+	var key models.APIKey
+	var scopes string
+	var rotatedAt, lastUsedAt, revokedAt sql.NullTime
+	var userID sql.NullInt64
+	var username, displayName, role sql.NullString
+	var userCreatedAt, userDisabledAt sql.NullTime
+
+	dest := append([]interface{}{
+		&key.ID, &key.Name, &key.Prefix, &scopes, &key.CreatedAt, &rotatedAt, &lastUsedAt, &revokedAt,
+		&userID, &username, &displayName, &role, &userCreatedAt, &userDisabledAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	key.Scopes = strings.Split(scopes, ",")
+	if rotatedAt.Valid {
+		key.RotatedAt = &rotatedAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	if userID.Valid {
+		key.User = &models.User{
+			ID:          userID.Int64,
+			Username:    username.String,
+			DisplayName: displayName.String,
+			Role:        role.String,
+			CreatedAt:   userCreatedAt.Time,
+		}
+		if userDisabledAt.Valid {
+			key.User.DisabledAt = &userDisabledAt.Time
+		}
+	}
+	return &key, nil
+}
+
+// CreateAPIKey crée une clé, éventuellement rattachée à un utilisateur,
+// et retourne sa valeur en clair (non récupérable ensuite)
+func (s *SQLiteStore) CreateAPIKey(ctx context.Context, name string, scopes []string, userID *int64) (*models.APIKey, string, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "create_api_key")
+
+	if userID != nil {
+		if _, err := s.GetUser(ctx, *userID); err != nil {
+			return nil, "", err
+		}
+	}
+
+	plain, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		"INSERT INTO api_keys (name, prefix, key_hash, scopes, created_at, user_id) VALUES (?, ?, ?, ?, ?, ?)",
+		name, prefix, hashAPIKey(plain), strings.Join(scopes, ","), time.Now().UTC(), userID,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := s.getAPIKey(ctx, id)
+	return key, plain, err
+}
+
+// ListAPIKeys retourne toutes les clés, révoquées comprises
+func (s *SQLiteStore) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "list_api_keys")
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+apiKeyFrom+" ORDER BY k.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// RotateAPIKey remplace la valeur d'une clé active ; l'ancienne cesse immédiatement de fonctionner
+func (s *SQLiteStore) RotateAPIKey(ctx context.Context, id int64) (*models.APIKey, string, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "rotate_api_key")
+
+	plain, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE api_keys SET prefix = ?, key_hash = ?, rotated_at = ? WHERE id = ? AND revoked_at IS NULL",
+		prefix, hashAPIKey(plain), time.Now().UTC(), id,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, "", ErrNotFound
+	}
+
+	key, err := s.getAPIKey(ctx, id)
+	return key, plain, err
+}
+
+// RevokeAPIKey désactive définitivement une clé (la ligne est conservée pour l'historique)
+func (s *SQLiteStore) RevokeAPIKey(ctx context.Context, id int64) error {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "revoke_api_key")
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey retrouve la clé active correspondant à la valeur fournie
+// et met à jour, si possible, sa date de dernière utilisation
+func (s *SQLiteStore) AuthenticateAPIKey(ctx context.Context, plain string) (*models.APIKey, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "authenticate_api_key")
+
+	rest, ok := strings.CutPrefix(plain, apiKeyPrefix)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	prefix, _, ok := strings.Cut(rest, "_")
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var hash string
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+apiKeyColumns+", k.key_hash"+apiKeyFrom+" WHERE k.prefix = ?", prefix)
+	key, err := scanAPIKey(row, &hash)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashAPIKey(plain))) != 1 || key.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	// Un utilisateur désactivé perd l'usage de toutes ses clés
+	if key.User != nil && key.User.DisabledAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Mise à jour indicative : un échec (base verrouillée, ...) ne doit pas
+	// refuser une clé valide
+	now := time.Now().UTC()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedResolution {
+		if _, err := s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = ? WHERE id = ?", now, key.ID); err != nil {
+			logging.FromContext(ctx).Warn("Mise à jour de last_used_at impossible",
+				"api_key", key.Prefix, "error", err)
+		} else {
+			key.LastUsedAt = &now
+		}
+	}
+
+	return key, nil
+}
+
+// getAPIKey retourne ErrNotFound si l'ID n'existe pas
+func (s *SQLiteStore) getAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+apiKeyFrom+" WHERE k.id = ?", id)
+	key, err := scanAPIKey(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
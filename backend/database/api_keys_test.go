@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"diagnostic-backend/models"
+)
+
+// TestAuthenticateAPIKeyLastUsedFailure vérifie qu'un échec de la mise à
+// jour de last_used_at ne refuse pas une clé valide
+func TestAuthenticateAPIKeyLastUsedFailure(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	key, plain, err := store.CreateAPIKey(ctx, "station-1", []string{models.ScopeIngest}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, store, `CREATE TRIGGER refuse_last_used BEFORE UPDATE OF last_used_at ON api_keys
+		BEGIN SELECT RAISE(ABORT, 'base verrouillée'); END`)
+
+	got, err := store.AuthenticateAPIKey(ctx, plain)
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey: %v", err)
+	}
+	if got.ID != key.ID {
+		t.Errorf("clé %d, attendu %d", got.ID, key.ID)
+	}
+	if got.LastUsedAt != nil {
+		t.Errorf("last_used_at = %s, attendu nul (mise à jour refusée)", got.LastUsedAt)
+	}
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"database/sql"
+
+	"diagnostic-backend/models"
+)
+
+// backfillNumericCapacities calcule les colonnes numériques (migration 0002)
+// à partir des chaînes déjà stockées ("16.00 GB", "85%", ...)
+func backfillNumericCapacities(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+	SELECT id, ram_total, ram_used, ram_available,
+		storage_capacity, storage_used, storage_available,
+		battery_capacity, battery_max_capacity
+	FROM diagnostics`)
+	if err != nil {
+		return err
+	}
+
+	var requests []models.DiagnosticRequest
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+		var d models.DiagnosticRequest
+		var batteryMaxCapacity sql.NullString
+		if err := rows.Scan(&id, &d.RAM.Total, &d.RAM.Used, &d.RAM.Available,
+			&d.Storage.Capacity, &d.Storage.Used, &d.Storage.Available,
+			&d.Battery.Capacity, &batteryMaxCapacity); err != nil {
+			rows.Close()
+			return err
+		}
+		d.Battery.MaxCapacity = batteryMaxCapacity.String
+		d.FillNumericValues()
+
+		ids = append(ids, id)
+		requests = append(requests, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`
+	UPDATE diagnostics SET
+		ram_total_bytes = ?, ram_used_bytes = ?, ram_available_bytes = ?,
+		storage_capacity_bytes = ?, storage_used_bytes = ?, storage_available_bytes = ?,
+		battery_capacity_percent = ?, battery_max_capacity_percent = ?
+	WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, d := range requests {
+		if _, err := stmt.Exec(
+			d.RAM.TotalBytes, d.RAM.UsedBytes, d.RAM.AvailableBytes,
+			d.Storage.CapacityBytes, d.Storage.UsedBytes, d.Storage.AvailableBytes,
+			d.Battery.CapacityPercent, d.Battery.MaxCapacityPercent,
+			ids[i],
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
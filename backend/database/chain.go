@@ -0,0 +1,443 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"diagnostic-backend/metrics"
+	"diagnostic-backend/models"
+)
+
+// genesisHash est le prev_hash du premier diagnostic de la chaîne
+var genesisHash = strings.Repeat("0", 64)
+
+// Motifs enregistrés dans diagnostic_tombstones
+const (
+	tombstoneDeleted = "deleted" // suppression par un administrateur
+	tombstonePurged  = "purged"  // purge de rétention
+)
+
+// chainPageSize est le nombre de maillons vérifiés par requête
+const chainPageSize = 500
+
+// chainVersion est le premier octet haché : il change avec le format de
+// chainPayload, qui ne doit jamais être modifié sans l'incrémenter
+const chainVersion byte = 1
+
+// chainPayload est le contenu haché d'un diagnostic. Ses champs sont figés
+// et indépendants du modèle de l'API : un champ ajouté à models.Diagnostic
+// ne change pas le hash des lignes existantes.
+type chainPayload struct {
+	ID    int64  `json:"id"`
+	RunID string `json:"run_id"`
+
+	MachineName  string `json:"machine_name"`
+	SerialNumber string `json:"serial_number"`
+	Model        string `json:"model"`
+	OSVersion    string `json:"os_version"`
+	MacOSVersion string `json:"macos_version"`
+
+	CPUModel       string `json:"cpu_model"`
+	CPUCores       int    `json:"cpu_cores"`
+	CPUFrequency   string `json:"cpu_frequency"`
+	CPUTemperature string `json:"cpu_temperature"`
+
+	RAMTotal          string `json:"ram_total"`
+	RAMUsed           string `json:"ram_used"`
+	RAMAvailable      string `json:"ram_available"`
+	RAMType           string `json:"ram_type"`
+	RAMTotalBytes     *int64 `json:"ram_total_bytes"`
+	RAMUsedBytes      *int64 `json:"ram_used_bytes"`
+	RAMAvailableBytes *int64 `json:"ram_available_bytes"`
+
+	StorageType           string `json:"storage_type"`
+	StorageCapacity       string `json:"storage_capacity"`
+	StorageUsed           string `json:"storage_used"`
+	StorageAvailable      string `json:"storage_available"`
+	StorageHealth         string `json:"storage_health"`
+	StorageDeviceName     string `json:"storage_device_name"`
+	StorageCapacityBytes  *int64 `json:"storage_capacity_bytes"`
+	StorageUsedBytes      *int64 `json:"storage_used_bytes"`
+	StorageAvailableBytes *int64 `json:"storage_available_bytes"`
+
+	BatteryCycleCount         int      `json:"battery_cycle_count"`
+	BatteryHealth             string   `json:"battery_health"`
+	BatteryCapacity           string   `json:"battery_capacity"`
+	BatteryMaxCapacity        string   `json:"battery_max_capacity"`
+	BatteryCondition          string   `json:"battery_condition"`
+	BatteryIsCharging         bool     `json:"battery_is_charging"`
+	BatteryPowerAdapter       string   `json:"battery_power_adapter"`
+	BatteryCapacityPercent    *float64 `json:"battery_capacity_percent"`
+	BatteryMaxCapacityPercent *float64 `json:"battery_max_capacity_percent"`
+
+	Status   string      `json:"status"`
+	Duration float64     `json:"duration"`
+	Tests    []chainTest `json:"tests"`
+
+	// Horodatages en RFC 3339 UTC
+	Timestamp        string   `json:"timestamp"`
+	CreatedAt        string   `json:"created_at"`
+	CapturedAt       *string  `json:"captured_at"`
+	ReceivedAt       string   `json:"received_at"`
+	ClockSkewSeconds *float64 `json:"clock_skew_seconds"`
+	TimestampFlag    string   `json:"timestamp_flag"`
+
+	SubmittedBy *int64  `json:"submitted_by"`
+	StationID   *int64  `json:"station_id"`
+	Signature   string  `json:"signature"`
+	SignedAt    *string `json:"signed_at"`
+}
+
+// chainTest est une étape de test hachée (voir chainPayload)
+type chainTest struct {
+	Name            string                 `json:"name"`
+	Outcome         string                 `json:"outcome"`
+	Measurements    map[string]interface{} `json:"measurements"`
+	ErrorMessage    string                 `json:"error_message"`
+	DurationSeconds float64                `json:"duration_seconds"`
+}
+
+// newChainPayload recopie champ par champ un diagnostic relu en base
+func newChainPayload(d models.Diagnostic) chainPayload {
+	p := chainPayload{
+		ID:    d.ID,
+		RunID: d.RunID,
+
+		MachineName:  d.SystemInfo.MachineName,
+		SerialNumber: d.SystemInfo.SerialNumber,
+		Model:        d.SystemInfo.Model,
+		OSVersion:    d.SystemInfo.OSVersion,
+		MacOSVersion: d.SystemInfo.MacOSVersion,
+
+		CPUModel:       d.CPU.Model,
+		CPUCores:       d.CPU.Cores,
+		CPUFrequency:   d.CPU.Frequency,
+		CPUTemperature: d.CPU.Temperature,
+
+		RAMTotal:          d.RAM.Total,
+		RAMUsed:           d.RAM.Used,
+		RAMAvailable:      d.RAM.Available,
+		RAMType:           d.RAM.Type,
+		RAMTotalBytes:     d.RAM.TotalBytes,
+		RAMUsedBytes:      d.RAM.UsedBytes,
+		RAMAvailableBytes: d.RAM.AvailableBytes,
+
+		StorageType:           d.Storage.Type,
+		StorageCapacity:       d.Storage.Capacity,
+		StorageUsed:           d.Storage.Used,
+		StorageAvailable:      d.Storage.Available,
+		StorageHealth:         d.Storage.Health,
+		StorageDeviceName:     d.Storage.DeviceName,
+		StorageCapacityBytes:  d.Storage.CapacityBytes,
+		StorageUsedBytes:      d.Storage.UsedBytes,
+		StorageAvailableBytes: d.Storage.AvailableBytes,
+
+		BatteryCycleCount:         d.Battery.CycleCount,
+		BatteryHealth:             d.Battery.Health,
+		BatteryCapacity:           d.Battery.Capacity,
+		BatteryMaxCapacity:        d.Battery.MaxCapacity,
+		BatteryCondition:          d.Battery.Condition,
+		BatteryIsCharging:         d.Battery.IsCharging,
+		BatteryPowerAdapter:       d.Battery.PowerAdapter,
+		BatteryCapacityPercent:    d.Battery.CapacityPercent,
+		BatteryMaxCapacityPercent: d.Battery.MaxCapacityPercent,
+
+		Status:   d.Status,
+		Duration: d.Duration,
+		Tests:    []chainTest{},
+
+		Timestamp:        chainTime(d.Timestamp),
+		CreatedAt:        chainTime(d.CreatedAt),
+		CapturedAt:       chainTimePtr(d.CapturedAt),
+		ReceivedAt:       chainTime(d.ReceivedAt),
+		ClockSkewSeconds: d.ClockSkewSeconds,
+		TimestampFlag:    d.TimestampFlag,
+
+		SubmittedBy: d.SubmittedBy,
+		StationID:   d.StationID,
+		Signature:   d.Signature,
+		SignedAt:    chainTimePtr(d.SignedAt),
+	}
+	for _, t := range d.Tests {
+		p.Tests = append(p.Tests, chainTest{
+			Name:            t.Name,
+			Outcome:         t.Outcome,
+			Measurements:    t.Measurements,
+			ErrorMessage:    t.ErrorMessage,
+			DurationSeconds: t.DurationSeconds,
+		})
+	}
+	return p
+}
+
+// chainTime fige le format des horodatages hachés
+func chainTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func chainTimePtr(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := chainTime(*t)
+	return &s
+}
+
+// contentHash calcule le hash du contenu d'un diagnostic tel que relu en
+// base : SHA-256 de chainVersion suivi de la forme JSON canonique de son
+// chainPayload (clés triées, sans espaces)
+func contentHash(d models.Diagnostic) (string, error) {
+	canonical, err := models.CanonicalJSON(newChainPayload(d))
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte{chainVersion})
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// linkHash calcule le hash d'un maillon : SHA-256 de chainVersion, du hash
+// du maillon précédent et du hash du contenu. Un maillon de diagnostic
+// supprimé conserve prev_hash et content_hash : son hash reste vérifiable.
+func linkHash(prevHash, contentHash string) string {
+	h := sha256.New()
+	h.Write([]byte{chainVersion})
+	h.Write([]byte(prevHash))
+	h.Write([]byte(contentHash))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// chainHead retourne le dernier maillon d'ID inférieur à beforeID, parmi les
+// diagnostics chaînés et les diagnostics supprimés (nil si la chaîne est vide)
+func chainHead(ctx context.Context, q queryer, beforeID int64) (*models.ChainHead, error) {
+	var head models.ChainHead
+	err := q.QueryRowContext(ctx, `
+	SELECT id, hash FROM (
+		SELECT id, hash FROM diagnostics WHERE hash IS NOT NULL AND id < ?
+		UNION ALL
+		SELECT id, hash FROM diagnostic_tombstones WHERE id < ?
+	) ORDER BY id DESC LIMIT 1`, beforeID, beforeID).Scan(&head.ID, &head.Hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+// linkDiagnostic rattache un diagnostic venant d'être inséré au maillon
+// précédent, puis calcule son hash à partir de la ligne relue en base
+func linkDiagnostic(ctx context.Context, tx *sql.Tx, id int64) error {
+	prevHash := genesisHash
+	head, err := chainHead(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if head != nil {
+		prevHash = head.Hash
+	}
+	_, err = sealDiagnostic(ctx, tx, id, prevHash)
+	return err
+}
+
+// sealDiagnostic enregistre prev_hash, le hash du contenu et le hash du
+// maillon d'un diagnostic, et retourne ce dernier
+func sealDiagnostic(ctx context.Context, tx *sql.Tx, id int64, prevHash string) (string, error) {
+	d, err := getDiagnostic(ctx, tx, id)
+	if err != nil {
+		return "", err
+	}
+	content, err := contentHash(*d)
+	if err != nil {
+		return "", err
+	}
+
+	hash := linkHash(prevHash, content)
+	_, err = tx.ExecContext(ctx, "UPDATE diagnostics SET prev_hash = ?, content_hash = ?, hash = ? WHERE id = ?",
+		prevHash, content, hash, id)
+	return hash, err
+}
+
+// buryDiagnostics conserve le maillon des diagnostics sélectionnés par
+// idQuery avant leur suppression, avec le hash de leur contenu
+func buryDiagnostics(ctx context.Context, tx *sql.Tx, reason, idQuery string, args ...interface{}) error {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO diagnostic_tombstones (id, prev_hash, content_hash, hash, reason, deleted_at)
+	SELECT id, COALESCE(prev_hash, ''), COALESCE(content_hash, ''), COALESCE(hash, ''), ?, ?
+	FROM diagnostics WHERE id IN (`+idQuery+`)`,
+		append([]interface{}{reason, time.Now().UTC()}, args...)...)
+	return err
+}
+
+// backfillHashChain chaîne les diagnostics existants dans l'ordre des ID (migration 0010)
+func backfillHashChain(tx *sql.Tx) error {
+	ctx := context.Background()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM diagnostics ORDER BY id")
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := linkDiagnostic(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chainLink est un maillon lu pour la vérification
+type chainLink struct {
+	id          int64
+	prevHash    string
+	contentHash string // hash du contenu enregistré, seul témoin d'un diagnostic supprimé
+	hash        string
+	tombstone   bool
+}
+
+// VerifyChain recalcule toute la chaîne dans l'ordre des ID et s'arrête au
+// premier maillon invalide : contenu modifié, ou prev_hash ne correspondant
+// pas au maillon précédent (ligne supprimée, insérée ou réordonnée hors de l'API)
+func (s *SQLiteStore) VerifyChain(ctx context.Context) (*models.ChainReport, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "verify_chain")
+
+	// Une seule transaction de lecture : la vérification porte sur un instantané
+	// cohérent. BEGIN DEFERRED explicite, les transactions du pool prenant le
+	// verrou d'écriture (voir dataSourceName) qui bloquerait les envois.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN DEFERRED"); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+
+	report := &models.ChainReport{Valid: true}
+	prevHash := genesisHash
+	var lastID int64
+
+	for {
+		links, err := readChainLinks(ctx, conn, lastID)
+		if err != nil {
+			return nil, err
+		}
+		if len(links) == 0 {
+			break
+		}
+
+		// Relire le contenu des diagnostics de la page pour recalculer leur hash
+		var ids []interface{}
+		for _, l := range links {
+			if !l.tombstone {
+				ids = append(ids, l.id)
+			}
+		}
+		byID := make(map[int64]models.Diagnostic, len(ids))
+		if len(ids) > 0 {
+			diagnostics, err := selectDiagnostics(ctx, conn,
+				"SELECT "+diagnosticColumns+" FROM diagnostics WHERE id IN ("+placeholders(len(ids))+")", ids...)
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range diagnostics {
+				byID[d.ID] = d
+			}
+		}
+
+		for _, l := range links {
+			if l.prevHash != prevHash {
+				report.Break = &models.ChainBreak{
+					ID:       l.id,
+					Reason:   "prev_hash ne correspond pas au maillon précédent (ligne supprimée, insérée ou réordonnée hors de l'API)",
+					Expected: prevHash,
+					Found:    l.prevHash,
+				}
+				break
+			}
+
+			if l.tombstone {
+				if hash := linkHash(l.prevHash, l.contentHash); l.hash != hash {
+					report.Break = &models.ChainBreak{
+						ID:       l.id,
+						Reason:   "maillon de suppression falsifié : le hash ne correspond pas au contenu supprimé",
+						Expected: hash,
+						Found:    l.hash,
+					}
+					break
+				}
+				report.Tombstones++
+			} else {
+				content, err := contentHash(byID[l.id])
+				if err != nil {
+					return nil, err
+				}
+				if hash := linkHash(l.prevHash, content); l.hash != hash {
+					reason := "contenu modifié : le hash recalculé ne correspond pas au hash enregistré"
+					if l.hash == "" {
+						reason = "hash manquant"
+					}
+					report.Break = &models.ChainBreak{ID: l.id, Reason: reason, Expected: hash, Found: l.hash}
+					break
+				}
+				report.Checked++
+			}
+
+			prevHash = l.hash
+			report.Head = &models.ChainHead{ID: l.id, Hash: l.hash}
+		}
+		if report.Break != nil {
+			report.Valid = false
+			break
+		}
+
+		lastID = links[len(links)-1].id
+	}
+
+	report.VerifiedAt = time.Now().UTC()
+	return report, nil
+}
+
+// readChainLinks lit la page de maillons suivant afterID
+func readChainLinks(ctx context.Context, q queryer, afterID int64) ([]chainLink, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT id, COALESCE(prev_hash, ''), COALESCE(content_hash, ''), COALESCE(hash, ''), tombstone FROM (
+		SELECT id, prev_hash, content_hash, hash, 0 AS tombstone FROM diagnostics
+		UNION ALL
+		SELECT id, prev_hash, content_hash, hash, 1 AS tombstone FROM diagnostic_tombstones
+	) WHERE id > ? ORDER BY id LIMIT ?`, afterID, chainPageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []chainLink
+	for rows.Next() {
+		var l chainLink
+		if err := rows.Scan(&l.id, &l.prevHash, &l.contentHash, &l.hash, &l.tombstone); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+// createChain insère un diagnostic par date de réception et retourne leurs ID
+func createChain(t *testing.T, store *SQLiteStore, received ...time.Time) []int64 {
+	t.Helper()
+	var ids []int64
+	for i, at := range received {
+		id, err := store.CreateDiagnostic(context.Background(),
+			testDiagnostic(t, "C02TEST"+string(rune('A'+i)), at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// TestHashChainLinking vérifie que chaque diagnostic est rattaché au maillon
+// précédent, diagnostic supprimé compris
+func TestHashChainLinking(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	ids := createChain(t, store, now, now, now)
+
+	// Supprimer la tête : le diagnostic suivant se rattache à son maillon
+	if err := store.DeleteDiagnostic(ctx, ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	next := createChain(t, store, now)[0]
+
+	var deletedHash string
+	if err := store.db.QueryRow("SELECT hash FROM diagnostic_tombstones WHERE id = ?", ids[2]).Scan(&deletedHash); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.GetDiagnosticByID(ctx, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.GetDiagnosticByID(ctx, ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := store.GetDiagnosticByID(ctx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		prevHash string
+		want     string
+	}{
+		{"premier maillon", first.PrevHash, genesisHash},
+		{"deuxième maillon", second.PrevHash, first.Hash},
+		{"après un diagnostic supprimé", last.PrevHash, deletedHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prevHash != tt.want {
+				t.Errorf("prev_hash = %s, attendu %s", tt.prevHash, tt.want)
+			}
+		})
+	}
+
+	for _, d := range []*models.Diagnostic{first, second, last} {
+		content, err := contentHash(*d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash := linkHash(d.PrevHash, content); d.Hash != hash {
+			t.Errorf("diagnostic %d : hash %q, recalculé %q", d.ID, d.Hash, hash)
+		}
+	}
+}
+
+// TestContentHashFrozen fige le hash d'un diagnostic : s'il change,
+// chainPayload ou son encodage a changé sans incrémenter chainVersion
+func TestContentHashFrozen(t *testing.T) {
+	at := time.Date(2024, 4, 12, 9, 30, 0, 0, time.UTC)
+	total := int64(16 << 30)
+	d := models.Diagnostic{
+		ID:    1,
+		RunID: "7d4c2a52-3a7e-4c55-9d0b-1f2e3d4c5b6a",
+		SystemInfo: models.SystemInfo{
+			MachineName:  "MacBook Pro",
+			SerialNumber: "C02TESTA",
+			Model:        "MacBookPro18,3",
+			OSVersion:    "macOS 14.4.1",
+		},
+		CPU:       models.CPUInfo{Model: "Apple M1 Pro", Cores: 8},
+		RAM:       models.RAMInfo{Total: "16 GB", TotalBytes: &total},
+		Storage:   models.StorageInfo{Type: "SSD", Capacity: "512 GB"},
+		Battery:   models.BatteryInfo{CycleCount: 120, Health: "Normal"},
+		Status:    "success",
+		Duration:  12.5,
+		Tests:     []models.TestResult{{Name: "cpu", Outcome: "passed", DurationSeconds: 1.5}},
+		Timestamp: at,
+		CreatedAt: at,
+		// Heure locale : le hash ne dépend pas du fuseau de lecture
+		ReceivedAt: at.In(time.FixedZone("CEST", 2*3600)),
+	}
+
+	content, err := contentHash(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "9907c2f84239e66bac3771b47060339c982cfca3cac79f31112a6658b56cb262"
+	if content != want {
+		t.Errorf("content_hash = %s, attendu %s", content, want)
+	}
+	const wantLink = "10adf98dde86173ae263cd3d9cc4868347d919d2d1dbe80ce08cc86519498acb"
+	if hash := linkHash(genesisHash, content); hash != wantLink {
+		t.Errorf("hash = %s, attendu %s", hash, wantLink)
+	}
+
+	// Les champs de l'API hors chainPayload ne participent pas au hash
+	d.PrevHash, d.Hash = genesisHash, "ignoré"
+	if again, _ := contentHash(d); again != content {
+		t.Errorf("prev_hash ou hash modifie le hash du contenu")
+	}
+}
+
+// TestVerifyChain vérifie la détection des modifications faites hors de l'API
+func TestVerifyChain(t *testing.T) {
+	now := time.Now().UTC()
+	old := now.AddDate(-2, 0, 0)
+
+	tests := []struct {
+		name       string
+		received   []time.Time // quatre diagnostics reçus maintenant si vide
+		tamper     func(t *testing.T, s *SQLiteStore, ids []int64)
+		valid      bool
+		checked    int
+		tombstones int
+		breakID    int
+		reason     string
+	}{
+		{
+			name:    "chaîne intacte",
+			tamper:  func(t *testing.T, s *SQLiteStore, ids []int64) {},
+			valid:   true,
+			checked: 4,
+		},
+		{
+			name: "suppression par l'API",
+			tamper: func(t *testing.T, s *SQLiteStore, ids []int64) {
+				if err := s.DeleteDiagnostic(context.Background(), ids[1]); err != nil {
+					t.Fatal(err)
+				}
+			},
+			valid:      true,
+			checked:    3,
+			tombstones: 1,
+		},
+		{
+			name:     "purge de rétention",
+			received: []time.Time{old, now, old, now},
+			tamper: func(t *testing.T, s *SQLiteStore, ids []int64) {
+				if _, err := s.Purge(context.Background(), now.AddDate(-1, 0, 0), time.Time{}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			valid:      true,
+			checked:    2,
+			tombstones: 2,
+		},
+		{
+			name: "contenu modifié",
+			tamper: func(t *testing.T, s *SQLiteStore, ids []int64) {
+				mustExec(t, s, "UPDATE diagnostics SET status = 'failed' WHERE id = ?", ids[2])
+			},
+			breakID: 3,
+			reason:  "contenu modifié",
+		},
+		{
+			name: "étape de test modifiée",
+			tamper: func(t *testing.T, s *SQLiteStore, ids []int64) {
+				mustExec(t, s, "UPDATE diagnostic_tests SET outcome = 'failed' WHERE diagnostic_id = ?", ids[1])
+			},
+			breakID: 2,
+			reason:  "contenu modifié",
+		},
+		{
+			name: "ligne supprimée hors de l'API",
+			tamper: func(t *testing.T, s *SQLiteStore, ids []int64) {
+				mustExec(t, s, "DELETE FROM diagnostics WHERE id = ?", ids[1])
+			},
+			breakID: 3,
+			reason:  "prev_hash",
+		},
+		{
+			name: "hash effacé",
+			tamper: func(t *testing.T, s *SQLiteStore, ids []int64) {
+				mustExec(t, s, "UPDATE diagnostics SET hash = NULL WHERE id = ?", ids[3])
+			},
+			breakID: 4,
+			reason:  "hash manquant",
+		},
+		{
+			name: "maillon de suppression falsifié",
+			tamper: func(t *testing.T, s *SQLiteStore, ids []int64) {
+				if err := s.DeleteDiagnostic(context.Background(), ids[1]); err != nil {
+					t.Fatal(err)
+				}
+				mustExec(t, s, "UPDATE diagnostic_tombstones SET prev_hash = ? WHERE id = ?", genesisHash, ids[1])
+			},
+			breakID: 2,
+			reason:  "prev_hash",
+		},
+		{
+			name: "ligne remplacée par un maillon de suppression",
+			tamper: func(t *testing.T, s *SQLiteStore, ids []int64) {
+				var prevHash, hash string
+				if err := s.db.QueryRow("SELECT prev_hash, hash FROM diagnostics WHERE id = ?", ids[1]).Scan(&prevHash, &hash); err != nil {
+					t.Fatal(err)
+				}
+				mustExec(t, s, "DELETE FROM diagnostics WHERE id = ?", ids[1])
+				mustExec(t, s, `INSERT INTO diagnostic_tombstones (id, prev_hash, content_hash, hash, reason, deleted_at)
+					VALUES (?, ?, '', ?, 'deleted', ?)`, ids[1], prevHash, hash, time.Now().UTC())
+			},
+			breakID: 2,
+			reason:  "maillon de suppression falsifié",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			received := tt.received
+			if len(received) == 0 {
+				received = []time.Time{now, now, now, now}
+			}
+			ids := createChain(t, store, received...)
+			tt.tamper(t, store, ids)
+
+			report, err := store.VerifyChain(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Valid != tt.valid {
+				t.Fatalf("valid = %v, attendu %v (rupture : %+v)", report.Valid, tt.valid, report.Break)
+			}
+			if tt.valid {
+				if report.Checked != tt.checked || report.Tombstones != tt.tombstones {
+					t.Errorf("checked = %d, tombstones = %d, attendu %d et %d",
+						report.Checked, report.Tombstones, tt.checked, tt.tombstones)
+				}
+				return
+			}
+			if report.Break == nil || report.Break.ID != int64(tt.breakID) {
+				t.Fatalf("rupture %+v, attendue au diagnostic %d", report.Break, tt.breakID)
+			}
+			if !strings.Contains(report.Break.Reason, tt.reason) {
+				t.Errorf("motif %q, attendu %q", report.Break.Reason, tt.reason)
+			}
+		})
+	}
+}
@@ -1,109 +1,371 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
+	"diagnostic-backend/logging"
+	"diagnostic-backend/metrics"
 	"diagnostic-backend/models"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var DB *sql.DB
+// SQLiteStore implémente Store au-dessus d'une base SQLite
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// Vérification à la compilation que SQLiteStore implémente Store
+var _ Store = (*SQLiteStore)(nil)
 
-// InitDB initialise la connexion à la base de données SQLite
-func InitDB(dbPath string) error {
-	var err error
-	DB, err = sql.Open("sqlite3", dbPath)
+// Open ouvre la base SQLite et vérifie la connexion, sans appliquer les migrations.
+// Les pragmas (ex: journal_mode=WAL) sont appliqués à chaque connexion du pool.
+func Open(dbPath string, pragmas map[string]string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", dataSourceName(dbPath, pragmas))
 	if err != nil {
-		return fmt.Errorf("erreur d'ouverture de la base de données: %v", err)
+		return nil, fmt.Errorf("erreur d'ouverture de la base de données: %v", err)
 	}
 
 	// Vérifier la connexion
-	if err = DB.Ping(); err != nil {
-		return fmt.Errorf("erreur de connexion à la base de données: %v", err)
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("erreur de connexion à la base de données: %v", err)
 	}
 
-	log.Println("Connexion à la base de données établie")
+	return db, nil
+}
 
-	// Créer les tables
-	if err = createTables(); err != nil {
-		return fmt.Errorf("erreur de création des tables: %v", err)
+// dataSourceName ajoute les pragmas au chemin sous la forme comprise par
+// go-sqlite3 ("chemin?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate").
+// Les transactions prennent le verrou d'écriture dès BEGIN (_txlock=immediate) :
+// une insertion qui lit la tête de la chaîne de hachage puis écrit attend son
+// tour (busy_timeout) au lieu d'échouer en SQLITE_BUSY face à un envoi concurrent.
+func dataSourceName(dbPath string, pragmas map[string]string) string {
+	names := make([]string, 0, len(pragmas))
+	for name := range pragmas {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	return nil
+	params := url.Values{}
+	for _, name := range names {
+		params.Set("_"+name, pragmas[name])
+	}
+	params.Set("_txlock", "immediate")
+
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return dbPath + sep + params.Encode()
 }
 
-// createTables crée les tables nécessaires
-func createTables() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS diagnostics (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		machine_name TEXT NOT NULL,
-		serial_number TEXT NOT NULL,
-		model TEXT NOT NULL,
-		os_version TEXT NOT NULL,
-		macos_version TEXT,
-		
-		cpu_model TEXT NOT NULL,
-		cpu_cores INTEGER NOT NULL,
-		cpu_frequency TEXT NOT NULL,
-		cpu_temperature TEXT,
-		
-		ram_total TEXT NOT NULL,
-		ram_used TEXT NOT NULL,
-		ram_available TEXT NOT NULL,
-		ram_type TEXT,
-		
-		storage_type TEXT NOT NULL,
-		storage_capacity TEXT NOT NULL,
-		storage_used TEXT NOT NULL,
-		storage_available TEXT NOT NULL,
-		storage_health TEXT,
-		storage_device_name TEXT,
-		
-		battery_cycle_count INTEGER NOT NULL,
-		battery_health TEXT NOT NULL,
-		battery_capacity TEXT NOT NULL,
-		battery_max_capacity TEXT,
-		battery_condition TEXT,
-		battery_is_charging BOOLEAN NOT NULL,
-		battery_power_adapter TEXT,
-		
-		status TEXT NOT NULL,
-		duration REAL NOT NULL,
-		timestamp DATETIME NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_serial_number ON diagnostics(serial_number);
-	CREATE INDEX IF NOT EXISTS idx_created_at ON diagnostics(created_at);
-	CREATE INDEX IF NOT EXISTS idx_status ON diagnostics(status);
-	`
+// NewSQLiteStore ouvre la base SQLite et applique les migrations manquantes
+func NewSQLiteStore(dbPath string, pragmas map[string]string) (*SQLiteStore, error) {
+	db, err := Open(dbPath, pragmas)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Connexion à la base de données établie", "path", dbPath)
 
-	_, err := DB.Exec(query)
+	// Mettre le schéma à jour
+	applied, err := Migrate(db)
 	if err != nil {
-		return err
+		db.Close()
+		return nil, fmt.Errorf("erreur de migration du schéma: %v", err)
 	}
 
-	log.Println("Tables créées ou déjà existantes")
-	return nil
+	version, err := SchemaVersion(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("erreur de lecture de la version du schéma: %v", err)
+	}
+	slog.Info("Schéma à jour", "schema_version", version, "migrations_applied", applied)
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// diagnosticColumns liste les colonnes lues par scanDiagnostic, dans l'ordre
+const diagnosticColumns = `
+		id, machine_name, serial_number, model, os_version, macos_version,
+		cpu_model, cpu_cores, cpu_frequency, cpu_temperature,
+		ram_total, ram_used, ram_available, ram_type,
+		storage_type, storage_capacity, storage_used, storage_available, storage_health, storage_device_name,
+		battery_cycle_count, battery_health, battery_capacity, battery_max_capacity,
+		battery_condition, battery_is_charging, battery_power_adapter,
+		status, duration, timestamp, created_at,
+		ram_total_bytes, ram_used_bytes, ram_available_bytes,
+		storage_capacity_bytes, storage_used_bytes, storage_available_bytes,
+		battery_capacity_percent, battery_max_capacity_percent,
+		run_id,
+		captured_at, received_at, clock_skew_seconds, timestamp_flag,
+		submitted_by, station_id, signature, signed_at,
+		prev_hash, hash`
+
+// rowScanner est satisfait par *sql.Row et *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer est satisfait par *sql.DB et *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// scanDiagnostic lit une ligne sélectionnée avec diagnosticColumns.
+// extra reçoit les colonnes supplémentaires sélectionnées après celles-ci.
+func scanDiagnostic(row rowScanner, extra ...interface{}) (models.Diagnostic, error) {
+	var d models.Diagnostic
+	var macosVersion, cpuTemp, ramType, storageHealth, storageDevice sql.NullString
+	var batteryMaxCapacity, batteryCondition, batteryPowerAdapter sql.NullString
+	var ramTotalBytes, ramUsedBytes, ramAvailableBytes sql.NullInt64
+	var storageCapacityBytes, storageUsedBytes, storageAvailableBytes sql.NullInt64
+	var batteryCapacityPercent, batteryMaxCapacityPercent sql.NullFloat64
+	var runID, timestampFlag sql.NullString
+	var capturedAt, receivedAt sql.NullTime
+	var clockSkewSeconds sql.NullFloat64
+	var submittedBy, stationID sql.NullInt64
+	var signature, prevHash, hash sql.NullString
+	var signedAt sql.NullTime
+
+	dest := []interface{}{
+		&d.ID, &d.SystemInfo.MachineName, &d.SystemInfo.SerialNumber, &d.SystemInfo.Model,
+		&d.SystemInfo.OSVersion, &macosVersion,
+		&d.CPU.Model, &d.CPU.Cores, &d.CPU.Frequency, &cpuTemp,
+		&d.RAM.Total, &d.RAM.Used, &d.RAM.Available, &ramType,
+		&d.Storage.Type, &d.Storage.Capacity, &d.Storage.Used, &d.Storage.Available,
+		&storageHealth, &storageDevice,
+		&d.Battery.CycleCount, &d.Battery.Health, &d.Battery.Capacity,
+		&batteryMaxCapacity, &batteryCondition, &d.Battery.IsCharging, &batteryPowerAdapter,
+		&d.Status, &d.Duration, &d.Timestamp, &d.CreatedAt,
+		&ramTotalBytes, &ramUsedBytes, &ramAvailableBytes,
+		&storageCapacityBytes, &storageUsedBytes, &storageAvailableBytes,
+		&batteryCapacityPercent, &batteryMaxCapacityPercent,
+		&runID,
+		&capturedAt, &receivedAt, &clockSkewSeconds, &timestampFlag,
+		&submittedBy, &stationID, &signature, &signedAt,
+		&prevHash, &hash,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return d, err
+	}
+
+	// Gérer les valeurs NULL
+	d.SystemInfo.MacOSVersion = macosVersion.String
+	d.CPU.Temperature = cpuTemp.String
+	d.RAM.Type = ramType.String
+	d.Storage.Health = storageHealth.String
+	d.Storage.DeviceName = storageDevice.String
+	d.Battery.MaxCapacity = batteryMaxCapacity.String
+	d.Battery.Condition = batteryCondition.String
+	d.Battery.PowerAdapter = batteryPowerAdapter.String
+
+	d.RAM.TotalBytes = nullInt64Ptr(ramTotalBytes)
+	d.RAM.UsedBytes = nullInt64Ptr(ramUsedBytes)
+	d.RAM.AvailableBytes = nullInt64Ptr(ramAvailableBytes)
+	d.Storage.CapacityBytes = nullInt64Ptr(storageCapacityBytes)
+	d.Storage.UsedBytes = nullInt64Ptr(storageUsedBytes)
+	d.Storage.AvailableBytes = nullInt64Ptr(storageAvailableBytes)
+	d.Battery.CapacityPercent = nullFloat64Ptr(batteryCapacityPercent)
+	d.Battery.MaxCapacityPercent = nullFloat64Ptr(batteryMaxCapacityPercent)
+	d.RunID = runID.String
+
+	if capturedAt.Valid {
+		d.CapturedAt = &capturedAt.Time
+	}
+	d.ReceivedAt = receivedAt.Time
+	d.ClockSkewSeconds = nullFloat64Ptr(clockSkewSeconds)
+	d.TimestampFlag = timestampFlag.String
+	d.SubmittedBy = nullInt64Ptr(submittedBy)
+	d.StationID = nullInt64Ptr(stationID)
+	d.Signature = signature.String
+	if signedAt.Valid {
+		d.SignedAt = &signedAt.Time
+	}
+	d.PrevHash = prevHash.String
+	d.Hash = hash.String
+
+	return d, nil
+}
+
+// nullIfEmpty stocke NULL plutôt qu'une chaîne vide (index uniques partiels)
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullInt64Ptr convertit une valeur SQL nullable en pointeur (nil si NULL)
+func nullInt64Ptr(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
+
+// nullFloat64Ptr convertit une valeur SQL nullable en pointeur (nil si NULL)
+func nullFloat64Ptr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// queryDiagnostics exécute une requête de sélection et lit toutes les lignes
+func (s *SQLiteStore) queryDiagnostics(ctx context.Context, query string, args ...interface{}) ([]models.Diagnostic, error) {
+	return selectDiagnostics(ctx, s.db, query, args...)
+}
+
+// selectDiagnostics lit les diagnostics et leurs étapes avec q (base ou transaction)
+func selectDiagnostics(ctx context.Context, q queryer, query string, args ...interface{}) ([]models.Diagnostic, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var diagnostics []models.Diagnostic
+
+	for rows.Next() {
+		d, err := scanDiagnostic(rows)
+		if err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := attachTests(ctx, q, diagnostics); err != nil {
+		return nil, err
+	}
+
+	return diagnostics, nil
 }
 
 // CreateDiagnostic insère un nouveau diagnostic dans la base de données
-func CreateDiagnostic(diag models.DiagnosticRequest) (int64, error) {
+func (s *SQLiteStore) CreateDiagnostic(ctx context.Context, diag models.DiagnosticRequest) (int64, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "create_diagnostic")
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // sans effet si Commit a réussi
+
+	id, err := insertDiagnostic(ctx, tx, diag)
+	if isUniqueViolation(err) {
+		return 0, ErrDuplicateRunID
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	logging.FromContext(ctx).Debug("Diagnostic inséré",
+		"diagnostic_id", id, "serial_number", diag.SystemInfo.SerialNumber)
+	return id, nil
+}
+
+// CreateDiagnostics insère plusieurs diagnostics dans une seule transaction :
+// soit tous sont enregistrés, soit aucun. Un diagnostic dont le run_id est
+// déjà connu (lot renvoyé après une coupure) n'est pas réinséré.
+func (s *SQLiteStore) CreateDiagnostics(ctx context.Context, diags []models.DiagnosticRequest) ([]InsertResult, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "create_diagnostics")
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // sans effet si Commit a réussi
+
+	results := make([]InsertResult, 0, len(diags))
+	created := 0
+	for i, diag := range diags {
+		if diag.RunID != "" {
+			existingID, err := findDiagnosticIDByRunID(ctx, tx, diag.RunID)
+			if err != nil {
+				return nil, fmt.Errorf("diagnostic %d: %v", i, err)
+			}
+			if existingID != 0 {
+				results = append(results, InsertResult{ID: existingID, Duplicate: true})
+				continue
+			}
+		}
+
+		id, err := insertDiagnostic(ctx, tx, diag)
+		if err != nil {
+			return nil, fmt.Errorf("diagnostic %d: %v", i, err)
+		}
+		results = append(results, InsertResult{ID: id})
+		created++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Debug("Lot de diagnostics inséré",
+		"created", created, "duplicates", len(diags)-created)
+	return results, nil
+}
+
+// insertDiagnostic insère un diagnostic et ses étapes, et retourne son ID
+func insertDiagnostic(ctx context.Context, tx *sql.Tx, diag models.DiagnosticRequest) (int64, error) {
+	// Sans ApplyReceipt (appel interne), le diagnostic est daté de son insertion
+	receivedAt := diag.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	timestamp := diag.Timestamp
+	if timestamp.IsZero() {
+		timestamp = receivedAt
+	}
+
+	// Toujours stocker en UTC pour que les dates restent comparables entre elles
+	receivedAt = receivedAt.UTC()
+	timestamp = timestamp.UTC()
+
+	var stationID, signature, signedAt interface{}
+	if sig := diag.Signature; sig != nil {
+		stationID = sig.StationID
+		// Station identifiée par son certificat client : pas de signature HMAC à conserver
+		if sig.Signature != "" {
+			signature, signedAt = sig.Signature, sig.SignedAt.UTC()
+		}
+	}
+
 	query := `
 	INSERT INTO diagnostics (
 		machine_name, serial_number, model, os_version, macos_version,
 		cpu_model, cpu_cores, cpu_frequency, cpu_temperature,
 		ram_total, ram_used, ram_available, ram_type,
 		storage_type, storage_capacity, storage_used, storage_available, storage_health, storage_device_name,
-		battery_cycle_count, battery_health, battery_capacity, battery_max_capacity, 
+		battery_cycle_count, battery_health, battery_capacity, battery_max_capacity,
 		battery_condition, battery_is_charging, battery_power_adapter,
-		status, duration, timestamp
+		status, duration, timestamp,
+		ram_total_bytes, ram_used_bytes, ram_available_bytes,
+		storage_capacity_bytes, storage_used_bytes, storage_available_bytes,
+		battery_capacity_percent, battery_max_capacity_percent,
+		run_id,
+		captured_at, received_at, clock_skew_seconds, timestamp_flag,
+		submitted_by, station_id, signature, signed_at, api_key_id
 	) VALUES (
 		?, ?, ?, ?, ?,
 		?, ?, ?, ?,
@@ -111,11 +373,17 @@ func CreateDiagnostic(diag models.DiagnosticRequest) (int64, error) {
 		?, ?, ?, ?, ?, ?,
 		?, ?, ?, ?,
 		?, ?, ?,
-		?, ?, ?
+		?, ?, ?,
+		?, ?, ?,
+		?, ?, ?,
+		?, ?,
+		?,
+		?, ?, ?, ?,
+		?, ?, ?, ?, ?
 	)
 	`
 
-	result, err := DB.Exec(query,
+	result, err := tx.ExecContext(ctx, query,
 		diag.SystemInfo.MachineName, diag.SystemInfo.SerialNumber, diag.SystemInfo.Model,
 		diag.SystemInfo.OSVersion, diag.SystemInfo.MacOSVersion,
 		diag.CPU.Model, diag.CPU.Cores, diag.CPU.Frequency, diag.CPU.Temperature,
@@ -125,7 +393,13 @@ func CreateDiagnostic(diag models.DiagnosticRequest) (int64, error) {
 		diag.Battery.CycleCount, diag.Battery.Health, diag.Battery.Capacity,
 		diag.Battery.MaxCapacity, diag.Battery.Condition, diag.Battery.IsCharging,
 		diag.Battery.PowerAdapter,
-		diag.Status, diag.Duration, time.Now(),
+		diag.Status, diag.Duration, timestamp,
+		diag.RAM.TotalBytes, diag.RAM.UsedBytes, diag.RAM.AvailableBytes,
+		diag.Storage.CapacityBytes, diag.Storage.UsedBytes, diag.Storage.AvailableBytes,
+		diag.Battery.CapacityPercent, diag.Battery.MaxCapacityPercent,
+		nullIfEmpty(diag.RunID),
+		diag.CapturedAt, receivedAt, diag.ClockSkewSeconds, nullIfEmpty(diag.TimestampFlag),
+		diag.SubmittedBy, stationID, signature, signedAt, diag.APIKeyID,
 	)
 
 	if err != nil {
@@ -137,241 +411,224 @@ func CreateDiagnostic(diag models.DiagnosticRequest) (int64, error) {
 		return 0, err
 	}
 
-	log.Printf("Diagnostic créé avec l'ID: %d (Machine: %s)", id, diag.SystemInfo.MachineName)
+	if err := insertTests(ctx, tx, id, diag.Tests); err != nil {
+		return 0, err
+	}
+
+	if err := upsertMachine(ctx, tx, id, diag, timestamp); err != nil {
+		return 0, err
+	}
+
+	if err := linkDiagnostic(ctx, tx, id); err != nil {
+		return 0, err
+	}
+
 	return id, nil
 }
 
-// GetAllDiagnostics récupère tous les diagnostics
-func GetAllDiagnostics(limit int) ([]models.Diagnostic, error) {
-	query := `
-	SELECT 
-		id, machine_name, serial_number, model, os_version, macos_version,
-		cpu_model, cpu_cores, cpu_frequency, cpu_temperature,
-		ram_total, ram_used, ram_available, ram_type,
-		storage_type, storage_capacity, storage_used, storage_available, storage_health, storage_device_name,
-		battery_cycle_count, battery_health, battery_capacity, battery_max_capacity, 
-		battery_condition, battery_is_charging, battery_power_adapter,
-		status, duration, timestamp, created_at
-	FROM diagnostics
-	ORDER BY created_at DESC
-	`
+// ListDiagnostics récupère une page de diagnostics filtrés et triés.
+// Le curseur retourné est vide quand il n'y a plus de page suivante.
+func (s *SQLiteStore) ListDiagnostics(ctx context.Context, opts ListOptions) ([]models.Diagnostic, string, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "list_diagnostics")
+
+	sort := opts.Sort
+	if sort.Field == "" {
+		sort = DefaultSort
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
 
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
+	query, args, err := listQuery(opts, sort)
+	if err != nil {
+		return nil, "", err
 	}
+	// Une ligne de plus que demandé indique s'il existe une page suivante
+	query += "\n\tLIMIT ?"
+	args = append(args, limit+1)
 
-	rows, err := DB.Query(query)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
 	var diagnostics []models.Diagnostic
+	var sortValues []interface{}
 
 	for rows.Next() {
-		var d models.Diagnostic
-		var macosVersion, cpuTemp, ramType, storageHealth, storageDevice sql.NullString
-		var batteryMaxCapacity, batteryCondition, batteryPowerAdapter sql.NullString
-
-		err := rows.Scan(
-			&d.ID, &d.SystemInfo.MachineName, &d.SystemInfo.SerialNumber, &d.SystemInfo.Model,
-			&d.SystemInfo.OSVersion, &macosVersion,
-			&d.CPU.Model, &d.CPU.Cores, &d.CPU.Frequency, &cpuTemp,
-			&d.RAM.Total, &d.RAM.Used, &d.RAM.Available, &ramType,
-			&d.Storage.Type, &d.Storage.Capacity, &d.Storage.Used, &d.Storage.Available,
-			&storageHealth, &storageDevice,
-			&d.Battery.CycleCount, &d.Battery.Health, &d.Battery.Capacity,
-			&batteryMaxCapacity, &batteryCondition, &d.Battery.IsCharging, &batteryPowerAdapter,
-			&d.Status, &d.Duration, &d.Timestamp, &d.CreatedAt,
-		)
-
+		var sortValue interface{}
+		d, err := scanDiagnostic(rows, &sortValue)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
+		diagnostics = append(diagnostics, d)
+		sortValues = append(sortValues, sortValue)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
 
-		// Gérer les valeurs NULL
-		if macosVersion.Valid {
-			d.SystemInfo.MacOSVersion = macosVersion.String
-		}
-		if cpuTemp.Valid {
-			d.CPU.Temperature = cpuTemp.String
-		}
-		if ramType.Valid {
-			d.RAM.Type = ramType.String
-		}
-		if storageHealth.Valid {
-			d.Storage.Health = storageHealth.String
-		}
-		if storageDevice.Valid {
-			d.Storage.DeviceName = storageDevice.String
-		}
-		if batteryMaxCapacity.Valid {
-			d.Battery.MaxCapacity = batteryMaxCapacity.String
-		}
-		if batteryCondition.Valid {
-			d.Battery.Condition = batteryCondition.String
+	nextCursor := ""
+	if len(diagnostics) > limit {
+		last := limit - 1
+		nextCursor = encodeCursor(cursor{
+			Sort:  sort.String(),
+			Value: sortValues[last],
+			ID:    diagnostics[last].ID,
+		})
+		diagnostics = diagnostics[:limit]
+	}
+
+	if err := attachTests(ctx, s.db, diagnostics); err != nil {
+		return nil, "", err
+	}
+
+	return diagnostics, nextCursor, nil
+}
+
+// listQuery construit la requête de liste (filtres, curseur, tri) sans LIMIT.
+// Les colonnes sélectionnées sont diagnosticColumns suivies de la valeur de tri.
+func listQuery(opts ListOptions, sort Sort) (string, []interface{}, error) {
+	where, args := opts.Filter.whereClause()
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor, sort)
+		if err != nil {
+			return "", nil, err
 		}
-		if batteryPowerAdapter.Valid {
-			d.Battery.PowerAdapter = batteryPowerAdapter.String
+		cond, cursorArgs := keysetCondition(sort, c)
+		if where != "" {
+			where += " AND "
 		}
-
-		diagnostics = append(diagnostics, d)
+		where += cond
+		args = append(args, cursorArgs...)
 	}
 
-	return diagnostics, nil
+	query := `SELECT ` + diagnosticColumns + `, ` + sortValueExpr(sort) + `
+	FROM diagnostics`
+	if where != "" {
+		query += "\n\tWHERE " + where
+	}
+	query += "\n\tORDER BY " + orderClause(sort)
+	return query, args, nil
 }
 
 // GetDiagnosticByID récupère un diagnostic par son ID
-func GetDiagnosticByID(id int64) (*models.Diagnostic, error) {
-	query := `
-	SELECT 
-		id, machine_name, serial_number, model, os_version, macos_version,
-		cpu_model, cpu_cores, cpu_frequency, cpu_temperature,
-		ram_total, ram_used, ram_available, ram_type,
-		storage_type, storage_capacity, storage_used, storage_available, storage_health, storage_device_name,
-		battery_cycle_count, battery_health, battery_capacity, battery_max_capacity, 
-		battery_condition, battery_is_charging, battery_power_adapter,
-		status, duration, timestamp, created_at
+func (s *SQLiteStore) GetDiagnosticByID(ctx context.Context, id int64) (*models.Diagnostic, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "get_diagnostic")
+
+	return getDiagnostic(ctx, s.db, id)
+}
+
+// getDiagnostic lit un diagnostic et ses étapes avec q (base ou transaction)
+func getDiagnostic(ctx context.Context, q queryer, id int64) (*models.Diagnostic, error) {
+	query := `SELECT ` + diagnosticColumns + `
 	FROM diagnostics
 	WHERE id = ?
 	`
 
-	var d models.Diagnostic
-	var macosVersion, cpuTemp, ramType, storageHealth, storageDevice sql.NullString
-	var batteryMaxCapacity, batteryCondition, batteryPowerAdapter sql.NullString
-
-	err := DB.QueryRow(query, id).Scan(
-		&d.ID, &d.SystemInfo.MachineName, &d.SystemInfo.SerialNumber, &d.SystemInfo.Model,
-		&d.SystemInfo.OSVersion, &macosVersion,
-		&d.CPU.Model, &d.CPU.Cores, &d.CPU.Frequency, &cpuTemp,
-		&d.RAM.Total, &d.RAM.Used, &d.RAM.Available, &ramType,
-		&d.Storage.Type, &d.Storage.Capacity, &d.Storage.Used, &d.Storage.Available,
-		&storageHealth, &storageDevice,
-		&d.Battery.CycleCount, &d.Battery.Health, &d.Battery.Capacity,
-		&batteryMaxCapacity, &batteryCondition, &d.Battery.IsCharging, &batteryPowerAdapter,
-		&d.Status, &d.Duration, &d.Timestamp, &d.CreatedAt,
-	)
-
+	d, err := scanDiagnostic(q.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("diagnostic non trouvé")
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Gérer les valeurs NULL
-	if macosVersion.Valid {
-		d.SystemInfo.MacOSVersion = macosVersion.String
-	}
-	if cpuTemp.Valid {
-		d.CPU.Temperature = cpuTemp.String
-	}
-	if ramType.Valid {
-		d.RAM.Type = ramType.String
-	}
-	if storageHealth.Valid {
-		d.Storage.Health = storageHealth.String
-	}
-	if storageDevice.Valid {
-		d.Storage.DeviceName = storageDevice.String
-	}
-	if batteryMaxCapacity.Valid {
-		d.Battery.MaxCapacity = batteryMaxCapacity.String
-	}
-	if batteryCondition.Valid {
-		d.Battery.Condition = batteryCondition.String
-	}
-	if batteryPowerAdapter.Valid {
-		d.Battery.PowerAdapter = batteryPowerAdapter.String
+	diagnostics := []models.Diagnostic{d}
+	if err := attachTests(ctx, q, diagnostics); err != nil {
+		return nil, err
 	}
 
-	return &d, nil
+	return &diagnostics[0], nil
 }
 
 // GetDiagnosticsBySerialNumber récupère tous les diagnostics d'une machine
-func GetDiagnosticsBySerialNumber(serialNumber string) ([]models.Diagnostic, error) {
-	query := `
-	SELECT 
-		id, machine_name, serial_number, model, os_version, macos_version,
-		cpu_model, cpu_cores, cpu_frequency, cpu_temperature,
-		ram_total, ram_used, ram_available, ram_type,
-		storage_type, storage_capacity, storage_used, storage_available, storage_health, storage_device_name,
-		battery_cycle_count, battery_health, battery_capacity, battery_max_capacity, 
-		battery_condition, battery_is_charging, battery_power_adapter,
-		status, duration, timestamp, created_at
+func (s *SQLiteStore) GetDiagnosticsBySerialNumber(ctx context.Context, serialNumber string) ([]models.Diagnostic, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "get_diagnostics_by_serial")
+
+	query := `SELECT ` + diagnosticColumns + `
 	FROM diagnostics
 	WHERE serial_number = ?
 	ORDER BY created_at DESC
 	`
 
-	rows, err := DB.Query(query, serialNumber)
+	return s.queryDiagnostics(ctx, query, serialNumber)
+}
+
+// DeleteDiagnostic supprime un diagnostic, ses étapes de test et ses clés
+// d'idempotence, puis recalcule l'entrée du registre des machines
+func (s *SQLiteStore) DeleteDiagnostic(ctx context.Context, id int64) error {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "delete_diagnostic")
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
-
-	var diagnostics []models.Diagnostic
+	defer tx.Rollback() // sans effet si Commit a réussi
 
-	for rows.Next() {
-		var d models.Diagnostic
-		var macosVersion, cpuTemp, ramType, storageHealth, storageDevice sql.NullString
-		var batteryMaxCapacity, batteryCondition, batteryPowerAdapter sql.NullString
-
-		err := rows.Scan(
-			&d.ID, &d.SystemInfo.MachineName, &d.SystemInfo.SerialNumber, &d.SystemInfo.Model,
-			&d.SystemInfo.OSVersion, &macosVersion,
-			&d.CPU.Model, &d.CPU.Cores, &d.CPU.Frequency, &cpuTemp,
-			&d.RAM.Total, &d.RAM.Used, &d.RAM.Available, &ramType,
-			&d.Storage.Type, &d.Storage.Capacity, &d.Storage.Used, &d.Storage.Available,
-			&storageHealth, &storageDevice,
-			&d.Battery.CycleCount, &d.Battery.Health, &d.Battery.Capacity,
-			&batteryMaxCapacity, &batteryCondition, &d.Battery.IsCharging, &batteryPowerAdapter,
-			&d.Status, &d.Duration, &d.Timestamp, &d.CreatedAt,
-		)
+	var serialNumber string
+	err = tx.QueryRowContext(ctx, "SELECT serial_number FROM diagnostics WHERE id = ?", id).Scan(&serialNumber)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	// Conserver le maillon pour que la chaîne reste vérifiable
+	if err := buryDiagnostics(ctx, tx, tombstoneDeleted, "?", id); err != nil {
+		return err
+	}
 
-		// Gérer les valeurs NULL
-		if macosVersion.Valid {
-			d.SystemInfo.MacOSVersion = macosVersion.String
-		}
-		if cpuTemp.Valid {
-			d.CPU.Temperature = cpuTemp.String
-		}
-		if ramType.Valid {
-			d.RAM.Type = ramType.String
-		}
-		if storageHealth.Valid {
-			d.Storage.Health = storageHealth.String
-		}
-		if storageDevice.Valid {
-			d.Storage.DeviceName = storageDevice.String
-		}
-		if batteryMaxCapacity.Valid {
-			d.Battery.MaxCapacity = batteryMaxCapacity.String
-		}
-		if batteryCondition.Valid {
-			d.Battery.Condition = batteryCondition.String
-		}
-		if batteryPowerAdapter.Valid {
-			d.Battery.PowerAdapter = batteryPowerAdapter.String
+	// Supprimer d'abord les lignes qui référencent le diagnostic ; le dernier
+	// diagnostic de la machine est recalculé ensuite par refreshMachine
+	for _, query := range []string{
+		"DELETE FROM diagnostic_tests WHERE diagnostic_id = ?",
+		"DELETE FROM idempotency_keys WHERE diagnostic_id = ?",
+		"UPDATE machines SET latest_diagnostic_id = NULL WHERE latest_diagnostic_id = ?",
+		"DELETE FROM diagnostics WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
 		}
+	}
 
-		diagnostics = append(diagnostics, d)
+	if err := refreshMachine(ctx, tx, serialNumber); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM machines WHERE serial_number = ? AND run_count = 0", serialNumber); err != nil {
+		return err
 	}
 
-	return diagnostics, nil
+	return tx.Commit()
+}
+
+// Totals compte les diagnostics et les machines du registre
+func (s *SQLiteStore) Totals(ctx context.Context) (int, int, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "totals")
+
+	var diagnostics, machines int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT (SELECT COUNT(*) FROM diagnostics), (SELECT COUNT(*) FROM machines)",
+	).Scan(&diagnostics, &machines)
+	return diagnostics, machines, err
 }
 
 // GetStatistics récupère des statistiques générales
-func GetStatistics() (map[string]interface{}, error) {
+func (s *SQLiteStore) GetStatistics(ctx context.Context) (map[string]interface{}, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "get_statistics")
+
 	stats := make(map[string]interface{})
 
 	// Nombre total de diagnostics
 	var total int
-	err := DB.QueryRow("SELECT COUNT(*) FROM diagnostics").Scan(&total)
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM diagnostics").Scan(&total)
 	if err != nil {
 		return nil, err
 	}
@@ -379,14 +636,14 @@ func GetStatistics() (map[string]interface{}, error) {
 
 	// Nombre de machines uniques
 	var uniqueMachines int
-	err = DB.QueryRow("SELECT COUNT(DISTINCT serial_number) FROM diagnostics").Scan(&uniqueMachines)
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM machines").Scan(&uniqueMachines)
 	if err != nil {
 		return nil, err
 	}
 	stats["unique_machines"] = uniqueMachines
 
 	// Répartition par statut
-	rows, err := DB.Query("SELECT status, COUNT(*) as count FROM diagnostics GROUP BY status")
+	rows, err := s.db.QueryContext(ctx, "SELECT status, COUNT(*) as count FROM diagnostics GROUP BY status")
 	if err != nil {
 		return nil, err
 	}
@@ -403,26 +660,57 @@ func GetStatistics() (map[string]interface{}, error) {
 	}
 	stats["status_distribution"] = statusCounts
 
-	// Dernier diagnostic
+	// Dernier diagnostic (MAX() perd le type DATETIME, d'où ORDER BY ... LIMIT 1)
 	var lastDiag sql.NullTime
-	err = DB.QueryRow("SELECT MAX(created_at) FROM diagnostics").Scan(&lastDiag)
-	if err != nil {
+	err = s.db.QueryRowContext(ctx, "SELECT created_at FROM diagnostics ORDER BY created_at DESC LIMIT 1").Scan(&lastDiag)
+	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
 	if lastDiag.Valid {
 		stats["last_diagnostic"] = lastDiag.Time
 	}
 
-	statsJSON, _ := json.MarshalIndent(stats, "", "  ")
-	log.Printf("📊 Statistiques: %s", string(statsJSON))
+	// Dernier maillon de la chaîne de hachage
+	head, err := chainHead(ctx, s.db, math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+	if head != nil {
+		stats["chain_head"] = head
+	}
+
+	logging.FromContext(ctx).Debug("Statistiques calculées",
+		"total_diagnostics", total, "unique_machines", uniqueMachines)
 
 	return stats, nil
 }
 
-// CloseDB ferme la connexion à la base de données
-func CloseDB() error {
-	if DB != nil {
-		return DB.Close()
+// Ping vérifie que la base répond et que son fichier est lisible
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	var n int
+	return s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master").Scan(&n)
+}
+
+// SchemaVersion retourne la dernière migration appliquée
+func (s *SQLiteStore) SchemaVersion(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	err := s.db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_version").Scan(&version)
+	return int(version.Int64), err
+}
+
+// Close reporte le WAL dans le fichier principal puis ferme la base
+func (s *SQLiteStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+
+	if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		slog.Warn("Checkpoint du WAL impossible", "error", err)
+	}
+	if err := s.db.Close(); err != nil {
+		return err
 	}
+
+	slog.Info("Base de données fermée")
 	return nil
 }
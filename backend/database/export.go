@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"diagnostic-backend/metrics"
+	"diagnostic-backend/models"
+)
+
+// ExportDiagnostics parcourt les diagnostics filtrés dans l'ordre du tri et
+// appelle fn pour chacun, au fil du curseur SQL, sans charger la liste en
+// mémoire. opts.Limit est ici un nombre maximal de lignes (0 = toutes) ; les
+// étapes de test ne sont pas chargées. Une erreur de fn arrête le parcours.
+func (s *SQLiteStore) ExportDiagnostics(ctx context.Context, opts ListOptions, fn func(models.Diagnostic) error) error {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "export_diagnostics")
+
+	sort := opts.Sort
+	if sort.Field == "" {
+		sort = DefaultSort
+	}
+
+	query, args, err := listQuery(opts, sort)
+	if err != nil {
+		return err
+	}
+	if opts.Limit > 0 {
+		query += "\n\tLIMIT ?"
+		args = append(args, opts.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sortValue interface{}
+		d, err := scanDiagnostic(rows, &sortValue)
+		if err != nil {
+			return err
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
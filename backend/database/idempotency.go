@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"diagnostic-backend/logging"
+	"diagnostic-backend/metrics"
+	"diagnostic-backend/models"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrDuplicateRunID est retournée quand un diagnostic avec le même run_id
+// existe déjà, envoyé par une autre station ou un autre client
+var ErrDuplicateRunID = errors.New("run_id déjà enregistré")
+
+// IdempotencyRecord est la réponse mémorisée pour une clé d'idempotence
+type IdempotencyRecord struct {
+	Scope        string // envoyeur propriétaire de la clé (voir submitterScope)
+	Key          string
+	RequestHash  string // SHA-256 du body original
+	DiagnosticID int64
+	StatusCode   int
+	Body         []byte
+	CreatedAt    time.Time
+}
+
+// InsertResult est le résultat de l'insertion d'un élément d'un lot
+type InsertResult struct {
+	ID        int64
+	Duplicate bool // run_id déjà connu : rien n'a été inséré, ID est l'existant
+}
+
+// ResponseRenderer construit la réponse HTTP (code, body) à mémoriser
+// une fois l'ID du diagnostic connu
+type ResponseRenderer func(id int64) (int, []byte)
+
+// isUniqueViolation indique si err est une violation de contrainte d'unicité
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
+}
+
+// submitterScope identifie l'envoyeur d'un diagnostic, propriétaire de ses
+// clés d'idempotence et de son run_id : la station et la clé d'API qui l'ont
+// envoyé, ou, pour un envoi anonyme, la machine diagnostiquée. Deux postes
+// anonymes qui choisissent la même clé pour deux machines restent distincts.
+func submitterScope(stationID, apiKeyID *int64, serialNumber string) string {
+	if stationID == nil && apiKeyID == nil {
+		return "anonymous:" + serialNumber
+	}
+	scopeID := func(id *int64) string {
+		if id == nil {
+			return "-"
+		}
+		return strconv.FormatInt(*id, 10)
+	}
+	return "station:" + scopeID(stationID) + "/key:" + scopeID(apiKeyID)
+}
+
+// diagnosticScope retourne la portée de l'envoyeur d'un diagnostic reçu
+func diagnosticScope(diag models.DiagnosticRequest) string {
+	var stationID *int64
+	if diag.Signature != nil {
+		stationID = &diag.Signature.StationID
+	}
+	return submitterScope(stationID, diag.APIKeyID, diag.SystemInfo.SerialNumber)
+}
+
+// CreateDiagnosticIdempotent insère le diagnostic et mémorise la réponse sous
+// la clé de son envoyeur, dans une seule transaction. Si l'envoyeur a déjà
+// utilisé la clé, rien n'est inséré et l'enregistrement existant est retourné
+// avec replayed = true ; la même clé d'un autre envoyeur est indépendante.
+// Un run_id déjà enregistré par le même envoyeur sans cette clé (lot, import)
+// est aussi rejoué : la réponse 201 est construite à partir de la ligne existante.
+func (s *SQLiteStore) CreateDiagnosticIdempotent(ctx context.Context, diag models.DiagnosticRequest, key, requestHash string, render ResponseRenderer) (*IdempotencyRecord, bool, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "create_diagnostic_idempotent")
+
+	scope := diagnosticScope(diag)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback() // sans effet si Commit a réussi
+
+	// Réserver la clé en premier : la clé primaire sérialise les retries concurrents
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO idempotency_keys (scope, key, request_hash, created_at) VALUES (?, ?, ?, ?)",
+		scope, key, requestHash, now,
+	)
+	if isUniqueViolation(err) {
+		tx.Rollback()
+		existing, err := s.getIdempotencyRecord(ctx, scope, key)
+		if err != nil {
+			return nil, false, err
+		}
+		return existing, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	replayed := false
+	id, err := insertDiagnostic(ctx, tx, diag)
+	if isUniqueViolation(err) && diag.RunID != "" {
+		// run_id déjà enregistré sans cette clé (envoi en lot, retry avec une
+		// autre clé) : la création est rejouée si l'envoyeur est le même
+		id, err = existingRunForSubmitter(ctx, tx, diag)
+		replayed = true
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	statusCode, body := render(id)
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE idempotency_keys SET diagnostic_id = ?, status_code = ?, response_body = ? WHERE scope = ? AND key = ?",
+		id, statusCode, body, scope, key,
+	); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	if !replayed {
+		logging.FromContext(ctx).Debug("Diagnostic inséré avec clé d'idempotence",
+			"diagnostic_id", id, "serial_number", diag.SystemInfo.SerialNumber)
+	}
+
+	return &IdempotencyRecord{
+		Scope:        scope,
+		Key:          key,
+		RequestHash:  requestHash,
+		DiagnosticID: id,
+		StatusCode:   statusCode,
+		Body:         body,
+		CreatedAt:    now,
+	}, replayed, nil
+}
+
+// existingRunForSubmitter retourne l'ID du diagnostic portant le run_id de
+// diag s'il a été envoyé par le même envoyeur (même portée que les clés
+// d'idempotence, voir submitterScope), ErrDuplicateRunID sinon
+func existingRunForSubmitter(ctx context.Context, tx *sql.Tx, diag models.DiagnosticRequest) (int64, error) {
+	var id int64
+	var stationID, apiKeyID sql.NullInt64
+	var serialNumber string
+	err := tx.QueryRowContext(ctx, "SELECT id, station_id, api_key_id, serial_number FROM diagnostics WHERE run_id = ?",
+		diag.RunID).Scan(&id, &stationID, &apiKeyID, &serialNumber)
+	if err == sql.ErrNoRows {
+		return 0, ErrDuplicateRunID
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if submitterScope(nullInt64Ptr(stationID), nullInt64Ptr(apiKeyID), serialNumber) != diagnosticScope(diag) {
+		return 0, ErrDuplicateRunID
+	}
+	return id, nil
+}
+
+// getIdempotencyRecord lit la réponse mémorisée pour une clé de l'appelant
+func (s *SQLiteStore) getIdempotencyRecord(ctx context.Context, scope, key string) (*IdempotencyRecord, error) {
+	var rec IdempotencyRecord
+	var diagnosticID, statusCode sql.NullInt64
+
+	err := s.db.QueryRowContext(ctx, `
+	SELECT scope, key, request_hash, diagnostic_id, status_code, response_body, created_at
+	FROM idempotency_keys
+	WHERE scope = ? AND key = ?`, scope, key).Scan(
+		&rec.Scope, &rec.Key, &rec.RequestHash, &diagnosticID, &statusCode, &rec.Body, &rec.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !statusCode.Valid {
+		// Ne devrait pas arriver : clé et réponse sont écrites dans la même transaction
+		return nil, fmt.Errorf("clé d'idempotence %q sans réponse enregistrée", key)
+	}
+
+	rec.DiagnosticID = diagnosticID.Int64
+	rec.StatusCode = int(statusCode.Int64)
+	return &rec, nil
+}
+
+// findDiagnosticIDByRunID retourne l'ID du diagnostic portant ce run_id (0 si aucun)
+func findDiagnosticIDByRunID(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, runID string) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx, "SELECT id FROM diagnostics WHERE run_id = ?", runID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+// TestIdempotencyScope vérifie qu'une clé d'idempotence ou un run_id n'est
+// rejoué que pour son envoyeur : deux postes anonymes qui choisissent la même
+// clé pour deux machines enregistrent chacun leur diagnostic
+func TestIdempotencyScope(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	apiKey, _, err := store.CreateAPIKey(ctx, "station-1", []string{models.ScopeIngest}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := apiKey.ID
+
+	render := func(id int64) (int, []byte) { return 201, []byte("{}") }
+	submit := func(serial, runID string, apiKeyID *int64, key string) (*IdempotencyRecord, bool, error) {
+		diag := testDiagnostic(t, serial, now)
+		diag.RunID = runID
+		diag.APIKeyID = apiKeyID
+		return store.CreateDiagnosticIdempotent(ctx, diag, key, "hash-"+serial, render)
+	}
+
+	first, replayed, err := submit("C02FIRST", "", nil, "cle-1")
+	if err != nil || replayed {
+		t.Fatalf("premier envoi : replayed = %v, err = %v", replayed, err)
+	}
+
+	tests := []struct {
+		name     string
+		serial   string
+		runID    string
+		apiKeyID *int64
+		key      string
+		replayed bool
+		err      error
+	}{
+		{"même machine, même clé", "C02FIRST", "", nil, "cle-1", true, nil},
+		{"autre machine anonyme, même clé", "C02OTHER", "", nil, "cle-1", false, nil},
+		{"clé d'API, même clé", "C02FIRST", "", &keyID, "cle-1", false, nil},
+		{"run_id, premier envoi", "C02RUN", "run-1", nil, "run:run-1", false, nil},
+		{"run_id renvoyé avec une autre clé", "C02RUN", "run-1", nil, "cle-2", true, nil},
+		{"run_id d'une autre machine", "C02AUTRE", "run-1", nil, "run:run-1", false, ErrDuplicateRunID},
+		{"run_id d'un autre envoyeur", "C02RUN", "run-1", &keyID, "run:run-1", false, ErrDuplicateRunID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, replayed, err := submit(tt.serial, tt.runID, tt.apiKeyID, tt.key)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("erreur %v, attendue %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if replayed != tt.replayed {
+				t.Errorf("replayed = %v, attendu %v", replayed, tt.replayed)
+			}
+			if tt.serial == "C02FIRST" && tt.apiKeyID == nil && rec.DiagnosticID != first.DiagnosticID {
+				t.Errorf("diagnostic %d rejoué, attendu %d", rec.DiagnosticID, first.DiagnosticID)
+			}
+			if !tt.replayed && rec.DiagnosticID == first.DiagnosticID {
+				t.Errorf("diagnostic %d d'un autre envoyeur rejoué", rec.DiagnosticID)
+			}
+		})
+	}
+}
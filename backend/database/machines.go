@@ -0,0 +1,259 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"diagnostic-backend/metrics"
+	"diagnostic-backend/models"
+)
+
+// upsertMachine met à jour le registre des machines pour un diagnostic inséré.
+// Le profil matériel n'est remplacé que si le diagnostic est plus récent que
+// le dernier connu (un diagnostic hors ligne peut arriver en retard).
+func upsertMachine(ctx context.Context, tx *sql.Tx, diagnosticID int64, diag models.DiagnosticRequest, timestamp time.Time) error {
+	var firstSeen, lastSeen time.Time
+	err := tx.QueryRowContext(ctx, "SELECT first_seen, last_seen FROM machines WHERE serial_number = ?",
+		diag.SystemInfo.SerialNumber).Scan(&firstSeen, &lastSeen)
+
+	if err == sql.ErrNoRows {
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO machines (
+			serial_number, machine_name, model, os_version, macos_version,
+			cpu_model, cpu_cores, ram_total, ram_total_bytes,
+			storage_type, storage_capacity, storage_capacity_bytes,
+			first_seen, last_seen, run_count, latest_diagnostic_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?)`,
+			diag.SystemInfo.SerialNumber, diag.SystemInfo.MachineName, diag.SystemInfo.Model,
+			diag.SystemInfo.OSVersion, nullIfEmpty(diag.SystemInfo.MacOSVersion),
+			diag.CPU.Model, diag.CPU.Cores, diag.RAM.Total, diag.RAM.TotalBytes,
+			diag.Storage.Type, diag.Storage.Capacity, diag.Storage.CapacityBytes,
+			timestamp, timestamp, diagnosticID,
+		)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if timestamp.Before(firstSeen) {
+		firstSeen = timestamp
+	}
+
+	// Diagnostic plus ancien que le dernier connu : seuls les compteurs changent
+	if timestamp.Before(lastSeen) {
+		_, err = tx.ExecContext(ctx,
+			"UPDATE machines SET run_count = run_count + 1, first_seen = ? WHERE serial_number = ?",
+			firstSeen, diag.SystemInfo.SerialNumber,
+		)
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE machines SET
+		machine_name = ?, model = ?, os_version = ?, macos_version = ?,
+		cpu_model = ?, cpu_cores = ?, ram_total = ?, ram_total_bytes = ?,
+		storage_type = ?, storage_capacity = ?, storage_capacity_bytes = ?,
+		first_seen = ?, last_seen = ?, run_count = run_count + 1, latest_diagnostic_id = ?
+	WHERE serial_number = ?`,
+		diag.SystemInfo.MachineName, diag.SystemInfo.Model,
+		diag.SystemInfo.OSVersion, nullIfEmpty(diag.SystemInfo.MacOSVersion),
+		diag.CPU.Model, diag.CPU.Cores, diag.RAM.Total, diag.RAM.TotalBytes,
+		diag.Storage.Type, diag.Storage.Capacity, diag.Storage.CapacityBytes,
+		firstSeen, timestamp, diagnosticID,
+		diag.SystemInfo.SerialNumber,
+	)
+	return err
+}
+
+// refreshMachine recalcule les compteurs et le dernier diagnostic d'une
+// machine à partir de ses diagnostics restants, après une suppression ou une
+// purge. Sans diagnostic restant, first_seen et last_seen sont conservés.
+func refreshMachine(ctx context.Context, tx *sql.Tx, serialNumber string) error {
+	// Les dates sont comparées par julianday : les timestamps gardent le
+	// fuseau du client, et leur ordre textuel n'est pas chronologique
+	const latest = `SELECT %s FROM diagnostics WHERE serial_number = m.serial_number
+		ORDER BY julianday(timestamp) DESC, id DESC LIMIT 1`
+	const earliest = `SELECT timestamp FROM diagnostics WHERE serial_number = m.serial_number
+		ORDER BY julianday(timestamp), id LIMIT 1`
+	_, err := tx.ExecContext(ctx, `
+	UPDATE machines AS m SET
+		run_count = (SELECT COUNT(*) FROM diagnostics WHERE serial_number = m.serial_number),
+		first_seen = COALESCE((`+earliest+`), first_seen),
+		last_seen = COALESCE((`+fmt.Sprintf(latest, "timestamp")+`), last_seen),
+		latest_diagnostic_id = (`+fmt.Sprintf(latest, "id")+`)
+	WHERE serial_number = ?`, serialNumber)
+	return err
+}
+
+// machineColumns liste les colonnes lues par scanMachine, dans l'ordre
+// (m = machines, d = dernier diagnostic en LEFT JOIN)
+const machineColumns = `
+		m.serial_number, m.machine_name, m.model, m.os_version, m.macos_version,
+		m.cpu_model, m.cpu_cores, m.ram_total, m.ram_total_bytes,
+		m.storage_type, m.storage_capacity, m.storage_capacity_bytes,
+		m.first_seen, m.last_seen, m.run_count, CAST(m.last_seen AS TEXT),
+		d.id, d.status, d.timestamp, d.duration,
+		d.battery_health, d.battery_cycle_count, d.battery_max_capacity_percent`
+
+// machineFrom joint chaque machine à son dernier diagnostic
+const machineFrom = `
+	FROM machines m
+	LEFT JOIN diagnostics d ON d.id = m.latest_diagnostic_id`
+
+// scanMachine lit une ligne sélectionnée avec machineColumns.
+// Retourne aussi la valeur brute de last_seen pour le curseur de pagination.
+func scanMachine(row rowScanner) (models.Machine, string, error) {
+	var m models.Machine
+	var macosVersion sql.NullString
+	var ramTotalBytes, storageCapacityBytes sql.NullInt64
+	var lastSeenRaw string
+
+	var diagID, cycleCount sql.NullInt64
+	var status, batteryHealth sql.NullString
+	var timestamp sql.NullTime
+	var duration, maxCapacity sql.NullFloat64
+
+	err := row.Scan(
+		&m.SerialNumber, &m.MachineName, &m.Model, &m.OSVersion, &macosVersion,
+		&m.CPUModel, &m.CPUCores, &m.RAMTotal, &ramTotalBytes,
+		&m.StorageType, &m.StorageCapacity, &storageCapacityBytes,
+		&m.FirstSeen, &m.LastSeen, &m.RunCount, &lastSeenRaw,
+		&diagID, &status, &timestamp, &duration,
+		&batteryHealth, &cycleCount, &maxCapacity,
+	)
+	if err != nil {
+		return m, "", err
+	}
+
+	m.MacOSVersion = macosVersion.String
+	m.RAMTotalBytes = nullInt64Ptr(ramTotalBytes)
+	m.StorageCapacityBytes = nullInt64Ptr(storageCapacityBytes)
+
+	if diagID.Valid {
+		m.LatestDiagnostic = &models.DiagnosticSummary{
+			ID:                        diagID.Int64,
+			Status:                    status.String,
+			Timestamp:                 timestamp.Time,
+			Duration:                  duration.Float64,
+			BatteryHealth:             batteryHealth.String,
+			BatteryCycleCount:         int(cycleCount.Int64),
+			BatteryMaxCapacityPercent: nullFloat64Ptr(maxCapacity),
+		}
+	}
+
+	return m, lastSeenRaw, nil
+}
+
+// machineCursor est le contenu du curseur de pagination des machines
+type machineCursor struct {
+	LastSeen string `json:"ls"`
+	Serial   string `json:"sn"`
+}
+
+// ListMachines retourne les machines vues le plus récemment en premier
+func (s *SQLiteStore) ListMachines(ctx context.Context, limit int, cursorStr string) ([]models.Machine, string, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "list_machines")
+
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	query := `SELECT ` + machineColumns + machineFrom
+	var args []interface{}
+
+	if cursorStr != "" {
+		var c machineCursor
+		data, err := base64.RawURLEncoding.DecodeString(cursorStr)
+		if err != nil || json.Unmarshal(data, &c) != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		// last_seen garde le fuseau du diagnostic (et des fuseaux mélangés
+		// depuis la reprise de la migration 0006) : comparaison chronologique par julianday
+		query += "\n\tWHERE (julianday(m.last_seen) < julianday(?) OR (julianday(m.last_seen) = julianday(?) AND m.serial_number < ?))"
+		args = append(args, c.LastSeen, c.LastSeen, c.Serial)
+	}
+
+	// Une ligne de plus que demandé indique s'il existe une page suivante
+	query += "\n\tORDER BY julianday(m.last_seen) DESC, m.serial_number DESC\n\tLIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var machines []models.Machine
+	var lastSeenValues []string
+
+	for rows.Next() {
+		m, lastSeenRaw, err := scanMachine(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		machines = append(machines, m)
+		lastSeenValues = append(lastSeenValues, lastSeenRaw)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(machines) > limit {
+		last := limit - 1
+		data, _ := json.Marshal(machineCursor{LastSeen: lastSeenValues[last], Serial: machines[last].SerialNumber})
+		nextCursor = base64.RawURLEncoding.EncodeToString(data)
+		machines = machines[:limit]
+	}
+
+	return machines, nextCursor, nil
+}
+
+// GetMachine retourne une machine et le résumé de son dernier diagnostic
+func (s *SQLiteStore) GetMachine(ctx context.Context, serialNumber string) (*models.Machine, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "get_machine")
+
+	query := `SELECT ` + machineColumns + machineFrom + `
+	WHERE m.serial_number = ?`
+
+	m, _, err := scanMachine(s.db.QueryRowContext(ctx, query, serialNumber))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Étapes échouées du dernier diagnostic
+	if m.LatestDiagnostic != nil {
+		rows, err := s.db.QueryContext(ctx, `
+		SELECT name FROM diagnostic_tests
+		WHERE diagnostic_id = ? AND outcome = ?
+		ORDER BY position`, m.LatestDiagnostic.ID, models.TestOutcomeFailed)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return nil, err
+			}
+			m.LatestDiagnostic.FailedTests = append(m.LatestDiagnostic.FailedTests, name)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &m, nil
+}
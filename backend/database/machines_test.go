@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestListMachinesMixedOffsets vérifie l'ordre et la pagination des machines
+// quand last_seen mélange les fuseaux (reprise de la migration 0006) :
+// l'ordre textuel n'est pas chronologique
+func TestListMachinesMixedOffsets(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	base := time.Now().UTC().Truncate(time.Hour).Add(-24 * time.Hour)
+	for _, d := range []struct {
+		serial string
+		seen   time.Time
+	}{
+		{"C02PARIS", base.Add(time.Hour).In(time.FixedZone("CEST", 2*3600))}, // base+1h, texte le plus grand
+		{"C02UTC", base.Add(2 * time.Hour)},
+		{"C02NEWYORK", base.Add(3 * time.Hour).In(time.FixedZone("EDT", -4*3600))}, // texte le plus petit
+		{"C02TOKYO", base.Add(2 * time.Hour).In(time.FixedZone("JST", 9*3600))},    // même instant que C02UTC
+	} {
+		if _, err := store.CreateDiagnostic(ctx, testDiagnostic(t, d.serial, d.seen)); err != nil {
+			t.Fatal(err)
+		}
+		// Les nouveaux envois sont enregistrés en UTC ; la reprise de la
+		// migration 0006 a conservé le fuseau de chaque diagnostic
+		mustExec(t, store, "UPDATE machines SET last_seen = ? WHERE serial_number = ?",
+			d.seen.Format("2006-01-02 15:04:05-07:00"), d.serial)
+	}
+
+	want := []string{"C02NEWYORK", "C02UTC", "C02TOKYO", "C02PARIS"}
+	var got []string
+	cursor := ""
+	for page := 0; page <= len(want); page++ {
+		machines, next, err := store.ListMachines(ctx, 1, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range machines {
+			got = append(got, m.SerialNumber)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("machines %v, attendu %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("machines %v, attendu %v", got, want)
+		}
+	}
+}
+
+// TestDeleteLatestDiagnostic vérifie, avec les clés étrangères activées, la
+// suppression du dernier diagnostic d'une machine puis de son seul diagnostic
+func TestDeleteLatestDiagnostic(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "diagnostics.db"), map[string]string{"foreign_keys": "on"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	older, err := store.CreateDiagnostic(ctx, testDiagnostic(t, "C02DELETE", now.Add(-time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := store.CreateDiagnostic(ctx, testDiagnostic(t, "C02DELETE", now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.DeleteDiagnostic(ctx, latest); err != nil {
+		t.Fatalf("suppression du dernier diagnostic : %v", err)
+	}
+	m, err := store.GetMachine(ctx, "C02DELETE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.RunCount != 1 || m.LatestDiagnostic == nil || m.LatestDiagnostic.ID != older {
+		t.Errorf("run_count = %d, dernier diagnostic %+v ; attendu 1 et %d", m.RunCount, m.LatestDiagnostic, older)
+	}
+
+	if err := store.DeleteDiagnostic(ctx, older); err != nil {
+		t.Fatalf("suppression du seul diagnostic : %v", err)
+	}
+	if _, err := store.GetMachine(ctx, "C02DELETE"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("machine sans diagnostic : erreur %v, attendue %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Les migrations sont des fichiers SQL numérotés embarqués dans le binaire :
+// migrations/0001_nom.sql, migrations/0002_autre.sql, ...
+// Une migration appliquée ne doit jamais être modifiée : on en ajoute une nouvelle.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationHooks associe à une version une étape Go exécutée après le SQL,
+// dans la même transaction (ex: conversion de données impossible en SQL pur)
+var migrationHooks = map[int]func(tx *sql.Tx) error{
+	2:  backfillNumericCapacities,
+	10: backfillHashChain,
+}
+
+// Migration représente une migration "up" numérotée
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationState représente l'état d'une migration dans une base donnée
+type MigrationState struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// loadMigrations lit et trie les migrations embarquées
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, ".sql") {
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, ".sql")
+		numPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("nom de migration invalide: %s (attendu: 0001_nom.sql)", fileName)
+		}
+		version, err := strconv.Atoi(numPart)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("numéro de migration invalide: %s", fileName)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s et %s ont le même numéro", other, fileName)
+		}
+		seen[version] = fileName
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// LatestSchemaVersion retourne le numéro de la dernière migration connue du binaire
+func LatestSchemaVersion() int {
+	migrations, err := loadMigrations()
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// ensureSchemaVersionTable crée la table de suivi des migrations
+func ensureSchemaVersionTable(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`)
+	return err
+}
+
+// appliedMigrations retourne les versions déjà appliquées avec leur date
+func appliedMigrations(db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.Query("SELECT version, applied_at FROM schema_version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// SchemaVersion retourne la version actuelle du schéma (0 si aucune migration)
+func SchemaVersion(db *sql.DB) (int, error) {
+	if err := ensureSchemaVersionTable(db); err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// Migrate applique les migrations manquantes, chacune dans sa propre transaction.
+// Retourne le nombre de migrations appliquées.
+func Migrate(db *sql.DB) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, fmt.Errorf("erreur de chargement des migrations: %v", err)
+	}
+
+	if err := ensureSchemaVersionTable(db); err != nil {
+		return 0, fmt.Errorf("erreur de création de schema_version: %v", err)
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, fmt.Errorf("erreur de lecture de schema_version: %v", err)
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, done := applied[m.Version]; done {
+			continue
+		}
+
+		if err := applyMigration(db, m); err != nil {
+			return count, fmt.Errorf("migration %04d_%s échouée: %v", m.Version, m.Name, err)
+		}
+
+		slog.Info("Migration appliquée", "version", m.Version, "name", m.Name)
+		count++
+	}
+
+	return count, nil
+}
+
+// applyMigration exécute une migration et l'enregistre de façon atomique
+func applyMigration(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // sans effet si Commit a réussi
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return err
+	}
+
+	if hook, ok := migrationHooks[m.Version]; ok {
+		if err := hook(tx); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now().UTC(),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetMigrationStatus liste toutes les migrations connues et leur état
+func GetMigrationStatus(db *sql.DB) ([]MigrationState, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureSchemaVersionTable(db); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		states = append(states, MigrationState{
+			Version:   m.Version,
+			Name:      m.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+
+	return states, nil
+}
@@ -0,0 +1,43 @@
+-- Schéma initial : table des diagnostics et index
+CREATE TABLE IF NOT EXISTS diagnostics (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	machine_name TEXT NOT NULL,
+	serial_number TEXT NOT NULL,
+	model TEXT NOT NULL,
+	os_version TEXT NOT NULL,
+	macos_version TEXT,
+
+	cpu_model TEXT NOT NULL,
+	cpu_cores INTEGER NOT NULL,
+	cpu_frequency TEXT NOT NULL,
+	cpu_temperature TEXT,
+
+	ram_total TEXT NOT NULL,
+	ram_used TEXT NOT NULL,
+	ram_available TEXT NOT NULL,
+	ram_type TEXT,
+
+	storage_type TEXT NOT NULL,
+	storage_capacity TEXT NOT NULL,
+	storage_used TEXT NOT NULL,
+	storage_available TEXT NOT NULL,
+	storage_health TEXT,
+	storage_device_name TEXT,
+
+	battery_cycle_count INTEGER NOT NULL,
+	battery_health TEXT NOT NULL,
+	battery_capacity TEXT NOT NULL,
+	battery_max_capacity TEXT,
+	battery_condition TEXT,
+	battery_is_charging BOOLEAN NOT NULL,
+	battery_power_adapter TEXT,
+
+	status TEXT NOT NULL,
+	duration REAL NOT NULL,
+	timestamp DATETIME NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_serial_number ON diagnostics(serial_number);
+CREATE INDEX IF NOT EXISTS idx_created_at ON diagnostics(created_at);
+CREATE INDEX IF NOT EXISTS idx_status ON diagnostics(status);
@@ -0,0 +1,13 @@
+-- Valeurs numériques canoniques à côté des chaînes affichables.
+-- Les lignes existantes sont complétées par backfillNumericCapacities (Go).
+ALTER TABLE diagnostics ADD COLUMN ram_total_bytes INTEGER;
+ALTER TABLE diagnostics ADD COLUMN ram_used_bytes INTEGER;
+ALTER TABLE diagnostics ADD COLUMN ram_available_bytes INTEGER;
+ALTER TABLE diagnostics ADD COLUMN storage_capacity_bytes INTEGER;
+ALTER TABLE diagnostics ADD COLUMN storage_used_bytes INTEGER;
+ALTER TABLE diagnostics ADD COLUMN storage_available_bytes INTEGER;
+ALTER TABLE diagnostics ADD COLUMN battery_capacity_percent REAL;
+ALTER TABLE diagnostics ADD COLUMN battery_max_capacity_percent REAL;
+
+CREATE INDEX IF NOT EXISTS idx_ram_total_bytes ON diagnostics(ram_total_bytes);
+CREATE INDEX IF NOT EXISTS idx_storage_capacity_bytes ON diagnostics(storage_capacity_bytes);
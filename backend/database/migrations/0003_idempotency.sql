@@ -0,0 +1,17 @@
+-- Identifiant de run généré par le client et clés d'idempotence :
+-- un POST rejoué (timeout + retry) ne crée pas de doublon.
+ALTER TABLE diagnostics ADD COLUMN run_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_run_id ON diagnostics(run_id) WHERE run_id IS NOT NULL;
+
+-- Les clés sont propres à chaque envoyeur (voir submitterScope) : une clé ou
+-- un run_id réutilisé par un autre client ne rejoue jamais la réponse d'origine.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	scope TEXT NOT NULL,
+	key TEXT NOT NULL,
+	request_hash TEXT NOT NULL,
+	diagnostic_id INTEGER REFERENCES diagnostics(id),
+	status_code INTEGER,
+	response_body BLOB,
+	created_at DATETIME NOT NULL,
+	PRIMARY KEY (scope, key)
+);
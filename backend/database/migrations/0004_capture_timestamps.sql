@@ -0,0 +1,12 @@
+-- Horodatage client : heure de capture annoncée, heure de réception serveur,
+-- décalage d'horloge mesuré et signalement des horodatages incohérents.
+-- "timestamp" reste l'heure effective du diagnostic.
+ALTER TABLE diagnostics ADD COLUMN captured_at DATETIME;
+ALTER TABLE diagnostics ADD COLUMN received_at DATETIME;
+ALTER TABLE diagnostics ADD COLUMN clock_skew_seconds REAL;
+ALTER TABLE diagnostics ADD COLUMN timestamp_flag TEXT;
+
+-- Avant cette migration, timestamp était l'heure de réception
+UPDATE diagnostics SET received_at = timestamp WHERE received_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_timestamp ON diagnostics(timestamp);
@@ -0,0 +1,14 @@
+-- Résultat de chaque étape de test d'un diagnostic
+CREATE TABLE IF NOT EXISTS diagnostic_tests (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	diagnostic_id INTEGER NOT NULL REFERENCES diagnostics(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	outcome TEXT NOT NULL,
+	measurements TEXT,
+	error_message TEXT,
+	duration REAL NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_diagnostic_tests_diagnostic ON diagnostic_tests(diagnostic_id);
+CREATE INDEX IF NOT EXISTS idx_diagnostic_tests_name_outcome ON diagnostic_tests(name, outcome);
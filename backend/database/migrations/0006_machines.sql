@@ -0,0 +1,46 @@
+-- Registre des machines, mis à jour à chaque diagnostic reçu
+CREATE TABLE IF NOT EXISTS machines (
+	serial_number TEXT PRIMARY KEY,
+	machine_name TEXT NOT NULL,
+	model TEXT NOT NULL,
+	os_version TEXT NOT NULL,
+	macos_version TEXT,
+	cpu_model TEXT NOT NULL,
+	cpu_cores INTEGER NOT NULL,
+	ram_total TEXT NOT NULL,
+	ram_total_bytes INTEGER,
+	storage_type TEXT NOT NULL,
+	storage_capacity TEXT NOT NULL,
+	storage_capacity_bytes INTEGER,
+	first_seen DATETIME NOT NULL,
+	last_seen DATETIME NOT NULL,
+	run_count INTEGER NOT NULL DEFAULT 0,
+	latest_diagnostic_id INTEGER REFERENCES diagnostics(id)
+);
+
+-- La pagination trie last_seen par julianday (fuseaux mélangés)
+CREATE INDEX IF NOT EXISTS idx_machines_last_seen ON machines(julianday(last_seen), serial_number);
+
+-- Reprise des machines déjà diagnostiquées : profil du diagnostic le plus récent
+INSERT OR IGNORE INTO machines (
+	serial_number, machine_name, model, os_version, macos_version,
+	cpu_model, cpu_cores, ram_total, ram_total_bytes,
+	storage_type, storage_capacity, storage_capacity_bytes,
+	first_seen, last_seen, run_count, latest_diagnostic_id
+)
+SELECT
+	d.serial_number, d.machine_name, d.model, d.os_version, d.macos_version,
+	d.cpu_model, d.cpu_cores, d.ram_total, d.ram_total_bytes,
+	d.storage_type, d.storage_capacity, d.storage_capacity_bytes,
+	agg.first_seen, d.timestamp, agg.run_count, d.id
+FROM diagnostics d
+JOIN (
+	SELECT serial_number,
+		MIN(timestamp) AS first_seen,
+		COUNT(*) AS run_count,
+		(SELECT id FROM diagnostics d2
+			WHERE d2.serial_number = d1.serial_number
+			ORDER BY julianday(d2.timestamp) DESC, d2.id DESC LIMIT 1) AS latest_id
+	FROM diagnostics d1
+	GROUP BY serial_number
+) agg ON d.id = agg.latest_id;
@@ -0,0 +1,18 @@
+-- Clés d'API des stations et des outils d'administration.
+-- Seule l'empreinte SHA-256 de la clé est stockée ; le préfixe public
+-- permet de retrouver la ligne sans balayer la table.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL UNIQUE,
+	key_hash TEXT NOT NULL,
+	scopes TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	rotated_at DATETIME,
+	last_used_at DATETIME,
+	revoked_at DATETIME
+);
+
+-- Clé d'API ayant envoyé le diagnostic : un run_id déjà enregistré n'est
+-- rejoué (201 idempotent) que pour le client qui l'a envoyé
+ALTER TABLE diagnostics ADD COLUMN api_key_id INTEGER REFERENCES api_keys(id);
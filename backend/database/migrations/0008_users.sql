@@ -0,0 +1,17 @@
+-- Utilisateurs et rôles (technician, manager, admin).
+-- Une clé d'API peut être rattachée à un utilisateur : ses droits sont
+-- alors ceux du rôle, limités par les portées de la clé.
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	display_name TEXT,
+	role TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	disabled_at DATETIME
+);
+
+ALTER TABLE api_keys ADD COLUMN user_id INTEGER REFERENCES users(id);
+
+-- Auteur de l'envoi (technicien), pour restreindre la lecture à ses propres runs
+ALTER TABLE diagnostics ADD COLUMN submitted_by INTEGER REFERENCES users(id);
+CREATE INDEX IF NOT EXISTS idx_diagnostics_submitted_by ON diagnostics(submitted_by);
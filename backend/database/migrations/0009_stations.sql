@@ -0,0 +1,22 @@
+-- Stations de diagnostic enregistrées : chacune signe ses envois (HMAC-SHA256)
+-- avec un secret partagé. Le secret doit rester lisible pour vérifier la signature.
+CREATE TABLE IF NOT EXISTS stations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	secret TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	last_seen_at DATETIME,
+	revoked_at DATETIME
+);
+
+-- Signatures déjà acceptées, pour refuser un envoi rejoué dans la fenêtre de validité
+CREATE TABLE IF NOT EXISTS used_signatures (
+	signature TEXT PRIMARY KEY,
+	station_id INTEGER NOT NULL REFERENCES stations(id),
+	used_at DATETIME NOT NULL
+);
+
+-- Station ayant signé le diagnostic, et la preuve associée
+ALTER TABLE diagnostics ADD COLUMN station_id INTEGER REFERENCES stations(id);
+ALTER TABLE diagnostics ADD COLUMN signature TEXT;
+ALTER TABLE diagnostics ADD COLUMN signed_at DATETIME;
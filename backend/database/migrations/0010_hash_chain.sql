@@ -0,0 +1,18 @@
+-- Chaîne de hachage : chaque diagnostic stocke le SHA-256 de son contenu
+-- (content_hash) et celui de son maillon (hash), calculé à partir du hash du
+-- maillon précédent (prev_hash) et de content_hash.
+-- Une ligne modifiée, insérée ou supprimée hors de l'API rompt la chaîne.
+ALTER TABLE diagnostics ADD COLUMN prev_hash TEXT;
+ALTER TABLE diagnostics ADD COLUMN content_hash TEXT;
+ALTER TABLE diagnostics ADD COLUMN hash TEXT;
+
+-- Maillons des diagnostics supprimés par l'API (purge, suppression admin) :
+-- le hash du contenu supprimé garde leur maillon vérifiable
+CREATE TABLE IF NOT EXISTS diagnostic_tombstones (
+	id INTEGER PRIMARY KEY,
+	prev_hash TEXT NOT NULL,
+	content_hash TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	deleted_at DATETIME NOT NULL
+);
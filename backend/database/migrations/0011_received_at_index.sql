@@ -0,0 +1,2 @@
+-- Index de la purge, qui compte la rétention depuis la réception
+CREATE INDEX IF NOT EXISTS idx_received_at ON diagnostics(received_at);
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor est retournée quand le curseur de pagination est illisible
+// ou ne correspond pas au tri demandé
+var ErrInvalidCursor = errors.New("curseur de pagination invalide")
+
+const (
+	// DefaultPageSize est la taille de page quand le client ne précise pas de limite
+	DefaultPageSize = 100
+	// MaxPageSize borne la taille d'une page
+	MaxPageSize = 1000
+)
+
+// sortColumns associe les clés de tri exposées par l'API aux colonnes SQL
+var sortColumns = map[string]string{
+	"timestamp":   "timestamp",
+	"created_at":  "created_at",
+	"duration":    "duration",
+	"cycle_count": "battery_cycle_count",
+}
+
+// Sort décrit l'ordre de tri d'une liste de diagnostics
+type Sort struct {
+	Field string // clé de sortColumns
+	Desc  bool
+}
+
+// DefaultSort trie du plus récent au plus ancien (comportement historique)
+var DefaultSort = Sort{Field: "created_at", Desc: true}
+
+// ParseSort interprète "timestamp", "-duration", ... ("-" = décroissant)
+func ParseSort(s string) (Sort, error) {
+	if s == "" {
+		return DefaultSort, nil
+	}
+
+	sort := Sort{Field: s}
+	if strings.HasPrefix(s, "-") {
+		sort = Sort{Field: s[1:], Desc: true}
+	}
+
+	if _, ok := sortColumns[sort.Field]; !ok {
+		return Sort{}, fmt.Errorf("tri inconnu %q (valeurs possibles: timestamp, created_at, duration, cycle_count, préfixées de - pour l'ordre décroissant)", s)
+	}
+	return sort, nil
+}
+
+// String retourne la forme acceptée par ParseSort
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// DiagnosticFilter regroupe les filtres de liste (les champs vides sont ignorés)
+type DiagnosticFilter struct {
+	Statuses       []string   // success, partial, failed
+	Model          string     // modèle de machine (égalité, insensible à la casse)
+	CPUModel       string     // modèle de processeur (égalité, insensible à la casse)
+	From           *time.Time // timestamp >= From
+	To             *time.Time // timestamp < To
+	BatteryHealths []string   // Good, Fair, Poor, ...
+	// BatteryMaxCapacityBelow garde les batteries dont la capacité max (%) est inférieure
+	BatteryMaxCapacityBelow *float64
+	// Tests garde les diagnostics ayant toutes ces étapes (ex: battery échoué)
+	Tests []TestCondition
+	// SubmittedBy garde les diagnostics envoyés par cet utilisateur
+	SubmittedBy *int64
+}
+
+// ListOptions décrit une page de diagnostics à récupérer
+type ListOptions struct {
+	Filter DiagnosticFilter
+	Sort   Sort
+	Cursor string // opaque, retourné par la page précédente
+	Limit  int    // DefaultPageSize si <= 0
+}
+
+// whereClause construit la clause WHERE (sans le mot-clé) et ses arguments
+func (f DiagnosticFilter) whereClause() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	if len(f.Statuses) > 0 {
+		conds = append(conds, "status IN ("+placeholders(len(f.Statuses))+")")
+		for _, s := range f.Statuses {
+			args = append(args, s)
+		}
+	}
+	if f.Model != "" {
+		conds = append(conds, "model = ? COLLATE NOCASE")
+		args = append(args, f.Model)
+	}
+	if f.CPUModel != "" {
+		conds = append(conds, "cpu_model = ? COLLATE NOCASE")
+		args = append(args, f.CPUModel)
+	}
+	// julianday() compare correctement des dates écrites avec des fuseaux différents
+	if f.From != nil {
+		conds = append(conds, "julianday(timestamp) >= julianday(?)")
+		args = append(args, f.From.UTC().Format(time.RFC3339Nano))
+	}
+	if f.To != nil {
+		conds = append(conds, "julianday(timestamp) < julianday(?)")
+		args = append(args, f.To.UTC().Format(time.RFC3339Nano))
+	}
+	if len(f.BatteryHealths) > 0 {
+		conds = append(conds, "battery_health COLLATE NOCASE IN ("+placeholders(len(f.BatteryHealths))+")")
+		for _, h := range f.BatteryHealths {
+			args = append(args, h)
+		}
+	}
+	if f.BatteryMaxCapacityBelow != nil {
+		conds = append(conds, "battery_max_capacity_percent < ?")
+		args = append(args, *f.BatteryMaxCapacityBelow)
+	}
+	if len(f.Tests) > 0 {
+		testConds, testArgs := testConditionsClause(f.Tests)
+		conds = append(conds, testConds...)
+		args = append(args, testArgs...)
+	}
+	if f.SubmittedBy != nil {
+		conds = append(conds, "submitted_by = ?")
+		args = append(args, *f.SubmittedBy)
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// placeholders retourne "?, ?, ?" pour n arguments
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// cursor est le contenu (encodé en base64) du curseur de pagination :
+// la valeur de tri et l'ID de la dernière ligne de la page
+type cursor struct {
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v"`
+	ID    int64       `json:"id"`
+}
+
+// encodeCursor produit le curseur opaque retourné au client
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor vérifie que le curseur a été produit pour le même tri
+func decodeCursor(s string, sort Sort) (cursor, error) {
+	var c cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.Sort != sort.String() {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// keysetCondition retourne la condition "après le curseur" pour le tri donné.
+// L'ID sert à départager les lignes ayant la même valeur de tri.
+func keysetCondition(sort Sort, c cursor) (string, []interface{}) {
+	col := sortColumns[sort.Field]
+	op := ">"
+	if sort.Desc {
+		op = "<"
+	}
+	return fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", col, op, col, op),
+		[]interface{}{c.Value, c.Value, c.ID}
+}
+
+// orderClause retourne la clause ORDER BY (sans le mot-clé)
+func orderClause(sort Sort) string {
+	dir := "ASC"
+	if sort.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("%s %s, id %s", sortColumns[sort.Field], dir, dir)
+}
+
+// sortValueExpr retourne l'expression SQL lue pour construire le curseur.
+// Les dates sont relues sous leur forme texte brute pour être comparées
+// exactement comme elles sont stockées.
+func sortValueExpr(sort Sort) string {
+	col := sortColumns[sort.Field]
+	switch sort.Field {
+	case "timestamp", "created_at":
+		return "CAST(" + col + " AS TEXT)"
+	default:
+		return col
+	}
+}
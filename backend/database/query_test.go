@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+// newTestStore ouvre une base SQLite temporaire, migrée
+func newTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "diagnostics.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// mustExec exécute une requête directement en base, hors de l'API du store
+func mustExec(t *testing.T, s *SQLiteStore, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := s.db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testDiagnostic retourne un envoi valide reçu à receivedAt
+func testDiagnostic(t *testing.T, serial string, receivedAt time.Time) models.DiagnosticRequest {
+	t.Helper()
+	diag := models.DiagnosticRequest{
+		SystemInfo: models.SystemInfo{
+			MachineName:  "MacBook Pro",
+			SerialNumber: serial,
+			Model:        "MacBookPro18,3",
+			OSVersion:    "macOS 14.4.1",
+		},
+		CPU:     models.CPUInfo{Model: "Apple M1 Pro", Cores: 8},
+		RAM:     models.RAMInfo{Total: "16 GB", Used: "8 GB", Available: "8 GB"},
+		Storage: models.StorageInfo{Type: "SSD", Capacity: "512 GB", Used: "200 GB", Available: "312 GB"},
+		Battery: models.BatteryInfo{CycleCount: 120, Health: "Normal", Capacity: "90%"},
+		Status:  "success",
+		Tests: []models.TestResult{
+			{Name: "cpu", Outcome: "passed", DurationSeconds: 1.5,
+				Measurements: map[string]interface{}{"temperature": 52.5}},
+		},
+	}
+	if err := diag.ApplyReceipt(receivedAt); err != nil {
+		t.Fatal(err)
+	}
+	return diag
+}
+
+// TestListDiagnosticsKeysetTies vérifie que la pagination par curseur
+// retourne chaque diagnostic une seule fois, dans l'ordre, quand plusieurs
+// diagnostics ont la même valeur de tri
+func TestListDiagnosticsKeysetTies(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	durations := map[int64]float64{}
+	for _, duration := range []float64{30, 10, 30, 20, 30, 10, 30} {
+		diag := testDiagnostic(t, "C02TIES", now) // même timestamp pour tous
+		diag.Duration = duration
+		id, err := store.CreateDiagnostic(ctx, diag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		durations[id] = duration
+	}
+
+	for _, sortParam := range []string{"duration", "-duration", "-timestamp"} {
+		t.Run(sortParam, func(t *testing.T) {
+			sortBy, err := ParseSort(sortParam)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Ordre attendu : valeur de tri, puis ID, dans le sens demandé
+			var want []int64
+			for id := range durations {
+				want = append(want, id)
+			}
+			sort.Slice(want, func(i, j int) bool {
+				a, b := want[i], want[j]
+				if sortBy.Field == "duration" && durations[a] != durations[b] {
+					return (durations[a] < durations[b]) != sortBy.Desc
+				}
+				return (a < b) != sortBy.Desc
+			})
+
+			var got []int64
+			opts := ListOptions{Sort: sortBy, Limit: 2}
+			for page := 0; page <= len(want); page++ {
+				diagnostics, next, err := store.ListDiagnostics(ctx, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, d := range diagnostics {
+					got = append(got, d.ID)
+				}
+				if next == "" {
+					break
+				}
+				opts.Cursor = next
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("IDs %v, attendu %v", got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("IDs %v, attendu %v", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"diagnostic-backend/metrics"
+)
+
+// PurgeResult compte les lignes supprimées par Purge
+type PurgeResult struct {
+	Diagnostics     int64
+	IdempotencyKeys int64
+}
+
+// oldDiagnostics sélectionne les diagnostics reçus avant la date limite.
+// La rétention compte à partir de la réception (received_at), pas de l'heure
+// effective : les diagnostics historiques importés (captured_at ancien)
+// sont conservés pendant toute la durée de rétention après leur import.
+const oldDiagnostics = "SELECT id FROM diagnostics WHERE julianday(received_at) < julianday(?)"
+
+// Purge supprime les diagnostics reçus avant diagnosticsBefore (avec leurs
+// étapes de test) et les clés d'idempotence antérieures à keysBefore.
+// Une date nulle désactive la purge correspondante. Le registre des machines
+// est conservé ; les compteurs et le dernier diagnostic des machines
+// concernées sont recalculés sur les diagnostics restants.
+func (s *SQLiteStore) Purge(ctx context.Context, diagnosticsBefore, keysBefore time.Time) (PurgeResult, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "purge")
+
+	var result PurgeResult
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback() // sans effet si Commit a réussi
+
+	if !diagnosticsBefore.IsZero() {
+		cutoff := diagnosticsBefore.UTC().Format(time.RFC3339Nano)
+
+		serials, err := purgedSerialNumbers(ctx, tx, cutoff)
+		if err != nil {
+			return result, err
+		}
+
+		// Conserver les maillons pour que la chaîne reste vérifiable
+		if err := buryDiagnostics(ctx, tx, tombstonePurged, oldDiagnostics, cutoff); err != nil {
+			return result, err
+		}
+
+		// Supprimer d'abord les lignes qui référencent les diagnostics purgés
+		for _, query := range []string{
+			"DELETE FROM diagnostic_tests WHERE diagnostic_id IN (" + oldDiagnostics + ")",
+			"DELETE FROM idempotency_keys WHERE diagnostic_id IN (" + oldDiagnostics + ")",
+			"UPDATE machines SET latest_diagnostic_id = NULL WHERE latest_diagnostic_id IN (" + oldDiagnostics + ")",
+		} {
+			if _, err := tx.ExecContext(ctx, query, cutoff); err != nil {
+				return result, err
+			}
+		}
+
+		res, err := tx.ExecContext(ctx, "DELETE FROM diagnostics WHERE id IN ("+oldDiagnostics+")", cutoff)
+		if err != nil {
+			return result, err
+		}
+		result.Diagnostics, _ = res.RowsAffected()
+
+		for _, serialNumber := range serials {
+			if err := refreshMachine(ctx, tx, serialNumber); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if !keysBefore.IsZero() {
+		res, err := tx.ExecContext(ctx,
+			"DELETE FROM idempotency_keys WHERE julianday(created_at) < julianday(?)",
+			keysBefore.UTC().Format(time.RFC3339Nano))
+		if err != nil {
+			return result, err
+		}
+		result.IdempotencyKeys, _ = res.RowsAffected()
+	}
+
+	return result, tx.Commit()
+}
+
+// purgedSerialNumbers liste les machines dont des diagnostics vont être purgés
+func purgedSerialNumbers(ctx context.Context, tx *sql.Tx, cutoff string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT DISTINCT serial_number FROM diagnostics WHERE id IN ("+oldDiagnostics+")", cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var serials []string
+	for rows.Next() {
+		var serialNumber string
+		if err := rows.Scan(&serialNumber); err != nil {
+			return nil, err
+		}
+		serials = append(serials, serialNumber)
+	}
+	return serials, rows.Err()
+}
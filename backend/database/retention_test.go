@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestPurgeRefreshesMachines vérifie que la purge recalcule le registre des
+// machines sur les diagnostics restants
+func TestPurgeRefreshesMachines(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	old := now.AddDate(-2, 0, 0)
+
+	var recentID int64
+	for _, d := range []struct {
+		serial     string
+		receivedAt time.Time
+	}{
+		{"C02MIXED", old},
+		{"C02MIXED", old.Add(time.Hour)},
+		{"C02MIXED", now},
+		{"C02OLD", old},
+		{"C02RECENT", now},
+	} {
+		id, err := store.CreateDiagnostic(ctx, testDiagnostic(t, d.serial, d.receivedAt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.serial == "C02MIXED" {
+			recentID = id
+		}
+	}
+
+	result, err := store.Purge(ctx, now.AddDate(-1, 0, 0), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Diagnostics != 3 {
+		t.Errorf("%d diagnostics purgés, attendu 3", result.Diagnostics)
+	}
+
+	tests := []struct {
+		serial    string
+		runCount  int
+		firstSeen time.Time
+		lastSeen  time.Time
+		latestID  int64 // 0 si plus aucun diagnostic
+	}{
+		{"C02MIXED", 1, now, now, recentID},
+		// Registre conservé sans diagnostic : dates d'origine, aucun dernier diagnostic
+		{"C02OLD", 0, old, old, 0},
+		{"C02RECENT", 1, now, now, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serial, func(t *testing.T) {
+			m, err := store.GetMachine(ctx, tt.serial)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.RunCount != tt.runCount {
+				t.Errorf("run_count = %d, attendu %d", m.RunCount, tt.runCount)
+			}
+			if !m.FirstSeen.Equal(tt.firstSeen) || !m.LastSeen.Equal(tt.lastSeen) {
+				t.Errorf("first_seen, last_seen = %s, %s ; attendu %s, %s", m.FirstSeen, m.LastSeen, tt.firstSeen, tt.lastSeen)
+			}
+			switch {
+			case tt.latestID == 0 && m.LatestDiagnostic != nil:
+				t.Errorf("dernier diagnostic %d, attendu aucun", m.LatestDiagnostic.ID)
+			case tt.latestID != 0 && (m.LatestDiagnostic == nil || m.LatestDiagnostic.ID != tt.latestID):
+				t.Errorf("dernier diagnostic %+v, attendu %d", m.LatestDiagnostic, tt.latestID)
+			}
+		})
+	}
+}
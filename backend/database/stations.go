@@ -0,0 +1,240 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"diagnostic-backend/metrics"
+	"diagnostic-backend/models"
+)
+
+var (
+	// ErrDuplicateStation est retournée quand le nom de station est déjà pris
+	ErrDuplicateStation = errors.New("nom de station déjà utilisé")
+	// ErrSignatureReplayed est retournée quand une signature a déjà été acceptée
+	ErrSignatureReplayed = errors.New("signature déjà utilisée")
+)
+
+// stationColumns liste les colonnes lues par scanStation, dans l'ordre
+const stationColumns = "id, name, created_at, last_seen_at, revoked_at"
+
+// generateStationSecret retourne un secret partagé aléatoire (256 bits)
+func generateStationSecret() (string, error) {
+	var secret [32]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret[:]), nil
+}
+
+// scanStation lit une ligne sélectionnée avec stationColumns
+func scanStation(row rowScanner, extra ...interface{}) (*models.Station, error) {
+	var station models.Station
+	var lastSeenAt, revokedAt sql.NullTime
+
+	dest := append([]interface{}{&station.ID, &station.Name, &station.CreatedAt, &lastSeenAt, &revokedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if lastSeenAt.Valid {
+		station.LastSeenAt = &lastSeenAt.Time
+	}
+	if revokedAt.Valid {
+		station.RevokedAt = &revokedAt.Time
+	}
+	return &station, nil
+}
+
+// CreateStation enregistre une station et retourne son secret partagé
+func (s *SQLiteStore) CreateStation(ctx context.Context, name string) (*models.Station, string, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "create_station")
+
+	secret, err := generateStationSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		"INSERT INTO stations (name, secret, created_at) VALUES (?, ?, ?)",
+		name, secret, time.Now().UTC(),
+	)
+	if isUniqueViolation(err) {
+		return nil, "", ErrDuplicateStation
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+	station, err := s.getStation(ctx, id)
+	return station, secret, err
+}
+
+// ListStations retourne toutes les stations, révoquées comprises
+func (s *SQLiteStore) ListStations(ctx context.Context) ([]models.Station, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "list_stations")
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+stationColumns+" FROM stations ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stations []models.Station
+	for rows.Next() {
+		station, err := scanStation(rows)
+		if err != nil {
+			return nil, err
+		}
+		stations = append(stations, *station)
+	}
+	return stations, rows.Err()
+}
+
+// RotateStationSecret remplace le secret d'une station active ;
+// les envois signés avec l'ancien sont refusés immédiatement
+func (s *SQLiteStore) RotateStationSecret(ctx context.Context, id int64) (*models.Station, string, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "rotate_station_secret")
+
+	secret, err := generateStationSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE stations SET secret = ? WHERE id = ? AND revoked_at IS NULL", secret, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, "", ErrNotFound
+	}
+
+	station, err := s.getStation(ctx, id)
+	return station, secret, err
+}
+
+// RevokeStation refuse désormais les envois de la station (la ligne est
+// conservée : les diagnostics déjà signés y font référence)
+func (s *SQLiteStore) RevokeStation(ctx context.Context, id int64) error {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "revoke_station")
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE stations SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetStationSecret retourne une station active et son secret, ou ErrNotFound
+// si le nom est inconnu ou la station révoquée
+func (s *SQLiteStore) GetStationSecret(ctx context.Context, name string) (*models.Station, string, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "get_station_secret")
+
+	var secret string
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+stationColumns+", secret FROM stations WHERE name = ? AND revoked_at IS NULL", name)
+	station, err := scanStation(row, &secret)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return station, secret, nil
+}
+
+// AuthenticateStation retourne la station active nommée name (CN du certificat
+// client) et met à jour sa dernière activité, ou ErrNotFound si le nom est
+// inconnu ou la station révoquée
+func (s *SQLiteStore) AuthenticateStation(ctx context.Context, name string) (*models.Station, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "authenticate_station")
+
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+stationColumns+" FROM stations WHERE name = ? AND revoked_at IS NULL", name)
+	station, err := scanStation(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if _, err := s.db.ExecContext(ctx, "UPDATE stations SET last_seen_at = ? WHERE id = ?", now, station.ID); err != nil {
+		return nil, err
+	}
+	station.LastSeenAt = &now
+	return station, nil
+}
+
+// RecordSignature mémorise une signature acceptée et retourne ErrSignatureReplayed
+// si elle l'a déjà été. Les signatures plus anciennes que keep, de toute façon
+// refusées pour leur horodatage, sont oubliées au passage.
+func (s *SQLiteStore) RecordSignature(ctx context.Context, sig models.StationSignature, keep time.Duration) error {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "record_signature")
+
+	now := time.Now().UTC()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // sans effet si Commit a réussi
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM used_signatures WHERE used_at < ?", now.Add(-keep)); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO used_signatures (signature, station_id, used_at) VALUES (?, ?, ?)",
+		sig.Signature, sig.StationID, now,
+	)
+	if isUniqueViolation(err) {
+		return ErrSignatureReplayed
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE stations SET last_seen_at = ? WHERE id = ?", now, sig.StationID,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReleaseSignature oublie une signature enregistrée par RecordSignature, pour
+// qu'un envoi refusé après la vérification puisse être renvoyé tel quel
+func (s *SQLiteStore) ReleaseSignature(ctx context.Context, sig models.StationSignature) error {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "release_signature")
+
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM used_signatures WHERE signature = ? AND station_id = ?", sig.Signature, sig.StationID)
+	return err
+}
+
+// getStation retourne ErrNotFound si l'ID n'existe pas
+func (s *SQLiteStore) getStation(ctx context.Context, id int64) (*models.Station, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+stationColumns+" FROM stations WHERE id = ?", id)
+	station, err := scanStation(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return station, err
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+// ErrNotFound est retournée quand l'élément demandé n'existe pas
+var ErrNotFound = errors.New("élément non trouvé")
+
+// Store définit les opérations de stockage utilisées par les handlers.
+// Une implémentation SQLite est fournie par SQLiteStore, mais n'importe quel
+// autre backend (mémoire, Postgres, ...) peut être injecté dans le serveur.
+type Store interface {
+	// CreateDiagnostic insère un diagnostic et retourne son ID
+	CreateDiagnostic(ctx context.Context, diag models.DiagnosticRequest) (int64, error)
+	// CreateDiagnostics insère plusieurs diagnostics de façon atomique
+	CreateDiagnostics(ctx context.Context, diags []models.DiagnosticRequest) ([]InsertResult, error)
+	// CreateDiagnosticIdempotent insère un diagnostic et mémorise sa réponse sous
+	// la clé de son envoyeur, ou retourne la réponse déjà mémorisée (replayed = true)
+	CreateDiagnosticIdempotent(ctx context.Context, diag models.DiagnosticRequest, key, requestHash string, render ResponseRenderer) (rec *IdempotencyRecord, replayed bool, err error)
+	// GetDiagnosticByID retourne ErrNotFound si l'ID n'existe pas
+	GetDiagnosticByID(ctx context.Context, id int64) (*models.Diagnostic, error)
+	// ListDiagnostics retourne une page de diagnostics et le curseur de la suivante
+	ListDiagnostics(ctx context.Context, opts ListOptions) ([]models.Diagnostic, string, error)
+	// ExportDiagnostics appelle fn pour chaque diagnostic filtré, au fil du curseur
+	ExportDiagnostics(ctx context.Context, opts ListOptions, fn func(models.Diagnostic) error) error
+	// GetDiagnosticsBySerialNumber retourne l'historique d'une machine
+	GetDiagnosticsBySerialNumber(ctx context.Context, serialNumber string) ([]models.Diagnostic, error)
+	// ListMachines retourne une page du registre des machines et le curseur de la suivante
+	ListMachines(ctx context.Context, limit int, cursor string) ([]models.Machine, string, error)
+	// GetMachine retourne ErrNotFound si le numéro de série est inconnu
+	GetMachine(ctx context.Context, serialNumber string) (*models.Machine, error)
+	// Totals retourne le nombre de diagnostics et de machines distinctes
+	Totals(ctx context.Context) (diagnostics, machines int, err error)
+	// GetStatistics retourne des statistiques générales
+	GetStatistics(ctx context.Context) (map[string]interface{}, error)
+	// DeleteDiagnostic supprime un diagnostic et ses étapes de test
+	DeleteDiagnostic(ctx context.Context, id int64) error
+	// VerifyChain recalcule la chaîne de hachage et signale le premier maillon rompu
+	VerifyChain(ctx context.Context) (*models.ChainReport, error)
+	// CreateAPIKey crée une clé et retourne sa valeur en clair (une seule fois)
+	CreateAPIKey(ctx context.Context, name string, scopes []string, userID *int64) (*models.APIKey, string, error)
+	// ListAPIKeys retourne toutes les clés, révoquées comprises
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	// RotateAPIKey remplace la valeur d'une clé active et retourne la nouvelle
+	RotateAPIKey(ctx context.Context, id int64) (*models.APIKey, string, error)
+	// RevokeAPIKey retourne ErrNotFound si la clé n'existe pas ou est déjà révoquée
+	RevokeAPIKey(ctx context.Context, id int64) error
+	// AuthenticateAPIKey retourne ErrInvalidAPIKey si la clé est inconnue ou révoquée
+	AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, error)
+	// CreateUser retourne ErrDuplicateUsername si le nom est déjà pris
+	CreateUser(ctx context.Context, username, displayName, role string) (*models.User, error)
+	// ListUsers retourne tous les utilisateurs, désactivés compris
+	ListUsers(ctx context.Context) ([]models.User, error)
+	// SetUserRole retourne ErrNotFound si l'utilisateur n'existe pas
+	SetUserRole(ctx context.Context, id int64, role string) (*models.User, error)
+	// DisableUser retourne ErrNotFound si l'utilisateur n'existe pas ou est déjà désactivé
+	DisableUser(ctx context.Context, id int64) error
+	// CreateStation enregistre une station et retourne son secret (une seule fois)
+	CreateStation(ctx context.Context, name string) (*models.Station, string, error)
+	// ListStations retourne toutes les stations, révoquées comprises
+	ListStations(ctx context.Context) ([]models.Station, error)
+	// RotateStationSecret retourne ErrNotFound si la station n'existe pas ou est révoquée
+	RotateStationSecret(ctx context.Context, id int64) (*models.Station, string, error)
+	// RevokeStation retourne ErrNotFound si la station n'existe pas ou est déjà révoquée
+	RevokeStation(ctx context.Context, id int64) error
+	// GetStationSecret retourne ErrNotFound si la station est inconnue ou révoquée
+	GetStationSecret(ctx context.Context, name string) (*models.Station, string, error)
+	// AuthenticateStation retourne ErrNotFound si la station est inconnue ou révoquée
+	AuthenticateStation(ctx context.Context, name string) (*models.Station, error)
+	// RecordSignature retourne ErrSignatureReplayed si la signature a déjà été acceptée
+	RecordSignature(ctx context.Context, sig models.StationSignature, keep time.Duration) error
+	// ReleaseSignature oublie une signature enregistrée dont l'envoi a été refusé
+	ReleaseSignature(ctx context.Context, sig models.StationSignature) error
+	// Ping vérifie que la base répond
+	Ping(ctx context.Context) error
+	// SchemaVersion retourne la version du schéma appliquée à la base
+	SchemaVersion(ctx context.Context) (int, error)
+	// Close libère les ressources du store
+	Close() error
+}
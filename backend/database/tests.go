@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"diagnostic-backend/models"
+)
+
+// TestCondition filtre les diagnostics ayant une étape name avec le résultat outcome
+type TestCondition struct {
+	Name    string
+	Outcome string
+}
+
+// insertTests enregistre les étapes d'un diagnostic
+func insertTests(ctx context.Context, tx *sql.Tx, diagnosticID int64, tests []models.TestResult) error {
+	if len(tests) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO diagnostic_tests (diagnostic_id, position, name, outcome, measurements, error_message, duration)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, t := range tests {
+		var measurements interface{}
+		if len(t.Measurements) > 0 {
+			data, err := json.Marshal(t.Measurements)
+			if err != nil {
+				return err
+			}
+			measurements = string(data)
+		}
+
+		if _, err := stmt.ExecContext(ctx, diagnosticID, i, t.Name, t.Outcome, measurements,
+			nullIfEmpty(t.ErrorMessage), t.DurationSeconds); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attachTests charge les étapes de tous les diagnostics en une seule requête
+func attachTests(ctx context.Context, q queryer, diagnostics []models.Diagnostic) error {
+	if len(diagnostics) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]int, len(diagnostics))
+	args := make([]interface{}, len(diagnostics))
+	for i := range diagnostics {
+		diagnostics[i].Tests = []models.TestResult{}
+		index[diagnostics[i].ID] = i
+		args[i] = diagnostics[i].ID
+	}
+
+	rows, err := q.QueryContext(ctx, `
+	SELECT diagnostic_id, name, outcome, measurements, error_message, duration
+	FROM diagnostic_tests
+	WHERE diagnostic_id IN (`+placeholders(len(args))+`)
+	ORDER BY diagnostic_id, position`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var diagnosticID int64
+		var t models.TestResult
+		var measurements, errorMessage sql.NullString
+
+		if err := rows.Scan(&diagnosticID, &t.Name, &t.Outcome, &measurements, &errorMessage, &t.DurationSeconds); err != nil {
+			return err
+		}
+		if measurements.Valid {
+			if err := json.Unmarshal([]byte(measurements.String), &t.Measurements); err != nil {
+				return err
+			}
+		}
+		t.ErrorMessage = errorMessage.String
+
+		i := index[diagnosticID]
+		diagnostics[i].Tests = append(diagnostics[i].Tests, t)
+	}
+
+	return rows.Err()
+}
+
+// testConditionsClause construit les conditions EXISTS sur diagnostic_tests
+func testConditionsClause(conds []TestCondition) ([]string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	for _, c := range conds {
+		clause := "EXISTS (SELECT 1 FROM diagnostic_tests t WHERE t.diagnostic_id = diagnostics.id AND t.name = ? COLLATE NOCASE"
+		args = append(args, c.Name)
+		if c.Outcome != "" {
+			clause += " AND t.outcome = ?"
+			args = append(args, strings.ToLower(c.Outcome))
+		}
+		clauses = append(clauses, clause+")")
+	}
+
+	return clauses, args
+}
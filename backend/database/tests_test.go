@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+// TestListDiagnosticsTestFilter vérifie le filtre sur les étapes de test :
+// toutes les conditions doivent être remplies par le même diagnostic
+func TestListDiagnosticsTestFilter(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	ids := map[string]int64{}
+	for _, d := range []struct {
+		serial string
+		tests  []models.TestResult
+	}{
+		{"C02BATTERY", []models.TestResult{{Name: "battery", Outcome: "failed"}, {Name: "cpu", Outcome: "passed"}}},
+		{"C02BOTH", []models.TestResult{{Name: "battery", Outcome: "failed"}, {Name: "storage", Outcome: "failed"}}},
+		{"C02PASSED", []models.TestResult{{Name: "battery", Outcome: "passed"}, {Name: "storage", Outcome: "passed"}}},
+	} {
+		diag := testDiagnostic(t, d.serial, now)
+		diag.Tests = d.tests
+		id, err := store.CreateDiagnostic(ctx, diag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[d.serial] = id
+	}
+
+	failed := func(name string) TestCondition {
+		return TestCondition{Name: name, Outcome: models.TestOutcomeFailed}
+	}
+	tests := []struct {
+		name       string
+		conditions []TestCondition
+		want       []string
+	}{
+		{"failed_test=battery", []TestCondition{failed("battery")}, []string{"C02BOTH", "C02BATTERY"}},
+		{"failed_test=battery,storage", []TestCondition{failed("battery"), failed("storage")}, []string{"C02BOTH"}},
+		{"failed_test=cpu", []TestCondition{failed("cpu")}, nil},
+		{"test=storage", []TestCondition{{Name: "storage"}}, []string{"C02PASSED", "C02BOTH"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics, _, err := store.ListDiagnostics(ctx, ListOptions{Filter: DiagnosticFilter{Tests: tt.conditions}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(diagnostics) != len(tt.want) {
+				t.Fatalf("%d diagnostics, attendu %v", len(diagnostics), tt.want)
+			}
+			for i, serial := range tt.want {
+				if diagnostics[i].ID != ids[serial] {
+					t.Errorf("diagnostic %d : ID %d, attendu %d (%s)", i, diagnostics[i].ID, ids[serial], serial)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"diagnostic-backend/metrics"
+	"diagnostic-backend/models"
+)
+
+// ErrDuplicateUsername est retournée quand le nom d'utilisateur est déjà pris
+var ErrDuplicateUsername = errors.New("nom d'utilisateur déjà utilisé")
+
+// userColumns liste les colonnes lues par scanUser, dans l'ordre
+const userColumns = "id, username, display_name, role, created_at, disabled_at"
+
+// scanUser lit une ligne de users
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var displayName sql.NullString
+	var disabledAt sql.NullTime
+
+	if err := row.Scan(&user.ID, &user.Username, &displayName, &user.Role, &user.CreatedAt, &disabledAt); err != nil {
+		return nil, err
+	}
+
+	user.DisplayName = displayName.String
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
+	return &user, nil
+}
+
+// CreateUser crée un utilisateur avec son rôle
+func (s *SQLiteStore) CreateUser(ctx context.Context, username, displayName, role string) (*models.User, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "create_user")
+
+	res, err := s.db.ExecContext(ctx,
+		"INSERT INTO users (username, display_name, role, created_at) VALUES (?, ?, ?, ?)",
+		username, nullIfEmpty(displayName), role, time.Now().UTC(),
+	)
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateUsername
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetUser(ctx, id)
+}
+
+// ListUsers retourne tous les utilisateurs, désactivés compris
+func (s *SQLiteStore) ListUsers(ctx context.Context) ([]models.User, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "list_users")
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+// GetUser retourne ErrNotFound si l'utilisateur n'existe pas
+func (s *SQLiteStore) GetUser(ctx context.Context, id int64) (*models.User, error) {
+	user, err := scanUser(s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return user, err
+}
+
+// SetUserRole change le rôle d'un utilisateur ; ses clés suivent immédiatement
+func (s *SQLiteStore) SetUserRole(ctx context.Context, id int64, role string) (*models.User, error) {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "set_user_role")
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET role = ? WHERE id = ?", role, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+	return s.GetUser(ctx, id)
+}
+
+// DisableUser désactive un utilisateur et, de fait, toutes ses clés.
+// Ses diagnostics sont conservés.
+func (s *SQLiteStore) DisableUser(ctx context.Context, id int64) error {
+	defer metrics.DBQueryDuration.ObserveSince(time.Now(), "disable_user")
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET disabled_at = ? WHERE id = ? AND disabled_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -3,8 +3,10 @@ module diagnostic-backend
 go 1.21
 
 //Dependencies for the backend server
-require ( 
+require (
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/gorilla/mux v1.8.1
 	github.com/mattn/go-sqlite3 v1.14.18
 	github.com/rs/cors v1.10.1
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// GetAPIKeys liste les clés d'API (sans leur valeur)
+func (s *Server) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	keys, err := s.store.ListAPIKeys(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de récupération des clés d'API", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIKeysListResponse{Success: false})
+		return
+	}
+
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.APIKeysListResponse{
+		Success: true,
+		Count:   len(keys),
+		Keys:    keys,
+	})
+}
+
+// CreateAPIKey crée une clé ; sa valeur n'est retournée que dans cette réponse
+func (s *Server) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIKeyResponse{
+			Success: false,
+			Message: "Format JSON invalide: " + err.Error(),
+		})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	scopes, err := models.ParseScopes(req.Scopes)
+	if err == nil && req.Name == "" {
+		err = errors.New("name est requis")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIKeyResponse{
+			Success: false,
+			Message: "Validation échouée: " + err.Error(),
+		})
+		return
+	}
+
+	key, plain, err := s.store.CreateAPIKey(r.Context(), req.Name, scopes, req.UserID)
+	if errors.Is(err, database.ErrNotFound) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIKeyResponse{
+			Success: false,
+			Message: "Validation échouée: utilisateur inconnu",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de création de la clé d'API", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIKeyResponse{
+			Success: false,
+			Message: "Erreur lors de la création de la clé: " + err.Error(),
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Clé d'API créée",
+		"api_key_id", key.ID, "name", key.Name, "prefix", key.Prefix, "scopes", key.Scopes)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.APIKeyResponse{
+		Success: true,
+		Message: "Clé créée : conservez-la, elle ne sera plus affichée",
+		Key:     plain,
+		APIKey:  key,
+	})
+}
+
+// RotateAPIKey remplace la valeur d'une clé ; l'ancienne est aussitôt refusée
+func (s *Server) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	key, plain, err := s.store.RotateAPIKey(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.APIKeyResponse{
+			Success: false,
+			Message: "Clé non trouvée ou révoquée",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de renouvellement de la clé d'API", "api_key_id", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIKeyResponse{
+			Success: false,
+			Message: "Erreur lors du renouvellement de la clé: " + err.Error(),
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Clé d'API renouvelée", "api_key_id", key.ID, "prefix", key.Prefix)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.APIKeyResponse{
+		Success: true,
+		Message: "Clé renouvelée : conservez-la, elle ne sera plus affichée",
+		Key:     plain,
+		APIKey:  key,
+	})
+}
+
+// RevokeAPIKey désactive définitivement une clé
+func (s *Server) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	err := s.store.RevokeAPIKey(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.APIKeyResponse{
+			Success: false,
+			Message: "Clé non trouvée ou déjà révoquée",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de révocation de la clé d'API", "api_key_id", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIKeyResponse{
+			Success: false,
+			Message: "Erreur lors de la révocation de la clé: " + err.Error(),
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Clé d'API révoquée", "api_key_id", id)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.APIKeyResponse{
+		Success: true,
+		Message: "Clé révoquée",
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+)
+
+// principalContextKey stocke l'appelant authentifié dans le contexte de la requête
+type principalContextKey struct{}
+
+// principal décrit l'appelant d'une requête et ses droits
+type principal struct {
+	key         *models.APIKey // nil pour une requête anonyme
+	permissions map[models.Permission]bool
+}
+
+// userID retourne l'utilisateur rattaché à la clé (nil pour une station ou un anonyme)
+func (p *principal) userID() *int64 {
+	if p == nil || p.key == nil || p.key.User == nil {
+		return nil
+	}
+	return &p.key.User.ID
+}
+
+// keyID retourne l'ID de la clé d'API (nil pour un anonyme)
+func (p *principal) keyID() *int64 {
+	if p == nil || p.key == nil {
+		return nil
+	}
+	return &p.key.ID
+}
+
+// can indique si l'appelant dispose de la permission
+func (p *principal) can(perm models.Permission) bool {
+	return p != nil && p.permissions[perm]
+}
+
+// principalFromContext retourne l'appelant de la requête (nil hors route protégée)
+func principalFromContext(ctx context.Context) *principal {
+	p, _ := ctx.Value(principalContextKey{}).(*principal)
+	return p
+}
+
+// APIKeyFromContext retourne la clé authentifiée (nil pour une requête anonyme)
+func APIKeyFromContext(ctx context.Context) *models.APIKey {
+	if p := principalFromContext(ctx); p != nil {
+		return p.key
+	}
+	return nil
+}
+
+// bearerToken extrait la clé de "Authorization: Bearer <clé>"
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", false
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", true
+	}
+	return strings.TrimSpace(token), true
+}
+
+// Require protège un handler par permission. Les droits d'une clé sont ceux
+// de ses portées, limités par le rôle de son utilisateur. Sans clé, la requête
+// n'est acceptée que si l'authentification est facultative (auth.required),
+// avec les seuls droits historiques de l'API (envoi et lecture).
+//
+// Pour PermReadOwnDiagnostics, le handler reçoit aussi les appelants qui ont
+// PermReadDiagnostics, et filtre lui-même les runs des autres.
+func (s *Server) Require(perm models.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := s.authenticate(w, r)
+		if !ok {
+			return
+		}
+
+		ctx := r.Context()
+		if p.key != nil {
+			ctx = logging.With(ctx, "api_key", p.key.Prefix)
+			if p.key.User != nil {
+				ctx = logging.With(ctx, "user", p.key.User.Username, "role", p.key.User.Role)
+			}
+		}
+
+		allowed := p.can(perm) || (perm == models.PermReadOwnDiagnostics && p.can(models.PermReadDiagnostics))
+		if !allowed {
+			logging.FromContext(ctx).Warn("Accès refusé", "permission", perm)
+			writeForbidden(w, perm)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(ctx, principalContextKey{}, p)))
+	}
+}
+
+// authenticate identifie l'appelant ; en cas d'échec, la réponse est déjà écrite
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (*principal, bool) {
+	token, present := bearerToken(r)
+	if !present {
+		if s.opts.AuthRequired {
+			writeUnauthorized(w, "Authentification requise (Authorization: Bearer <clé>)")
+			return nil, false
+		}
+		perms := make(map[models.Permission]bool)
+		for _, perm := range models.AnonymousPermissions {
+			perms[perm] = true
+		}
+		return &principal{permissions: perms}, true
+	}
+
+	key, err := s.store.AuthenticateAPIKey(r.Context(), token)
+	if errors.Is(err, database.ErrInvalidAPIKey) {
+		logging.FromContext(r.Context()).Warn("Clé d'API refusée", "remote_addr", r.RemoteAddr)
+		writeUnauthorized(w, err.Error())
+		return nil, false
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur d'authentification", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Erreur lors de la vérification de la clé d'API",
+		})
+		return nil, false
+	}
+
+	return &principal{key: key, permissions: key.Permissions()}, true
+}
+
+// writeUnauthorized écrit une réponse 401 (clé absente ou invalide)
+func writeUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="diagnostic"`)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Success: false,
+		Error:   "unauthorized",
+		Message: message,
+	})
+}
+
+// writeForbidden écrit la réponse 403 commune à toutes les routes
+func writeForbidden(w http.ResponseWriter, perm models.Permission) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Success:    false,
+		Error:      "forbidden",
+		Message:    "Permission insuffisante pour cette action",
+		Permission: perm,
+	})
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/models"
+)
+
+// newTestServer crée un serveur sur une base SQLite temporaire, migrée
+func newTestServer(t *testing.T, opts Options) (*Server, *database.SQLiteStore, string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "diagnostics.db")
+	store, err := database.NewSQLiteStore(dbPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return NewServer(store, opts), store, dbPath
+}
+
+// requireStatus appelle un handler protégé par perm et retourne le statut de la réponse
+func requireStatus(s *Server, perm models.Permission, authorization string) int {
+	handler := s.Require(perm, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/test", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec.Code
+}
+
+// TestAPIKeyHashing vérifie que seule l'empreinte SHA-256 de la clé est
+// stockée et que la clé en clair est la seule valeur acceptée
+func TestAPIKeyHashing(t *testing.T) {
+	s, store, dbPath := newTestServer(t, Options{AuthRequired: true})
+	ctx := context.Background()
+
+	key, plain, err := store.CreateAPIKey(ctx, "station-1", []string{models.ScopeIngest}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedPlain, err := store.CreateAPIKey(ctx, "station-2", []string{models.ScopeIngest}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeAPIKey(ctx, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(plain, "dk_"+key.Prefix+"_") {
+		t.Errorf("clé %q sans le préfixe dk_%s_", plain, key.Prefix)
+	}
+	data, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(plain))
+	if strings.Contains(string(data), plain) {
+		t.Error("la clé en clair est stockée en base")
+	}
+	if !strings.Contains(string(data), hex.EncodeToString(sum[:])) {
+		t.Error("l'empreinte SHA-256 de la clé n'est pas stockée en base")
+	}
+
+	// Même préfixe, secret différent : l'empreinte ne correspond pas
+	forged := plain[:len(plain)-1] + "0"
+	if strings.HasSuffix(plain, "0") {
+		forged = plain[:len(plain)-1] + "1"
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"clé valide", "Bearer " + plain, http.StatusOK},
+		{"schéma insensible à la casse", "bearer " + plain, http.StatusOK},
+		{"secret modifié", "Bearer " + forged, http.StatusUnauthorized},
+		{"clé révoquée", "Bearer " + revokedPlain, http.StatusUnauthorized},
+		{"sans préfixe dk_", "Bearer " + strings.TrimPrefix(plain, "dk_"), http.StatusUnauthorized},
+		{"préfixe inconnu", "Bearer dk_00000000_secret", http.StatusUnauthorized},
+		{"autre schéma", "Basic " + plain, http.StatusUnauthorized},
+		{"sans clé", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requireStatus(s, models.PermSubmitDiagnostics, tt.authorization); got != tt.want {
+				t.Errorf("statut %d, attendu %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAPIKeyScopes vérifie les droits d'une clé de station selon ses portées
+func TestAPIKeyScopes(t *testing.T) {
+	s, store, _ := newTestServer(t, Options{AuthRequired: true})
+
+	tests := []struct {
+		scopes  []string
+		perm    models.Permission
+		allowed bool
+	}{
+		{[]string{models.ScopeIngest}, models.PermSubmitDiagnostics, true},
+		{[]string{models.ScopeIngest}, models.PermReadDiagnostics, false},
+		{[]string{models.ScopeIngest}, models.PermManageAccess, false},
+		{[]string{models.ScopeRead}, models.PermSubmitDiagnostics, false},
+		{[]string{models.ScopeRead}, models.PermReadDiagnostics, true},
+		{[]string{models.ScopeRead}, models.PermReadStatistics, true},
+		{[]string{models.ScopeRead}, models.PermDeleteDiagnostics, false},
+		{[]string{models.ScopeIngest, models.ScopeRead}, models.PermSubmitDiagnostics, true},
+		{[]string{models.ScopeIngest, models.ScopeRead}, models.PermReadDiagnostics, true},
+		{[]string{models.ScopeAdmin}, models.PermSubmitDiagnostics, true},
+		{[]string{models.ScopeAdmin}, models.PermManageAccess, true},
+		{[]string{models.ScopeAdmin}, models.PermDeleteDiagnostics, true},
+		{[]string{models.ScopeAdmin}, models.PermImportDiagnostics, true},
+	}
+	for _, tt := range tests {
+		name := strings.Join(tt.scopes, ",") + " " + string(tt.perm)
+		t.Run(name, func(t *testing.T) {
+			_, plain, err := store.CreateAPIKey(context.Background(), name, tt.scopes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := http.StatusForbidden
+			if tt.allowed {
+				want = http.StatusOK
+			}
+			if got := requireStatus(s, tt.perm, "Bearer "+plain); got != want {
+				t.Errorf("statut %d, attendu %d", got, want)
+			}
+		})
+	}
+}
+
+// TestRequireRoleScopeMatrix vérifie Require pour chaque rôle et chaque
+// portée : une clé d'utilisateur n'a que les droits communs à ses portées et
+// à son rôle ; une clé de station, ceux de ses portées.
+func TestRequireRoleScopeMatrix(t *testing.T) {
+	s, store, _ := newTestServer(t, Options{AuthRequired: true})
+	ctx := context.Background()
+
+	const (
+		submit  = models.PermSubmitDiagnostics
+		readOwn = models.PermReadOwnDiagnostics
+		read    = models.PermReadDiagnostics
+		stats   = models.PermReadStatistics
+		verify  = models.PermVerifyChain
+		metrics = models.PermReadMetrics
+		del     = models.PermDeleteDiagnostics
+		imp     = models.PermImportDiagnostics
+		manage  = models.PermManageAccess
+	)
+	all := []models.Permission{submit, readOwn, read, stats, verify, metrics, del, imp, manage}
+
+	tests := []struct {
+		role    string // "" pour une clé de station
+		scope   string
+		allowed []models.Permission
+	}{
+		{models.RoleTechnician, models.ScopeIngest, []models.Permission{submit}},
+		{models.RoleTechnician, models.ScopeRead, []models.Permission{readOwn}},
+		{models.RoleTechnician, models.ScopeAdmin, []models.Permission{submit, readOwn}},
+		{models.RoleManager, models.ScopeIngest, []models.Permission{submit}},
+		{models.RoleManager, models.ScopeRead, []models.Permission{readOwn, read, stats, verify, metrics}},
+		{models.RoleManager, models.ScopeAdmin, []models.Permission{submit, readOwn, read, stats, verify, metrics}},
+		{models.RoleAdmin, models.ScopeIngest, []models.Permission{submit}},
+		{models.RoleAdmin, models.ScopeRead, []models.Permission{readOwn, read, stats, verify, metrics}},
+		{models.RoleAdmin, models.ScopeAdmin, all},
+		{"", models.ScopeIngest, []models.Permission{submit}},
+		{"", models.ScopeRead, []models.Permission{readOwn, read, stats, verify, metrics}},
+		{"", models.ScopeAdmin, all},
+	}
+
+	for _, tt := range tests {
+		name := tt.role + "/" + tt.scope
+		if tt.role == "" {
+			name = "station/" + tt.scope
+		}
+
+		var userID *int64
+		if tt.role != "" {
+			user, err := store.CreateUser(ctx, strings.ReplaceAll(name, "/", "-"), "", tt.role)
+			if err != nil {
+				t.Fatal(err)
+			}
+			userID = &user.ID
+		}
+		_, plain, err := store.CreateAPIKey(ctx, name, []string{tt.scope}, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		allowed := make(map[models.Permission]bool)
+		for _, perm := range tt.allowed {
+			allowed[perm] = true
+		}
+		for _, perm := range all {
+			t.Run(name+"/"+string(perm), func(t *testing.T) {
+				want := http.StatusForbidden
+				if allowed[perm] {
+					want = http.StatusOK
+				}
+				if got := requireStatus(s, perm, "Bearer "+plain); got != want {
+					t.Errorf("statut %d, attendu %d", got, want)
+				}
+			})
+		}
+	}
+}
+
+// TestRequireAnonymous vérifie les droits historiques d'une requête sans clé
+// quand l'authentification est facultative, et son refus sinon
+func TestRequireAnonymous(t *testing.T) {
+	optional, _, _ := newTestServer(t, Options{AuthRequired: false})
+	required, _, _ := newTestServer(t, Options{AuthRequired: true})
+
+	tests := []struct {
+		perm     models.Permission
+		optional int
+	}{
+		{models.PermSubmitDiagnostics, http.StatusOK},
+		{models.PermReadOwnDiagnostics, http.StatusOK}, // via PermReadDiagnostics
+		{models.PermReadDiagnostics, http.StatusOK},
+		{models.PermReadStatistics, http.StatusOK},
+		{models.PermVerifyChain, http.StatusForbidden},
+		{models.PermReadMetrics, http.StatusForbidden},
+		{models.PermDeleteDiagnostics, http.StatusForbidden},
+		{models.PermImportDiagnostics, http.StatusForbidden},
+		{models.PermManageAccess, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.perm), func(t *testing.T) {
+			if got := requireStatus(optional, tt.perm, ""); got != tt.optional {
+				t.Errorf("auth facultative : statut %d, attendu %d", got, tt.optional)
+			}
+			if got := requireStatus(required, tt.perm, ""); got != http.StatusUnauthorized {
+				t.Errorf("auth requise : statut %d, attendu %d", got, http.StatusUnauthorized)
+			}
+		})
+	}
+}
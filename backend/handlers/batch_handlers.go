@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+)
+
+// MaxBatchSize limite le nombre de diagnostics par envoi en lot
+const MaxBatchSize = 1000
+
+// CreateDiagnosticsBatch enregistre un lot de diagnostics collectés hors ligne.
+// Le body est soit un tableau JSON, soit du NDJSON (un diagnostic par ligne),
+// chaque élément au format standard ou Swift. Les éléments valides sont insérés
+// dans une seule transaction ; les invalides sont signalés individuellement.
+func (s *Server) CreateDiagnosticsBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	body, ok := s.readBody(w, r, s.opts.MaxBatchBodyBytes)
+	if !ok {
+		return
+	}
+
+	// La signature d'une station couvre le lot entier
+	signature, ok := s.identifyStation(w, r, body)
+	if !ok {
+		return
+	}
+	w, release := s.holdSignature(w, r, signature)
+	defer release()
+
+	items, err := readBatchItems(bytes.NewReader(body))
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Lot illisible", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.BatchResponse{
+			Success: false,
+			Message: "Lot invalide: " + err.Error(),
+		})
+		return
+	}
+
+	if len(items) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.BatchResponse{
+			Success: false,
+			Message: "Le lot est vide",
+		})
+		return
+	}
+	if len(items) > MaxBatchSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(models.BatchResponse{
+			Success: false,
+			Message: fmt.Sprintf("Le lot contient %d diagnostics (maximum: %d)", len(items), MaxBatchSize),
+			Total:   len(items),
+		})
+		return
+	}
+
+	// Décoder et valider chaque élément
+	caller := principalFromContext(r.Context())
+	submittedBy, apiKeyID := caller.userID(), caller.keyID()
+	results := make([]models.BatchItemResult, len(items))
+	var valid []models.DiagnosticRequest
+	var validIndexes []int
+
+	for i, item := range items {
+		results[i].Index = i
+
+		diagReq, err := decodeDiagnostic(r.Context(), item)
+		if err == nil {
+			err = validateDiagnostic(diagReq)
+			if err != nil {
+				err = fmt.Errorf("Validation échouée: %v", err)
+			}
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		diagReq.SubmittedBy = submittedBy
+		diagReq.APIKeyID = apiKeyID
+		diagReq.Signature = signature
+		valid = append(valid, diagReq)
+		validIndexes = append(validIndexes, i)
+	}
+
+	failed := len(items) - len(valid)
+	created := 0
+
+	// Insérer les éléments valides dans une seule transaction
+	if len(valid) > 0 {
+		inserted, err := s.store.CreateDiagnostics(r.Context(), valid)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Erreur de base de données (lot)", "error", err, "items", len(valid))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(models.BatchResponse{
+				Success: false,
+				Message: "Erreur lors de la sauvegarde du lot, aucun diagnostic enregistré: " + err.Error(),
+				Total:   len(items),
+				Failed:  len(items),
+			})
+			return
+		}
+
+		for j, res := range inserted {
+			results[validIndexes[j]].Success = true
+			results[validIndexes[j]].ID = res.ID
+			results[validIndexes[j]].Duplicate = res.Duplicate
+			if !res.Duplicate {
+				created++
+			}
+		}
+	}
+
+	logging.FromContext(r.Context()).Info("Lot traité",
+		"total", len(items), "created", created, "duplicates", len(valid)-created, "rejected", failed)
+
+	status := http.StatusCreated
+	message := "Lot enregistré avec succès"
+	switch {
+	case len(valid) == 0:
+		status = http.StatusBadRequest
+		message = "Aucun diagnostic valide dans le lot"
+	case failed > 0:
+		status = http.StatusMultiStatus
+		message = "Lot partiellement enregistré"
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.BatchResponse{
+		Success: len(valid) > 0,
+		Message: message,
+		Total:   len(items),
+		Created: created,
+		Failed:  failed,
+		Results: results,
+	})
+}
+
+// readBatchItems découpe le body en éléments JSON bruts.
+// Un body commençant par "[" est lu comme un tableau, sinon comme du NDJSON :
+// une ligne mal formée devient une erreur pour cet élément seulement.
+func readBatchItems(body io.Reader) ([]json.RawMessage, error) {
+	reader := bufio.NewReader(body)
+
+	// Regarder le premier caractère significatif sans le consommer
+	for {
+		b, err := reader.Peek(1)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n' {
+			reader.ReadByte()
+			continue
+		}
+		if b[0] == '[' {
+			var items []json.RawMessage
+			if err := json.NewDecoder(reader).Decode(&items); err != nil {
+				return nil, fmt.Errorf("tableau JSON invalide: %v", err)
+			}
+			return items, nil
+		}
+		break
+	}
+
+	var items []json.RawMessage
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024) // une ligne = un diagnostic
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		items = append(items, json.RawMessage(append([]byte(nil), line...)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("NDJSON invalide: %v", err)
+	}
+
+	return items, nil
+}
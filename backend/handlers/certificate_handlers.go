@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"diagnostic-backend/certificate"
+	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// GetCertificate produit le certificat signé d'un diagnostic, téléchargeable
+// tel quel et vérifiable hors ligne ("verify <fichier>")
+func (s *Server) GetCertificate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.opts.CertificateKey == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Aucune clé de signature des certificats n'est configurée",
+		})
+		return
+	}
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	diagnostic, err := s.store.GetDiagnosticByID(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Diagnostic non trouvé",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de récupération du diagnostic", "diagnostic_id", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Erreur lors de la récupération du diagnostic",
+		})
+		return
+	}
+
+	if !canReadDiagnostic(r, diagnostic) {
+		writeForbidden(w, models.PermReadDiagnostics)
+		return
+	}
+
+	cert, err := certificate.Issue(s.opts.CertificateKey, *diagnostic, time.Now())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de signature du certificat", "diagnostic_id", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Erreur lors de la signature du certificat",
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Certificat émis",
+		"diagnostic_id", id, "serial_number", diagnostic.SystemInfo.SerialNumber, "key_id", cert.Payload.KeyID)
+
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="certificat-%d.json"`, diagnostic.ID))
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(cert)
+}
+
+// CertificatePublicKey retourne la clé publique qui vérifie les certificats
+func (s *Server) CertificatePublicKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.opts.CertificateKey == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Aucune clé de signature des certificats n'est configurée",
+		})
+		return
+	}
+
+	pub := s.opts.CertificateKey.Public().(ed25519.PublicKey)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.PublicKeyResponse{
+		Success:   true,
+		Algorithm: certificate.Algorithm,
+		KeyID:     certificate.KeyID(pub),
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+		PEM:       string(certificate.EncodePublicKeyPEM(pub)),
+	})
+}
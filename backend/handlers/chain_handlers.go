@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+)
+
+// VerifyChain recalcule la chaîne de hachage des diagnostics et signale le
+// premier maillon rompu (la réponse est 200 dans les deux cas)
+func (s *Server) VerifyChain(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	report, err := s.store.VerifyChain(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de vérification de la chaîne", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.ChainVerifyResponse{
+			Success: false,
+			Message: "Erreur lors de la vérification de la chaîne: " + err.Error(),
+		})
+		return
+	}
+
+	message := "Chaîne intègre"
+	if b := report.Break; b != nil {
+		message = fmt.Sprintf("Chaîne rompue au diagnostic %d : %s", b.ID, b.Reason)
+		logging.FromContext(r.Context()).Warn("Chaîne de hachage rompue", "diagnostic_id", b.ID, "reason", b.Reason)
+	} else {
+		logging.FromContext(r.Context()).Info("Chaîne de hachage vérifiée",
+			"checked", report.Checked, "tombstones", report.Tombstones)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.ChainVerifyResponse{
+		Success: true,
+		Message: message,
+		Chain:   report,
+	})
+}
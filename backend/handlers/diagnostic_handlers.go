@@ -2,68 +2,52 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/metrics"
 	"diagnostic-backend/models"
 
 	"github.com/gorilla/mux"
 )
 
 // CreateDiagnostic gère la création d'un nouveau diagnostic
-func CreateDiagnostic(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateDiagnostic(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Lire le body en bytes pour pouvoir le parser deux fois si nécessaire
-	var rawData map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&rawData); err != nil {
-		log.Printf("Erreur de décodage JSON: %v", err)
+	// Lire le body en bytes pour pouvoir détecter le format avant de le parser
+	body, ok := s.readBody(w, r, s.opts.MaxBodyBytes)
+	if !ok {
+		return
+	}
+
+	// Identifier la station (certificat client, signature du body brut) avant tout décodage
+	signature, ok := s.identifyStation(w, r, body)
+	if !ok {
+		return
+	}
+	w, release := s.holdSignature(w, r, signature)
+	defer release()
+
+	diagReq, err := decodeDiagnostic(r.Context(), body)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Décodage JSON échoué", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.DiagnosticResponse{
 			Success: false,
-			Message: "Format JSON invalide: " + err.Error(),
+			Message: err.Error(),
 		})
 		return
 	}
 
-	var diagReq models.DiagnosticRequest
-
-	// Vérifier si c'est le format Swift (plat) ou le format standard (imbriqué)
-	if _, hasSystemInfo := rawData["system_info"]; hasSystemInfo {
-		// Format standard (imbriqué)
-		jsonBytes, _ := json.Marshal(rawData)
-		if err := json.Unmarshal(jsonBytes, &diagReq); err != nil {
-			log.Printf("Erreur de parsing format standard: %v", err)
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.DiagnosticResponse{
-				Success: false,
-				Message: "Format JSON invalide: " + err.Error(),
-			})
-			return
-		}
-	} else {
-		// Format Swift (plat)
-		var swiftReq models.SwiftDiagnosticRequest
-		jsonBytes, _ := json.Marshal(rawData)
-		if err := json.Unmarshal(jsonBytes, &swiftReq); err != nil {
-			log.Printf("Erreur de parsing format Swift: %v", err)
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.DiagnosticResponse{
-				Success: false,
-				Message: "Format JSON invalide: " + err.Error(),
-			})
-			return
-		}
-		// Convertir au format standard
-		diagReq = swiftReq.ToStandardRequest()
-		log.Printf("Format Swift détecté et converti")
-	}
-
 	// Valider les données
 	if err := validateDiagnostic(diagReq); err != nil {
-		log.Printf("Validation échouée: %v", err)
+		logging.FromContext(r.Context()).Warn("Validation échouée", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.DiagnosticResponse{
 			Success: false,
@@ -72,10 +56,20 @@ func CreateDiagnostic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Insérer dans la base de données
-	id, err := database.CreateDiagnostic(diagReq)
+	diagReq.SubmittedBy = principalFromContext(r.Context()).userID()
+	diagReq.APIKeyID = principalFromContext(r.Context()).keyID()
+	diagReq.Signature = signature
+
+	// Envoi idempotent : clé fournie en en-tête, sinon dérivée du run_id du client
+	if key := idempotencyKey(r, diagReq); key != "" {
+		s.createDiagnosticIdempotent(w, r, diagReq, key, body)
+		return
+	}
+
+	// Insérer dans la base de données (sans run_id : pas de doublon possible)
+	id, err := s.store.CreateDiagnostic(r.Context(), diagReq)
 	if err != nil {
-		log.Printf("Erreur de base de données: %v", err)
+		logging.FromContext(r.Context()).Error("Erreur de base de données", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(models.DiagnosticResponse{
 			Success: false,
@@ -84,33 +78,52 @@ func CreateDiagnostic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Diagnostic créé avec succès - ID: %d, Machine: %s, Serial: %s",
-		id, diagReq.SystemInfo.MachineName, diagReq.SystemInfo.SerialNumber)
+	logging.FromContext(r.Context()).Info("Diagnostic créé",
+		"diagnostic_id", id, "machine_name", diagReq.SystemInfo.MachineName, "serial_number", diagReq.SystemInfo.SerialNumber)
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(models.DiagnosticResponse{
+	json.NewEncoder(w).Encode(createdResponse(id))
+}
+
+// createdResponse est la réponse 201 d'un diagnostic enregistré
+func createdResponse(id int64) models.DiagnosticResponse {
+	return models.DiagnosticResponse{
 		Success: true,
 		Message: "Diagnostic enregistré avec succès",
 		ID:      id,
-	})
+	}
 }
 
-// GetDiagnostics récupère tous les diagnostics
-func GetDiagnostics(w http.ResponseWriter, r *http.Request) {
+// GetDiagnostics récupère une page de diagnostics (pagination par curseur, tri, filtres)
+func (s *Server) GetDiagnostics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Paramètre optionnel: limit
-	limitStr := r.URL.Query().Get("limit")
-	limit := 0
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil {
-			limit = l
-		}
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Paramètres invalides: " + err.Error(),
+		})
+		return
+	}
+
+	// Un technicien ne voit que ses propres runs
+	if caller := principalFromContext(r.Context()); !caller.can(models.PermReadDiagnostics) {
+		opts.Filter.SubmittedBy = caller.userID()
 	}
 
-	diagnostics, err := database.GetAllDiagnostics(limit)
+	diagnostics, nextCursor, err := s.store.ListDiagnostics(r.Context(), opts)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	if err != nil {
-		log.Printf("Erreur de récupération: %v", err)
+		logging.FromContext(r.Context()).Error("Erreur de récupération des diagnostics", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(models.DiagnosticsListResponse{
 			Success: false,
@@ -123,18 +136,19 @@ func GetDiagnostics(w http.ResponseWriter, r *http.Request) {
 		diagnostics = []models.Diagnostic{}
 	}
 
-	log.Printf(" Récupération de %d diagnostics", len(diagnostics))
+	logging.FromContext(r.Context()).Debug("Diagnostics récupérés", "count", len(diagnostics))
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(models.DiagnosticsListResponse{
 		Success:     true,
 		Count:       len(diagnostics),
 		Diagnostics: diagnostics,
+		NextCursor:  nextCursor,
 	})
 }
 
 // GetDiagnosticByID récupère un diagnostic spécifique
-func GetDiagnosticByID(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetDiagnosticByID(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	vars := mux.Vars(r)
@@ -150,9 +164,18 @@ func GetDiagnosticByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	diagnostic, err := database.GetDiagnosticByID(id)
+	diagnostic, err := s.store.GetDiagnosticByID(r.Context(), id)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		logging.FromContext(r.Context()).Error("Erreur de récupération du diagnostic", "diagnostic_id", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Erreur lors de la récupération du diagnostic",
+		})
+		return
+	}
 	if err != nil {
-		log.Printf("Diagnostic non trouvé (ID: %d): %v", id, err)
+		logging.FromContext(r.Context()).Info("Diagnostic non trouvé", "diagnostic_id", id)
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
@@ -161,7 +184,12 @@ func GetDiagnosticByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf(" Récupération du diagnostic ID: %d", id)
+	if !canReadDiagnostic(r, diagnostic) {
+		writeForbidden(w, models.PermReadDiagnostics)
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("Diagnostic récupéré", "diagnostic_id", id)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -171,15 +199,15 @@ func GetDiagnosticByID(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetDiagnosticsBySerial récupère tous les diagnostics d'une machine
-func GetDiagnosticsBySerial(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetDiagnosticsBySerial(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	vars := mux.Vars(r)
 	serialNumber := vars["serial"]
 
-	diagnostics, err := database.GetDiagnosticsBySerialNumber(serialNumber)
+	diagnostics, err := s.store.GetDiagnosticsBySerialNumber(r.Context(), serialNumber)
 	if err != nil {
-		log.Printf("Erreur de récupération: %v", err)
+		logging.FromContext(r.Context()).Error("Erreur de récupération des diagnostics", "serial_number", serialNumber, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(models.DiagnosticsListResponse{
 			Success: false,
@@ -190,11 +218,16 @@ func GetDiagnosticsBySerial(w http.ResponseWriter, r *http.Request) {
 
 	//Si err == nil → tout va bien
 
-	if diagnostics == nil {
-		diagnostics = []models.Diagnostic{}
+	// Ne garder que les runs visibles par l'appelant
+	visible := []models.Diagnostic{}
+	for i := range diagnostics {
+		if canReadDiagnostic(r, &diagnostics[i]) {
+			visible = append(visible, diagnostics[i])
+		}
 	}
+	diagnostics = visible
 
-	log.Printf(" Récupération de %d diagnostics pour la machine %s", len(diagnostics), serialNumber)
+	logging.FromContext(r.Context()).Debug("Diagnostics récupérés", "serial_number", serialNumber, "count", len(diagnostics))
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(models.DiagnosticsListResponse{
@@ -204,13 +237,67 @@ func GetDiagnosticsBySerial(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// DeleteDiagnostic supprime un diagnostic (réservé aux administrateurs)
+func (s *Server) DeleteDiagnostic(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "ID invalide",
+		})
+		return
+	}
+
+	err = s.store.DeleteDiagnostic(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Diagnostic non trouvé",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de suppression du diagnostic", "diagnostic_id", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Erreur lors de la suppression du diagnostic",
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Diagnostic supprimé", "diagnostic_id", id)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.DiagnosticResponse{
+		Success: true,
+		Message: "Diagnostic supprimé",
+		ID:      id,
+	})
+}
+
+// canReadDiagnostic indique si l'appelant peut consulter ce diagnostic
+// (tous avec PermReadDiagnostics, sinon seulement les siens)
+func canReadDiagnostic(r *http.Request, diag *models.Diagnostic) bool {
+	caller := principalFromContext(r.Context())
+	if caller.can(models.PermReadDiagnostics) {
+		return true
+	}
+	userID := caller.userID()
+	return userID != nil && diag.SubmittedBy != nil && *diag.SubmittedBy == *userID
+}
+
 // GetStatistics récupère les statistiques générales
-func GetStatistics(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetStatistics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	stats, err := database.GetStatistics()
+	stats, err := s.store.GetStatistics(r.Context())
 	if err != nil {
-		log.Printf("Erreur de récupération des statistiques: %v", err)
+		logging.FromContext(r.Context()).Error("Erreur de récupération des statistiques", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
@@ -219,7 +306,7 @@ func GetStatistics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Println("Récupération des statistiques")
+	logging.FromContext(r.Context()).Debug("Statistiques récupérées")
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -229,7 +316,7 @@ func GetStatistics(w http.ResponseWriter, r *http.Request) {
 }
 
 // HealthCheck vérifie que l'API est fonctionnelle
-func HealthCheck(w http.ResponseWriter, r *http.Request) {
+func (s *Server) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -239,40 +326,64 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// validateDiagnostic valide les données du diagnostic
+// validateDiagnostic valide les données du diagnostic et compte les rejets par champ
 func validateDiagnostic(diag models.DiagnosticRequest) error {
+	err := checkDiagnostic(diag)
+
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		metrics.ValidationFailures.Inc(verr.Field)
+	}
+	return err
+}
+
+// checkDiagnostic retourne la première erreur de validation rencontrée
+func checkDiagnostic(diag models.DiagnosticRequest) error {
 	if diag.SystemInfo.MachineName == "" {
-		return &ValidationError{"machine_name est requis"}
+		return &ValidationError{"machine_name", "machine_name est requis"}
 	}
 	if diag.SystemInfo.SerialNumber == "" {
-		return &ValidationError{"serial_number est requis"}
+		return &ValidationError{"serial_number", "serial_number est requis"}
 	}
 	if diag.SystemInfo.Model == "" {
-		return &ValidationError{"model est requis"}
+		return &ValidationError{"model", "model est requis"}
 	}
 	if diag.CPU.Model == "" {
-		return &ValidationError{"cpu.model est requis"}
+		return &ValidationError{"cpu.model", "cpu.model est requis"}
 	}
 	if diag.CPU.Cores <= 0 {
-		return &ValidationError{"cpu.cores doit être supérieur à 0"}
+		return &ValidationError{"cpu.cores", "cpu.cores doit être supérieur à 0"}
 	}
 	if diag.RAM.Total == "" {
-		return &ValidationError{"ram.total est requis"}
+		return &ValidationError{"ram.total", "ram.total est requis"}
 	}
 	if diag.Storage.Type == "" {
-		return &ValidationError{"storage.type est requis"}
+		return &ValidationError{"storage.type", "storage.type est requis"}
 	}
 	if diag.Battery.CycleCount < 0 {
-		return &ValidationError{"battery.cycle_count ne peut pas être négatif"}
+		return &ValidationError{"battery.cycle_count", "battery.cycle_count ne peut pas être négatif"}
 	}
 	if diag.Status == "" {
-		return &ValidationError{"status est requis"}
+		return &ValidationError{"status", "status est requis"}
+	}
+	for i, t := range diag.Tests {
+		if t.Name == "" {
+			return &ValidationError{"tests.name", fmt.Sprintf("tests[%d].name est requis", i)}
+		}
+		if !models.IsValidTestOutcome(t.Outcome) {
+			return &ValidationError{"tests.outcome", fmt.Sprintf("tests[%d].outcome doit être l'une des valeurs: %s",
+				i, strings.Join(models.TestOutcomes, ", "))}
+		}
+		if t.DurationSeconds < 0 {
+			return &ValidationError{"tests.duration_seconds", fmt.Sprintf("tests[%d].duration_seconds ne peut pas être négatif", i)}
+		}
 	}
 	return nil
 }
 
 // ValidationError représente une erreur de validation
 type ValidationError struct {
+	Field   string // champ en erreur (sans index pour les tests)
 	Message string
 }
 
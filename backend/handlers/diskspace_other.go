@@ -0,0 +1,10 @@
+//go:build !unix
+
+package handlers
+
+import "errors"
+
+// freeDiskSpace n'est implémenté que sur les systèmes Unix (Linux, macOS)
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errors.New("mesure de l'espace disque non supportée sur ce système")
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package handlers
+
+import "syscall"
+
+// freeDiskSpace retourne l'espace disponible (pour un utilisateur non root) sous dir
+func freeDiskSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+)
+
+// exportContentTypes associe les formats d'export à leur type MIME
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+}
+
+// ExportDiagnostics exporte les diagnostics en CSV ou NDJSON (format=csv|ndjson),
+// écrits au fil du curseur de la base. Accepte les filtres et le tri de
+// GET /diagnostics ; limit y est un nombre maximal de lignes (toutes par défaut).
+func (s *Server) ExportDiagnostics(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Format d'export inconnu %q (valeurs possibles: csv, ndjson)", format),
+		})
+		return
+	}
+
+	opts, err := parseListOptions(q)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Paramètres invalides: " + err.Error(),
+		})
+		return
+	}
+
+	// Un technicien n'exporte que ses propres runs
+	if caller := principalFromContext(r.Context()); !caller.can(models.PermReadDiagnostics) {
+		opts.Filter.SubmittedBy = caller.userID()
+	}
+
+	// Un export complet peut durer plus que server.write_timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	columns := models.ExportColumns()
+	csvWriter := csv.NewWriter(w)
+	ndjson := json.NewEncoder(w)
+
+	// Les en-têtes ne sont écrits qu'une fois la requête SQL lancée, pour
+	// pouvoir encore répondre une erreur JSON si elle échoue
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf(`attachment; filename="diagnostics-%s.%s"`, time.Now().UTC().Format("20060102-150405"), format))
+		w.WriteHeader(http.StatusOK)
+		if format == "csv" {
+			return csvWriter.Write(columns)
+		}
+		return nil
+	}
+
+	count := 0
+	err = s.store.ExportDiagnostics(r.Context(), opts, func(d models.Diagnostic) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		count++
+
+		values := d.ExportValues()
+		if format == "csv" {
+			record := make([]string, len(values))
+			for i, v := range values {
+				record[i] = csvField(v)
+			}
+			return csvWriter.Write(record)
+		}
+
+		line := make(map[string]interface{}, len(columns))
+		for i, name := range columns {
+			line[name] = values[i]
+		}
+		return ndjson.Encode(line)
+	})
+	if err == nil && !started {
+		err = start() // aucune ligne : fichier vide (en-tête CSV seul)
+	}
+	if err == nil {
+		csvWriter.Flush()
+		err = csvWriter.Error()
+	}
+
+	if err != nil && !started {
+		status := http.StatusInternalServerError
+		message := "Erreur lors de l'export des diagnostics"
+		if errors.Is(err, database.ErrInvalidCursor) {
+			status, message = http.StatusBadRequest, err.Error()
+		} else {
+			logging.FromContext(r.Context()).Error("Erreur d'export des diagnostics", "error", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	if err != nil {
+		// Fichier déjà partiellement envoyé : couper la connexion pour que le
+		// client voie un transfert incomplet plutôt qu'un export tronqué valide
+		logging.FromContext(r.Context()).Error("Export des diagnostics interrompu", "format", format, "rows", count, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	logging.FromContext(r.Context()).Info("Diagnostics exportés", "format", format, "rows", count)
+}
+
+// csvField formate une valeur de l'export pour une cellule CSV
+func csvField(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return escapeFormula(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapeFormula empêche un tableur d'interpréter comme une formule une
+// valeur saisie côté client (ex: machine_name "=HYPERLINK(...)")
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
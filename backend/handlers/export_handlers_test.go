@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+// TestExportCSV vérifie l'en-tête de l'export CSV et la neutralisation des
+// valeurs qu'un tableur interpréterait comme des formules
+func TestExportCSV(t *testing.T) {
+	s, store, _ := newTestServer(t, Options{})
+
+	for _, name := range []string{`=HYPERLINK("http://exemple.test","x")`, "MacBook Pro", "-1+1", "@SUM(A1)"} {
+		diag := models.DiagnosticRequest{
+			SystemInfo: models.SystemInfo{MachineName: name, SerialNumber: "C02EXPORT", Model: "MacBookPro18,3", OSVersion: "macOS 14.4.1"},
+			CPU:        models.CPUInfo{Model: "Apple M1 Pro", Cores: 8},
+			RAM:        models.RAMInfo{Total: "16 GB"},
+			Storage:    models.StorageInfo{Type: "SSD", Capacity: "512 GB"},
+			Status:     "success",
+		}
+		if err := diag.ApplyReceipt(time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.CreateDiagnostic(context.Background(), diag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/diagnostics/export?format=csv&sort=created_at", nil)
+	s.Require(models.PermReadOwnDiagnostics, s.ExportDiagnostics)(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("statut %d : %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("%d lignes, attendu l'en-tête et 4 diagnostics", len(records))
+	}
+	header := records[0]
+	if strings.Join(header[:4], ",") != "id,run_id,machine_name,serial_number" || len(header) != len(models.ExportColumns()) {
+		t.Errorf("en-tête %v", header)
+	}
+
+	want := []string{`'=HYPERLINK("http://exemple.test","x")`, "MacBook Pro", "'-1+1", "'@SUM(A1)"}
+	for i, name := range want {
+		if got := records[i+1][2]; got != name {
+			t.Errorf("machine_name ligne %d = %q, attendu %q", i+2, got, name)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+)
+
+// readinessTimeout borne la durée totale des vérifications de /readyz
+const readinessTimeout = 2 * time.Second
+
+// readinessCheck est une vérification de /readyz (nil = OK)
+type readinessCheck struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// Livez indique seulement que le processus répond (pas d'accès à la base) :
+// un échec doit entraîner un redémarrage, pas un simple retrait du routage
+func (s *Server) Livez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.ReadinessResponse{Status: models.CheckStatusOK})
+}
+
+// Readyz vérifie que le nœud peut accepter des envois : base joignable,
+// schéma à jour et espace disque suffisant. Retourne 503 si une vérification échoue.
+func (s *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := []readinessCheck{
+		{"database", s.store.Ping},
+		{"schema", s.checkSchema},
+		{"disk", s.checkDisk},
+	}
+
+	response := models.ReadinessResponse{Status: models.CheckStatusOK}
+	for _, check := range checks {
+		start := time.Now()
+		err := check.run(ctx)
+
+		result := models.HealthCheckResult{
+			Name:      check.name,
+			Status:    models.CheckStatusOK,
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil {
+			result.Status = models.CheckStatusFail
+			result.Message = err.Error()
+			response.Status = models.CheckStatusFail
+			logging.FromContext(r.Context()).Warn("Vérification de disponibilité échouée",
+				"check", check.name, "error", err)
+		}
+		response.Checks = append(response.Checks, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if response.Status != models.CheckStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// checkSchema vérifie que la base est à la version attendue par le binaire
+func (s *Server) checkSchema(ctx context.Context) error {
+	version, err := s.store.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if latest := database.LatestSchemaVersion(); version != latest {
+		return fmt.Errorf("schéma en version %d, version attendue %d", version, latest)
+	}
+	return nil
+}
+
+// checkDisk vérifie l'espace libre du répertoire de la base
+func (s *Server) checkDisk(ctx context.Context) error {
+	if s.opts.DataDir == "" {
+		return nil
+	}
+
+	free, err := freeDiskSpace(s.opts.DataDir)
+	if err != nil {
+		return err
+	}
+	if free < s.opts.MinFreeDiskBytes {
+		return fmt.Errorf("espace disque insuffisant: %d Mo libres (minimum %d Mo)",
+			free/(1<<20), s.opts.MinFreeDiskBytes/(1<<20))
+	}
+	return nil
+}
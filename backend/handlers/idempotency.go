@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+)
+
+// maxIdempotencyKeyLength borne la taille de l'en-tête Idempotency-Key
+const maxIdempotencyKeyLength = 255
+
+// idempotencyKey retourne la clé d'idempotence de la requête :
+// l'en-tête Idempotency-Key, sinon le run_id généré par le client
+func idempotencyKey(r *http.Request, diagReq models.DiagnosticRequest) string {
+	if key := strings.TrimSpace(r.Header.Get("Idempotency-Key")); key != "" {
+		return key
+	}
+	if diagReq.RunID != "" {
+		return "run:" + diagReq.RunID
+	}
+	return ""
+}
+
+// createDiagnosticIdempotent enregistre le diagnostic sous la clé donnée,
+// ou rejoue la réponse 201 d'origine si l'envoyeur a déjà utilisé la clé
+// (station et clé d'API, ou machine pour un envoi anonyme)
+func (s *Server) createDiagnosticIdempotent(w http.ResponseWriter, r *http.Request, diagReq models.DiagnosticRequest, key string, body []byte) {
+	if len(key) > maxIdempotencyKeyLength {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.DiagnosticResponse{
+			Success: false,
+			Message: "Idempotency-Key trop longue",
+		})
+		return
+	}
+
+	hash := sha256.Sum256(body)
+	requestHash := hex.EncodeToString(hash[:])
+
+	rec, replayed, err := s.store.CreateDiagnosticIdempotent(r.Context(), diagReq, key, requestHash,
+		func(id int64) (int, []byte) {
+			data, _ := json.Marshal(createdResponse(id))
+			return http.StatusCreated, append(data, '\n')
+		})
+	if errors.Is(err, database.ErrDuplicateRunID) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(models.DiagnosticResponse{
+			Success: false,
+			Message: "Un diagnostic avec ce run_id a déjà été envoyé par un autre client",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de base de données", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.DiagnosticResponse{
+			Success: false,
+			Message: "Erreur lors de la sauvegarde: " + err.Error(),
+		})
+		return
+	}
+
+	if replayed {
+		// La même clé avec un autre contenu est une erreur du client
+		if rec.RequestHash != requestHash {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(models.DiagnosticResponse{
+				Success: false,
+				Message: "Idempotency-Key déjà utilisée pour une requête différente",
+			})
+			return
+		}
+
+		logging.FromContext(r.Context()).Info("Requête rejouée, réponse d'origine renvoyée",
+			"idempotency_key", key, "scope", rec.Scope, "diagnostic_id", rec.DiagnosticID)
+		w.Header().Set("Idempotent-Replayed", "true")
+	} else {
+		logging.FromContext(r.Context()).Info("Diagnostic créé",
+			"diagnostic_id", rec.DiagnosticID, "machine_name", diagReq.SystemInfo.MachineName,
+			"serial_number", diagReq.SystemInfo.SerialNumber, "idempotency_key", key)
+	}
+
+	w.WriteHeader(rec.StatusCode)
+	w.Write(rec.Body)
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// importChunkSize est le nombre de lignes valides enregistrées par transaction
+	importChunkSize = 500
+	// maxImportErrors borne le nombre d'erreurs détaillées dans le rapport
+	maxImportErrors = 1000
+)
+
+// importFields associe les champs acceptés par un mapping d'import à leur
+// emplacement dans la requête (noms JSON du format standard)
+var importFields = map[string]func(d *models.DiagnosticRequest) interface{}{
+	"run_id":                    func(d *models.DiagnosticRequest) interface{} { return &d.RunID },
+	"system_info.machine_name":  func(d *models.DiagnosticRequest) interface{} { return &d.SystemInfo.MachineName },
+	"system_info.serial_number": func(d *models.DiagnosticRequest) interface{} { return &d.SystemInfo.SerialNumber },
+	"system_info.model":         func(d *models.DiagnosticRequest) interface{} { return &d.SystemInfo.Model },
+	"system_info.os_version":    func(d *models.DiagnosticRequest) interface{} { return &d.SystemInfo.OSVersion },
+	"system_info.macos_version": func(d *models.DiagnosticRequest) interface{} { return &d.SystemInfo.MacOSVersion },
+	"cpu.model":                 func(d *models.DiagnosticRequest) interface{} { return &d.CPU.Model },
+	"cpu.cores":                 func(d *models.DiagnosticRequest) interface{} { return &d.CPU.Cores },
+	"cpu.frequency":             func(d *models.DiagnosticRequest) interface{} { return &d.CPU.Frequency },
+	"cpu.temperature":           func(d *models.DiagnosticRequest) interface{} { return &d.CPU.Temperature },
+	"ram.total":                 func(d *models.DiagnosticRequest) interface{} { return &d.RAM.Total },
+	"ram.used":                  func(d *models.DiagnosticRequest) interface{} { return &d.RAM.Used },
+	"ram.available":             func(d *models.DiagnosticRequest) interface{} { return &d.RAM.Available },
+	"ram.type":                  func(d *models.DiagnosticRequest) interface{} { return &d.RAM.Type },
+	"storage.type":              func(d *models.DiagnosticRequest) interface{} { return &d.Storage.Type },
+	"storage.capacity":          func(d *models.DiagnosticRequest) interface{} { return &d.Storage.Capacity },
+	"storage.used":              func(d *models.DiagnosticRequest) interface{} { return &d.Storage.Used },
+	"storage.available":         func(d *models.DiagnosticRequest) interface{} { return &d.Storage.Available },
+	"storage.health":            func(d *models.DiagnosticRequest) interface{} { return &d.Storage.Health },
+	"storage.device_name":       func(d *models.DiagnosticRequest) interface{} { return &d.Storage.DeviceName },
+	"battery.cycle_count":       func(d *models.DiagnosticRequest) interface{} { return &d.Battery.CycleCount },
+	"battery.health":            func(d *models.DiagnosticRequest) interface{} { return &d.Battery.Health },
+	"battery.capacity":          func(d *models.DiagnosticRequest) interface{} { return &d.Battery.Capacity },
+	"battery.max_capacity":      func(d *models.DiagnosticRequest) interface{} { return &d.Battery.MaxCapacity },
+	"battery.condition":         func(d *models.DiagnosticRequest) interface{} { return &d.Battery.Condition },
+	"battery.is_charging":       func(d *models.DiagnosticRequest) interface{} { return &d.Battery.IsCharging },
+	"battery.power_adapter":     func(d *models.DiagnosticRequest) interface{} { return &d.Battery.PowerAdapter },
+	"status":                    func(d *models.DiagnosticRequest) interface{} { return &d.Status },
+	"duration":                  func(d *models.DiagnosticRequest) interface{} { return &d.Duration },
+	"captured_at":               func(d *models.DiagnosticRequest) interface{} { return &d.CapturedAt },
+}
+
+// ImportOptions règle un import CSV
+type ImportOptions struct {
+	DryRun      bool   // valider sans rien enregistrer
+	SubmittedBy *int64 // utilisateur à l'origine de l'import
+	APIKeyID    *int64 // clé d'API de l'import (nil en ligne de commande)
+}
+
+// ParseImportMapping lit un mapping YAML et vérifie ses champs
+func ParseImportMapping(data []byte) (*models.ImportMapping, error) {
+	var m models.ImportMapping
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("mapping invalide: %v", err)
+	}
+
+	if len(m.Columns) == 0 {
+		return nil, errors.New("mapping invalide: columns est vide")
+	}
+	for _, fields := range []map[string]string{m.Columns, m.Defaults} {
+		for field := range fields {
+			if _, ok := importFields[field]; !ok {
+				return nil, fmt.Errorf("mapping invalide: champ inconnu %q (champs possibles: %s)",
+					field, strings.Join(importFieldNames(), ", "))
+			}
+		}
+	}
+	if m.Delimiter != "" {
+		if r, size := utf8.DecodeRuneInString(m.Delimiter); size != len(m.Delimiter) || r == '"' || r == '\r' || r == '\n' {
+			return nil, fmt.Errorf("mapping invalide: delimiter doit être un seul caractère (ex: \";\")")
+		}
+	}
+	if m.Timezone != "" {
+		if _, err := time.LoadLocation(m.Timezone); err != nil {
+			return nil, fmt.Errorf("mapping invalide: timezone inconnu %q", m.Timezone)
+		}
+	}
+
+	return &m, nil
+}
+
+// importFieldNames retourne les champs acceptés, triés
+func importFieldNames() []string {
+	names := make([]string, 0, len(importFields))
+	for name := range importFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ImportCSV importe des diagnostics historiques ligne par ligne. Chaque ligne
+// est convertie selon le mapping puis validée comme un envoi de l'API ; une
+// ligne refusée est signalée dans le rapport sans interrompre l'import.
+// Sans colonne run_id, un run_id est dérivé du contenu de la ligne : réimporter
+// le même fichier ne crée pas de doublons.
+// Une erreur est retournée si le fichier est inexploitable (en-tête) ou si
+// l'enregistrement échoue ; le rapport décrit alors ce qui a déjà été importé.
+func ImportCSV(ctx context.Context, store database.Store, r io.Reader, mapping *models.ImportMapping, opts ImportOptions) (*models.ImportReport, error) {
+	report := &models.ImportReport{DryRun: opts.DryRun, Errors: []models.ImportLineError{}}
+
+	reader := csv.NewReader(r)
+	// Nombre de champs libre : une ligne courte prend les valeurs par défaut
+	reader.FieldsPerRecord = -1
+	if mapping.Delimiter != "" {
+		reader.Comma, _ = utf8.DecodeRuneInString(mapping.Delimiter)
+	}
+
+	layout := time.RFC3339
+	if mapping.DateFormat != "" {
+		layout = mapping.DateFormat
+	}
+	loc := time.UTC
+	if mapping.Timezone != "" {
+		loc, _ = time.LoadLocation(mapping.Timezone)
+	}
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return report, errors.New("fichier CSV vide")
+	}
+	if err != nil {
+		return report, fmt.Errorf("en-tête CSV illisible: %v", err)
+	}
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // BOM des exports Excel
+		}
+		positions[strings.TrimSpace(name)] = i
+	}
+	columns := make(map[string]int, len(mapping.Columns))
+	for field, name := range mapping.Columns {
+		pos, ok := positions[name]
+		if !ok {
+			return report, fmt.Errorf("colonne %q (champ %s) absente de l'en-tête du CSV", name, field)
+		}
+		columns[field] = pos
+	}
+
+	var pending []models.DiagnosticRequest
+	var pendingLines []int
+
+	// flush enregistre les lignes valides en attente dans une transaction
+	flush := func() error {
+		if len(pending) == 0 || opts.DryRun {
+			pending, pendingLines = pending[:0], pendingLines[:0]
+			return nil
+		}
+		inserted, err := store.CreateDiagnostics(ctx, pending)
+		if err != nil {
+			return fmt.Errorf("enregistrement des lignes %d à %d: %w", pendingLines[0], pendingLines[len(pendingLines)-1], err)
+		}
+		for _, res := range inserted {
+			if res.Duplicate {
+				report.Duplicates++
+			} else {
+				report.Imported++
+			}
+		}
+		pending, pendingLines = pending[:0], pendingLines[:0]
+		return nil
+	}
+
+	reject := func(line int, err error) {
+		report.Failed++
+		if len(report.Errors) < maxImportErrors {
+			report.Errors = append(report.Errors, models.ImportLineError{Line: line, Error: err.Error()})
+		}
+	}
+
+	now := time.Now()
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		// Une ParseError ne donne pas de position de champ : FieldPos
+		// n'est appelé que sur un enregistrement lu sans erreur
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			report.Total++
+			reject(perr.StartLine, fmt.Errorf("CSV invalide: %v", perr.Err))
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("lecture du CSV: %v", err)
+		}
+		report.Total++
+		line, _ := reader.FieldPos(0)
+
+		diagReq, err := importRow(record, columns, mapping.Defaults, layout, loc)
+		if err == nil {
+			diagReq.FillNumericValues()
+			if err = diagReq.ApplyReceipt(now); err != nil {
+				err = fmt.Errorf("Horodatage invalide: %v", err)
+			}
+		}
+		if err == nil {
+			if err = validateDiagnostic(diagReq); err != nil {
+				err = fmt.Errorf("Validation échouée: %v", err)
+			}
+		}
+		if err != nil {
+			reject(line, err)
+			continue
+		}
+
+		if diagReq.RunID == "" {
+			sum := sha256.Sum256([]byte(strings.Join(record, "\x1f")))
+			diagReq.RunID = "import-" + hex.EncodeToString(sum[:16])
+		}
+		diagReq.SubmittedBy = opts.SubmittedBy
+		diagReq.APIKeyID = opts.APIKeyID
+
+		report.Valid++
+		pending = append(pending, diagReq)
+		pendingLines = append(pendingLines, line)
+		if len(pending) == importChunkSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return report, err
+	}
+
+	report.Success = report.Valid > 0
+	switch {
+	case report.Total == 0:
+		report.Message = "Aucune ligne de données dans le fichier"
+	case report.Valid == 0:
+		report.Message = "Aucune ligne valide dans le fichier"
+	case opts.DryRun:
+		report.Message = fmt.Sprintf("Simulation : %d ligne(s) valide(s) sur %d, rien n'a été enregistré", report.Valid, report.Total)
+	case report.Failed > 0:
+		report.Message = "Import partiel : les lignes en erreur n'ont pas été enregistrées"
+	default:
+		report.Message = "Import terminé"
+	}
+	return report, nil
+}
+
+// importRow construit la requête d'une ligne : valeurs par défaut, puis
+// cellules non vides des colonnes du mapping
+func importRow(record []string, columns map[string]int, defaults map[string]string, layout string, loc *time.Location) (models.DiagnosticRequest, error) {
+	var d models.DiagnosticRequest
+
+	values := make(map[string]string, len(defaults)+len(columns))
+	for field, v := range defaults {
+		values[field] = v
+	}
+	for field, pos := range columns {
+		if pos >= len(record) {
+			continue // ligne courte : la valeur par défaut s'applique
+		}
+		if v := strings.TrimSpace(record[pos]); v != "" {
+			values[field] = v
+		}
+	}
+
+	// Ordre stable : la première erreur signalée est toujours la même
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		if err := setImportField(importFields[field](&d), values[field], layout, loc); err != nil {
+			return d, fmt.Errorf("%s: %v", field, err)
+		}
+	}
+
+	d.Status = strings.ToLower(d.Status)
+	return d, nil
+}
+
+// setImportField convertit la valeur d'une cellule selon le type du champ
+func setImportField(dst interface{}, v, layout string, loc *time.Location) error {
+	switch p := dst.(type) {
+	case *string:
+		*p = v
+	case *int:
+		n, err := strconv.Atoi(strings.ReplaceAll(v, " ", ""))
+		if err != nil {
+			return fmt.Errorf("entier attendu, lu %q", v)
+		}
+		*p = n
+	case *float64:
+		// Virgule décimale des tableurs français acceptée
+		f, err := strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64)
+		if err != nil {
+			return fmt.Errorf("nombre attendu, lu %q", v)
+		}
+		*p = f
+	case *bool:
+		switch strings.ToLower(v) {
+		case "true", "1", "oui", "yes", "vrai":
+			*p = true
+		case "false", "0", "non", "no", "faux":
+			*p = false
+		default:
+			return fmt.Errorf("booléen attendu (oui/non, true/false), lu %q", v)
+		}
+	case **time.Time:
+		t, err := time.ParseInLocation(layout, v, loc)
+		if err != nil {
+			return fmt.Errorf("date attendue au format %q, lu %q", layout, v)
+		}
+		*p = &t
+	default:
+		return fmt.Errorf("type de champ non pris en charge %T", dst)
+	}
+	return nil
+}
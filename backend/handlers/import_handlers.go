@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+)
+
+// importFormMemory est la part du formulaire gardée en mémoire, le reste passe sur disque
+const importFormMemory = 8 << 20
+
+// ImportDiagnostics importe un CSV de diagnostics historiques.
+// Formulaire multipart : "file" (le CSV) et "mapping" (le mapping YAML, en
+// fichier ou en texte). Avec ?dry_run=true, les lignes sont seulement validées.
+func (s *Server) ImportDiagnostics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := s.opts.MaxBatchBodyBytes
+	if limit > 0 {
+		if r.ContentLength > limit {
+			writeTooLarge(w, r, limit)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	if err := r.ParseMultipartForm(importFormMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeTooLarge(w, r, tooLarge.Limit)
+			return
+		}
+		writeImportError(w, http.StatusBadRequest, "Formulaire multipart invalide: "+err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	mappingData, err := formPart(r, "mapping")
+	if err != nil {
+		writeImportError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	mapping, err := ParseImportMapping(mappingData)
+	if err != nil {
+		writeImportError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeImportError(w, http.StatusBadRequest, "Fichier CSV manquant (champ \"file\")")
+		return
+	}
+	defer file.Close()
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	report, err := ImportCSV(r.Context(), s.store, file, mapping, ImportOptions{
+		DryRun:      dryRun,
+		SubmittedBy: principalFromContext(r.Context()).userID(),
+		APIKeyID:    principalFromContext(r.Context()).keyID(),
+	})
+	if err != nil && report.Total == 0 {
+		// Fichier inexploitable : rien n'a été lu
+		writeImportError(w, http.StatusBadRequest, "CSV invalide: "+err.Error())
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de base de données (import)", "error", err,
+			"imported", report.Imported)
+		report.Success = false
+		report.Message = "Import interrompu: " + err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Import CSV traité", "dry_run", dryRun,
+		"total", report.Total, "imported", report.Imported, "duplicates", report.Duplicates, "rejected", report.Failed)
+
+	status := http.StatusCreated
+	switch {
+	case report.Valid == 0:
+		status = http.StatusBadRequest
+	case dryRun:
+		status = http.StatusOK
+	case report.Failed > 0:
+		status = http.StatusMultiStatus
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// formPart retourne le contenu d'un champ du formulaire, envoyé en fichier ou en texte
+func formPart(r *http.Request, name string) ([]byte, error) {
+	file, _, err := r.FormFile(name)
+	if err == http.ErrMissingFile {
+		if value := r.FormValue(name); value != "" {
+			return []byte(value), nil
+		}
+		return nil, errors.New("Champ \"" + name + "\" manquant")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// writeImportError écrit le rapport d'un import refusé avant la lecture des lignes
+func writeImportError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.ImportReport{
+		Success: false,
+		Message: message,
+		Errors:  []models.ImportLineError{},
+	})
+}
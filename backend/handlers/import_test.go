@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestImportCSVLineErrors vérifie qu'une ligne mal formée est signalée
+// avec son numéro sans interrompre l'import (simulation, sans base)
+func TestImportCSVLineErrors(t *testing.T) {
+	mapping, err := ParseImportMapping([]byte(`
+columns:
+  system_info.machine_name: Nom
+  system_info.serial_number: Série
+defaults:
+  system_info.model: MacBookPro18,3
+  cpu.model: Apple M1 Pro
+  cpu.cores: "8"
+  ram.total: 16 GB
+  storage.type: SSD
+  status: success
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		csv       string
+		valid     int
+		wantLines []int
+	}{
+		{"guillemet isolé dans le premier champ", "Nom,Série\nx\"y,z\nMac,C02\n", 1, []int{2}},
+		{"guillemet non fermé", "Nom,Série\nMac,C01\n\"Mac,C02\n", 1, []int{3}},
+		{"champ requis vide", "Nom,Série\nMac,\nMac,C02\n", 1, []int{2}},
+		{"lignes valides", "Nom,Série\nMac,C01\nMac,C02\n", 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ImportCSV(context.Background(), nil, strings.NewReader(tt.csv), mapping,
+				ImportOptions{DryRun: true})
+			if err != nil {
+				t.Fatalf("ImportCSV: %v", err)
+			}
+			if report.Valid != tt.valid {
+				t.Errorf("valid = %d, attendu %d", report.Valid, tt.valid)
+			}
+			if len(report.Errors) != len(tt.wantLines) {
+				t.Fatalf("erreurs = %+v, attendu lignes %v", report.Errors, tt.wantLines)
+			}
+			for i, line := range tt.wantLines {
+				if report.Errors[i].Line != line {
+					t.Errorf("erreur %d à la ligne %d, attendu %d", i, report.Errors[i].Line, line)
+				}
+			}
+		})
+	}
+}
+
+// TestImportCSVShortRow vérifie qu'une ligne courte est importée avec les
+// valeurs par défaut des colonnes manquantes
+func TestImportCSVShortRow(t *testing.T) {
+	mapping, err := ParseImportMapping([]byte(`
+columns:
+  system_info.machine_name: Nom
+  system_info.serial_number: Série
+  cpu.model: Processeur
+defaults:
+  system_info.model: MacBookPro18,3
+  cpu.model: Apple M1 Pro
+  cpu.cores: "8"
+  ram.total: 16 GB
+  storage.type: SSD
+  status: success
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csv := "Nom,Série,Processeur\nMac,C01,Apple M2\nMac,C02\n"
+	report, err := ImportCSV(context.Background(), nil, strings.NewReader(csv), mapping, ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ImportCSV: %v", err)
+	}
+	if report.Valid != 2 || len(report.Errors) != 0 {
+		t.Errorf("valid = %d, erreurs = %+v ; attendu 2 lignes valides", report.Valid, report.Errors)
+	}
+}
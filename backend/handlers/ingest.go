@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+)
+
+// decodeDiagnostic interprète un diagnostic JSON dans l'un des formats acceptés
+// (standard imbriqué, sortie de system_profiler ou Swift plat) et le convertit
+// au format standard
+func decodeDiagnostic(ctx context.Context, data []byte) (models.DiagnosticRequest, error) {
+	var diagReq models.DiagnosticRequest
+
+	// Décoder d'abord les clés de premier niveau pour détecter le format
+	var rawData map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rawData); err != nil {
+		return diagReq, fmt.Errorf("Format JSON invalide: %v", err)
+	}
+
+	// Détecter le format : standard (imbriqué), system_profiler brut ou Swift (plat)
+	_, hasSystemInfo := rawData["system_info"]
+	_, hasSystemProfiler := rawData["SPHardwareDataType"]
+
+	switch {
+	case hasSystemInfo:
+		// Format standard (imbriqué)
+		if err := json.Unmarshal(data, &diagReq); err != nil {
+			return diagReq, fmt.Errorf("Format JSON invalide: %v", err)
+		}
+	case hasSystemProfiler:
+		// Sortie "system_profiler -json" envoyée telle quelle
+		var report models.SystemProfilerReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			return diagReq, fmt.Errorf("Format system_profiler invalide: %v", err)
+		}
+		diagReq = report.ToStandardRequest()
+		logging.FromContext(ctx).Debug("Format system_profiler détecté et converti")
+	default:
+		// Format Swift (plat)
+		var swiftReq models.SwiftDiagnosticRequest
+		if err := json.Unmarshal(data, &swiftReq); err != nil {
+			return diagReq, fmt.Errorf("Format JSON invalide: %v", err)
+		}
+		// Convertir au format standard
+		diagReq = swiftReq.ToStandardRequest()
+		logging.FromContext(ctx).Debug("Format Swift détecté et converti")
+	}
+
+	// Sans statut global, le déduire des résultats par étape
+	if diagReq.Status == "" && len(diagReq.Tests) > 0 {
+		diagReq.Status = models.StatusFromTests(diagReq.Tests)
+	}
+
+	// Compléter les valeurs numériques (octets, %) à partir des chaînes
+	diagReq.FillNumericValues()
+
+	// Dater le diagnostic (heure de capture client, décalage d'horloge)
+	if err := diagReq.ApplyReceipt(time.Now()); err != nil {
+		return diagReq, fmt.Errorf("Horodatage invalide: %v", err)
+	}
+
+	return diagReq, nil
+}
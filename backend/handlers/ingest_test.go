@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+// TestDecodeSystemProfiler décode des sorties de
+// "system_profiler -json SPHardwareDataType SPStorageDataType SPPowerDataType SPMemoryDataType"
+// (testdata/system_profiler) et vérifie la conversion au format standard
+func TestDecodeSystemProfiler(t *testing.T) {
+	captured := time.Date(2024, 4, 12, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		fixture string
+		want    models.DiagnosticRequest
+	}{
+		{
+			// Apple Silicon, portable sur secteur
+			fixture: "macbookpro_m1pro.json",
+			want: models.DiagnosticRequest{
+				SystemInfo: models.SystemInfo{
+					MachineName:  "MacBook Pro",
+					SerialNumber: "FVFXXXXXXQ6L",
+					Model:        "MacBookPro18,3",
+					OSVersion:    "macOS",
+				},
+				CPU: models.CPUInfo{Model: "Apple M1 Pro", Cores: 8},
+				RAM: models.RAMInfo{Total: "16 GB", Type: "LPDDR5"},
+				Storage: models.StorageInfo{
+					Type:       "SSD",
+					Capacity:   "460.43 GB",
+					Used:       "192.90 GB",
+					Available:  "267.53 GB",
+					Health:     "Verified",
+					DeviceName: "APPLE SSD AP0512R",
+				},
+				Battery: models.BatteryInfo{
+					CycleCount:   212,
+					Health:       "Normal",
+					Capacity:     "76%",
+					MaxCapacity:  "91%",
+					Condition:    "Normal",
+					IsCharging:   true,
+					PowerAdapter: "140W USB-C Power Adapter",
+				},
+				Status: "success",
+			},
+		},
+		{
+			// Intel : barrettes détaillées, fréquence, disque externe ignoré
+			fixture: "macbookpro_intel.json",
+			want: models.DiagnosticRequest{
+				SystemInfo: models.SystemInfo{
+					MachineName:  "MacBook Pro",
+					SerialNumber: "C02XXXXXXML7H",
+					Model:        "MacBookPro16,2",
+					OSVersion:    "macOS",
+				},
+				CPU: models.CPUInfo{Model: "Quad-Core Intel Core i7", Cores: 4, Frequency: "2,3 GHz"},
+				RAM: models.RAMInfo{Total: "32 GB", Type: "LPDDR4X"},
+				Storage: models.StorageInfo{
+					Type:       "SSD",
+					Capacity:   "931.55 GB",
+					Used:       "846.29 GB",
+					Available:  "85.25 GB",
+					Health:     "Verified",
+					DeviceName: "APPLE SSD AP1024N",
+				},
+				Battery: models.BatteryInfo{
+					CycleCount:   1043,
+					Health:       "Service Recommended",
+					Capacity:     "100%",
+					MaxCapacity:  "68%",
+					Condition:    "Service Recommended",
+					PowerAdapter: "96 W",
+				},
+				Status: "partial",
+			},
+		},
+		{
+			// Mac de bureau sans batterie, SPSoftwareDataType et champs du run ajoutés
+			fixture: "macmini_m2_software.json",
+			want: models.DiagnosticRequest{
+				RunID: "7d4c2a52-3a7e-4c55-9d0b-1f2e3d4c5b6a",
+				SystemInfo: models.SystemInfo{
+					MachineName:  "Atelier-Mac-mini",
+					SerialNumber: "XXXXXXXXVW",
+					Model:        "Mac14,3",
+					OSVersion:    "macOS 14.4.1 (23E224)",
+					MacOSVersion: "14.4.1",
+				},
+				CPU: models.CPUInfo{Model: "Apple M2", Cores: 8},
+				RAM: models.RAMInfo{Total: "8 GB", Type: "LPDDR5"},
+				Storage: models.StorageInfo{
+					Type:       "SSD",
+					Capacity:   "228.27 GB",
+					Used:       "216.27 GB",
+					Available:  "12.00 GB",
+					Health:     "Failing",
+					DeviceName: "APPLE SSD AP0256Z",
+				},
+				Status:     "failed",
+				CapturedAt: &captured,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "system_profiler", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := decodeDiagnostic(context.Background(), data)
+			if err != nil {
+				t.Fatalf("decodeDiagnostic: %v", err)
+			}
+			if err := validateDiagnostic(got); err != nil {
+				t.Fatalf("validateDiagnostic: %v", err)
+			}
+
+			if got.RunID != tt.want.RunID {
+				t.Errorf("run_id = %q, attendu %q", got.RunID, tt.want.RunID)
+			}
+			if got.SystemInfo != tt.want.SystemInfo {
+				t.Errorf("system_info = %+v, attendu %+v", got.SystemInfo, tt.want.SystemInfo)
+			}
+			if got.CPU != tt.want.CPU {
+				t.Errorf("cpu = %+v, attendu %+v", got.CPU, tt.want.CPU)
+			}
+			if got.RAM.Total != tt.want.RAM.Total || got.RAM.Type != tt.want.RAM.Type {
+				t.Errorf("ram = %q %q, attendu %q %q", got.RAM.Total, got.RAM.Type, tt.want.RAM.Total, tt.want.RAM.Type)
+			}
+			if got.RAM.TotalBytes == nil {
+				t.Errorf("ram.total_bytes non renseigné")
+			}
+
+			gotStorage, wantStorage := got.Storage, tt.want.Storage
+			gotStorage.CapacityBytes, gotStorage.UsedBytes, gotStorage.AvailableBytes = nil, nil, nil
+			if gotStorage != wantStorage {
+				t.Errorf("storage = %+v, attendu %+v", gotStorage, wantStorage)
+			}
+			if got.Storage.CapacityBytes == nil || got.Storage.UsedBytes == nil || got.Storage.AvailableBytes == nil {
+				t.Fatalf("storage: octets non renseignés")
+			}
+			if *got.Storage.UsedBytes+*got.Storage.AvailableBytes != *got.Storage.CapacityBytes {
+				t.Errorf("storage: used + available != capacity (%d + %d != %d)",
+					*got.Storage.UsedBytes, *got.Storage.AvailableBytes, *got.Storage.CapacityBytes)
+			}
+
+			gotBattery := got.Battery
+			gotBattery.CapacityPercent, gotBattery.MaxCapacityPercent = nil, nil
+			if gotBattery != tt.want.Battery {
+				t.Errorf("battery = %+v, attendu %+v", gotBattery, tt.want.Battery)
+			}
+
+			if got.Status != tt.want.Status {
+				t.Errorf("status = %q, attendu %q", got.Status, tt.want.Status)
+			}
+			if tt.want.CapturedAt != nil && !got.Timestamp.Equal(*tt.want.CapturedAt) {
+				t.Errorf("timestamp = %s, attendu %s", got.Timestamp, tt.want.CapturedAt)
+			}
+		})
+	}
+}
+
+// TestDecodeSystemProfilerRunFields vérifie que le statut et les étapes
+// ajoutés par la station l'emportent sur l'état du matériel
+func TestDecodeSystemProfilerRunFields(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "system_profiler", "macbookpro_intel.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ajouter les champs du run au premier niveau de la sortie brute
+	raw := bytes.TrimSuffix(bytes.TrimSpace(data), []byte("}"))
+	withTests := append(raw, `,"tests":[{"name":"cpu","outcome":"failed","duration_seconds":1}]}`...)
+	got, err := decodeDiagnostic(context.Background(), withTests)
+	if err != nil {
+		t.Fatalf("decodeDiagnostic: %v", err)
+	}
+	if got.Status != "failed" || len(got.Tests) != 1 {
+		t.Errorf("status = %q avec %d étape(s), attendu failed avec 1 étape", got.Status, len(got.Tests))
+	}
+}
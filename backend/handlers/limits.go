@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"diagnostic-backend/logging"
+	"diagnostic-backend/metrics"
+	"diagnostic-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// readBody lit le body en refusant (413) tout ce qui dépasse limit octets,
+// sans jamais garder plus de limit octets en mémoire.
+// En cas d'échec, la réponse est déjà écrite.
+func (s *Server) readBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	// Content-Length annoncé : refuser sans rien lire
+	if limit > 0 && r.ContentLength > limit {
+		writeTooLarge(w, r, limit)
+		return nil, false
+	}
+
+	reader := r.Body
+	if limit > 0 {
+		reader = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	body, err := io.ReadAll(reader)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeTooLarge(w, r, tooLarge.Limit)
+		return nil, false
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Lecture du body impossible", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.DiagnosticResponse{
+			Success: false,
+			Message: "Erreur de lecture de la requête: " + err.Error(),
+		})
+		return nil, false
+	}
+	return body, true
+}
+
+// writeTooLarge écrit la réponse 413 d'un body trop volumineux
+func writeTooLarge(w http.ResponseWriter, r *http.Request, limit int64) {
+	metrics.BodyTooLarge.Inc(routeTemplate(r))
+	logging.FromContext(r.Context()).Warn("Body trop volumineux",
+		"content_length", r.ContentLength, "limit_bytes", limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Success: false,
+		Error:   "payload_too_large",
+		Message: fmt.Sprintf("Requête trop volumineuse (maximum: %d octets)", limit),
+	})
+}
+
+// RateLimit limite le débit de chaque adresse IP. À placer autour de
+// Require : les requêtes refusées par l'authentification (clé ou signature
+// invalide), qui coûtent chacune un hash et une lecture en base, sont comptées.
+func (s *Server) RateLimit(next http.HandlerFunc) http.HandlerFunc {
+	if s.limiter == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.allow(w, r, "ip:"+clientIP(r)) {
+			next(w, r)
+		}
+	}
+}
+
+// RateLimitKey limite en plus le débit de chaque clé d'API, quelle que soit
+// l'adresse IP d'où elle est utilisée. À placer dans Require pour que la clé
+// soit connue ; sans clé, seul le débit par IP de RateLimit s'applique.
+func (s *Server) RateLimitKey(next http.HandlerFunc) http.HandlerFunc {
+	if s.limiter == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := APIKeyFromContext(r.Context())
+		if key == nil || s.allow(w, r, "key:"+key.Prefix) {
+			next(w, r)
+		}
+	}
+}
+
+// allow consomme un jeton du client, ou écrit la réponse 429
+func (s *Server) allow(w http.ResponseWriter, r *http.Request, client string) bool {
+	ok, wait := s.limiter.allow(client, time.Now())
+	if ok {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	metrics.RateLimited.Inc(routeTemplate(r))
+	logging.FromContext(r.Context()).Warn("Débit limité", "client", client, "retry_after", retryAfter)
+
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Success: false,
+		Error:   "rate_limited",
+		Message: fmt.Sprintf("Trop de requêtes, réessayez dans %d s", retryAfter),
+	})
+	return false
+}
+
+// clientIP retourne l'adresse IP de la connexion (sans le port)
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// routeTemplate retourne le modèle de la route (ex: /api/v1/diagnostics/{id}) pour les métriques
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unmatched"
+}
+
+// bucket est le seau de jetons d'un client
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter applique un seau de jetons par client : burst requêtes d'affilée,
+// puis rate requêtes par seconde
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow consomme un jeton du client, ou retourne le délai avant le prochain
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+		metrics.RateLimitClients.Set(float64(len(l.buckets)))
+	} else {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep oublie, au plus une fois par minute, les clients dont le seau
+// s'est rempli depuis leur dernière requête (ils repartiraient à l'identique)
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, client)
+		}
+	}
+	metrics.RateLimitClients.Set(float64(len(l.buckets)))
+}
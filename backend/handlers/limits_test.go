@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+// TestRateLimiterAllow vérifie le seau de jetons : burst requêtes d'affilée,
+// puis rate par seconde, indépendamment pour chaque client
+func TestRateLimiterAllow(t *testing.T) {
+	start := time.Date(2024, 4, 12, 9, 30, 0, 0, time.UTC)
+
+	// 2 requêtes par seconde, 3 d'affilée
+	steps := []struct {
+		name   string
+		after  time.Duration // depuis start
+		client string
+		ok     bool
+		wait   time.Duration
+	}{
+		{"burst 1/3", 0, "a", true, 0},
+		{"burst 2/3", 0, "a", true, 0},
+		{"burst 3/3", 0, "a", true, 0},
+		{"seau vide", 0, "a", false, 500 * time.Millisecond},
+		{"autre client", 0, "b", true, 0},
+		{"seau encore vide", 250 * time.Millisecond, "a", false, 250 * time.Millisecond},
+		{"un jeton regagné", 500 * time.Millisecond, "a", true, 0},
+		{"de nouveau vide", 500 * time.Millisecond, "a", false, 500 * time.Millisecond},
+		{"seau plein, pas au-delà du burst", 10 * time.Second, "a", true, 0},
+		{"burst 2/3 après remplissage", 10 * time.Second, "a", true, 0},
+		{"burst 3/3 après remplissage", 10 * time.Second, "a", true, 0},
+		{"burst épuisé après remplissage", 10 * time.Second, "a", false, 500 * time.Millisecond},
+	}
+
+	l := newRateLimiter(2, 3)
+	for _, step := range steps {
+		ok, wait := l.allow(step.client, start.Add(step.after))
+		if ok != step.ok || wait != step.wait {
+			t.Errorf("%s : allow = %v, %s ; attendu %v, %s", step.name, ok, wait, step.ok, step.wait)
+		}
+	}
+}
+
+// TestRateLimiterSweep vérifie que les clients dont le seau est de nouveau
+// plein sont oubliés
+func TestRateLimiterSweep(t *testing.T) {
+	start := time.Date(2024, 4, 12, 9, 30, 0, 0, time.UTC)
+	l := newRateLimiter(1, 10) // seau rempli en 10 s
+
+	l.allow("ancien", start)
+	l.allow("récent", start.Add(55*time.Second))
+	l.allow("déclencheur", start.Add(61*time.Second))
+
+	if _, ok := l.buckets["ancien"]; ok {
+		t.Error("le client inactif depuis plus de 10 s n'a pas été oublié")
+	}
+	for _, client := range []string{"récent", "déclencheur"} {
+		if _, ok := l.buckets[client]; !ok {
+			t.Errorf("le client %q a été oublié", client)
+		}
+	}
+}
+
+// TestRateLimitMiddleware vérifie la réponse 429, le débit par adresse IP
+// compté avant l'authentification, puis le débit par clé d'API
+func TestRateLimitMiddleware(t *testing.T) {
+	s, store, _ := newTestServer(t, Options{AuthRequired: true, RatePerSecond: 0.5, RateBurst: 1})
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	handler := s.RateLimit(s.Require(models.PermSubmitDiagnostics, s.RateLimitKey(okHandler)))
+
+	ctx := context.Background()
+	_, keyA, err := store.CreateAPIKey(ctx, "station-a", []string{models.ScopeIngest}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, keyB, err := store.CreateAPIKey(ctx, "station-b", []string{models.ScopeIngest}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(remoteAddr, key string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/diagnostics", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Authorization", "Bearer "+key)
+		return req
+	}
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"clé invalide depuis l'IP 1", request("192.0.2.1:5000", "dk_invalide"), http.StatusUnauthorized},
+		{"clé invalide, IP 1 limitée", request("192.0.2.1:5001", "dk_invalide"), http.StatusTooManyRequests},
+		{"clé valide, IP 1 limitée", request("192.0.2.1:5002", keyA), http.StatusTooManyRequests},
+		{"clé A depuis l'IP 2", request("192.0.2.2:5000", keyA), http.StatusOK},
+		{"clé A depuis l'IP 3", request("192.0.2.3:5000", keyA), http.StatusTooManyRequests},
+		{"clé B depuis l'IP 4", request("192.0.2.4:5000", keyB), http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler(rec, tt.req)
+		if rec.Code != tt.want {
+			t.Errorf("%s : statut %d, attendu %d", tt.name, rec.Code, tt.want)
+			continue
+		}
+		if tt.want == http.StatusTooManyRequests {
+			if got := rec.Header().Get("Retry-After"); got != "2" {
+				t.Errorf("%s : Retry-After = %q, attendu 2", tt.name, got)
+			}
+			if !strings.Contains(rec.Body.String(), `"rate_limited"`) {
+				t.Errorf("%s : body %s sans l'erreur rate_limited", tt.name, rec.Body)
+			}
+		}
+	}
+
+	// Sans limite configurée, les handlers sont appelés directement
+	unlimited := NewServer(nil, Options{})
+	limited := unlimited.RateLimit(unlimited.RateLimitKey(okHandler))
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		limited(rec, request("192.0.2.9:5000", ""))
+		if rec.Code != http.StatusOK {
+			t.Errorf("sans limite, requête %d : statut %d", i+1, rec.Code)
+		}
+	}
+}
+
+// TestReadBodyLimits vérifie le refus (413) des bodies trop volumineux,
+// annoncés par Content-Length ou envoyés sans (chunked)
+func TestReadBodyLimits(t *testing.T) {
+	s := NewServer(nil, Options{})
+
+	tests := []struct {
+		name    string
+		body    string
+		chunked bool // Content-Length inconnu
+		limit   int64
+		ok      bool
+	}{
+		{"sous la limite", "0123456789", false, 16, true},
+		{"exactement la limite", "0123456789", false, 10, true},
+		{"Content-Length au-delà", "0123456789", false, 9, false},
+		{"chunked sous la limite", "0123456789", true, 10, true},
+		{"chunked au-delà", "0123456789", true, 9, false},
+		{"sans limite", strings.Repeat("x", 1<<20), false, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/diagnostics", strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+
+			body, ok := s.readBody(rec, req, tt.limit)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, attendu %v (statut %d)", ok, tt.ok, rec.Code)
+			}
+			if tt.ok {
+				if string(body) != tt.body {
+					t.Errorf("body de %d octets, attendu %d", len(body), len(tt.body))
+				}
+				return
+			}
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("statut %d, attendu %d", rec.Code, http.StatusRequestEntityTooLarge)
+			}
+			if !strings.Contains(rec.Body.String(), `"payload_too_large"`) {
+				t.Errorf("body %s sans l'erreur payload_too_large", rec.Body)
+			}
+		})
+	}
+}
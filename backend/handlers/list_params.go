@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/models"
+)
+
+// parseListOptions lit les paramètres de pagination, de tri et de filtre :
+//
+//	limit, cursor, sort=[-]timestamp|created_at|duration|cycle_count,
+//	status, model, cpu_model, from, to, battery_health, battery_max_capacity_below,
+//	test=nom[:résultat], failed_test=nom
+//
+// status, battery_health, test et failed_test acceptent plusieurs valeurs
+// séparées par des virgules (ex: failed_test=battery,storage).
+func parseListOptions(q url.Values) (database.ListOptions, error) {
+	var opts database.ListOptions
+
+	if limitStr := q.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return opts, fmt.Errorf("limit doit être un entier positif")
+		}
+		opts.Limit = limit
+	}
+
+	sort, err := database.ParseSort(q.Get("sort"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Sort = sort
+	opts.Cursor = q.Get("cursor")
+
+	filter, err := parseDiagnosticFilter(q)
+	if err != nil {
+		return opts, err
+	}
+	opts.Filter = filter
+
+	return opts, nil
+}
+
+// parseDiagnosticFilter lit les filtres communs aux endpoints de liste
+func parseDiagnosticFilter(q url.Values) (database.DiagnosticFilter, error) {
+	var f database.DiagnosticFilter
+
+	f.Statuses = splitList(q.Get("status"))
+	f.Model = strings.TrimSpace(q.Get("model"))
+	f.CPUModel = strings.TrimSpace(q.Get("cpu_model"))
+	f.BatteryHealths = splitList(q.Get("battery_health"))
+
+	if v := q.Get("from"); v != "" {
+		t, err := parseDateParam(v)
+		if err != nil {
+			return f, fmt.Errorf("from invalide: %v", err)
+		}
+		f.From = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := parseDateParam(v)
+		if err != nil {
+			return f, fmt.Errorf("to invalide: %v", err)
+		}
+		// Une date seule ("2025-12-31") inclut toute la journée
+		if len(v) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		f.To = &t
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return f, fmt.Errorf("from doit être antérieur à to")
+	}
+
+	// test=battery:failed, test=cpu (étape présente quel que soit son résultat)
+	for _, v := range splitList(q.Get("test")) {
+		name, outcome, _ := strings.Cut(v, ":")
+		if name == "" {
+			return f, fmt.Errorf("test doit préciser le nom de l'étape (ex: test=battery:failed)")
+		}
+		if outcome != "" && !models.IsValidTestOutcome(strings.ToLower(outcome)) {
+			return f, fmt.Errorf("résultat de test inconnu %q (valeurs possibles: %s)",
+				outcome, strings.Join(models.TestOutcomes, ", "))
+		}
+		f.Tests = append(f.Tests, database.TestCondition{Name: name, Outcome: outcome})
+	}
+	// failed_test=battery est un raccourci pour test=battery:failed
+	for _, name := range splitList(q.Get("failed_test")) {
+		f.Tests = append(f.Tests, database.TestCondition{Name: name, Outcome: models.TestOutcomeFailed})
+	}
+
+	if v := q.Get("battery_max_capacity_below"); v != "" {
+		pct, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return f, fmt.Errorf("battery_max_capacity_below doit être un nombre")
+		}
+		f.BatteryMaxCapacityBelow = &pct
+	}
+
+	return f, nil
+}
+
+// parseDateParam accepte une date RFC 3339 ou une date seule (AAAA-MM-JJ, UTC)
+func parseDateParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("format attendu: AAAA-MM-JJ ou RFC 3339")
+	}
+	return t, nil
+}
+
+// splitList découpe "a,b , c" en ["a", "b", "c"]
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/models"
+)
+
+// TestParseTestFilters vérifie la lecture de test et de son raccourci failed_test
+func TestParseTestFilters(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []database.TestCondition
+		wantErr bool
+	}{
+		{"failed_test=battery,storage", []database.TestCondition{
+			{Name: "battery", Outcome: models.TestOutcomeFailed},
+			{Name: "storage", Outcome: models.TestOutcomeFailed},
+		}, false},
+		{"test=cpu:passed&failed_test=battery", []database.TestCondition{
+			{Name: "cpu", Outcome: "passed"},
+			{Name: "battery", Outcome: models.TestOutcomeFailed},
+		}, false},
+		{"test=cpu", []database.TestCondition{{Name: "cpu"}}, false},
+		{"test=cpu:cassé", nil, true},
+		{"test=:failed", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts, err := parseListOptions(q)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("erreur %v, attendue %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(opts.Filter.Tests, tt.want) {
+				t.Errorf("conditions %+v, attendu %+v", opts.Filter.Tests, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// GetMachines récupère le registre des machines, les plus récemment vues en premier
+func (s *Server) GetMachines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "limit doit être un entier positif",
+			})
+			return
+		}
+		limit = l
+	}
+
+	machines, nextCursor, err := s.store.ListMachines(r.Context(), limit, r.URL.Query().Get("cursor"))
+	if errors.Is(err, database.ErrInvalidCursor) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de récupération des machines", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.MachinesListResponse{
+			Success: false,
+			Count:   0,
+		})
+		return
+	}
+
+	if machines == nil {
+		machines = []models.Machine{}
+	}
+
+	logging.FromContext(r.Context()).Debug("Machines récupérées", "count", len(machines))
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.MachinesListResponse{
+		Success:    true,
+		Count:      len(machines),
+		Machines:   machines,
+		NextCursor: nextCursor,
+	})
+}
+
+// GetMachine récupère une machine et le résumé de son dernier diagnostic
+func (s *Server) GetMachine(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	serialNumber := mux.Vars(r)["serial"]
+
+	machine, err := s.store.GetMachine(r.Context(), serialNumber)
+	if errors.Is(err, database.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Machine non trouvée",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de récupération de la machine", "serial_number", serialNumber, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Erreur lors de la récupération de la machine",
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Debug("Machine récupérée", "serial_number", serialNumber)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"machine": machine,
+	})
+}
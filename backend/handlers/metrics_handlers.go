@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"diagnostic-backend/logging"
+	"diagnostic-backend/metrics"
+)
+
+// Metrics expose les métriques au format texte Prometheus.
+// Les jauges de volume sont recalculées à chaque collecte.
+func (s *Server) Metrics(w http.ResponseWriter, r *http.Request) {
+	diagnostics, machines, err := s.store.Totals(r.Context())
+	if err != nil {
+		// Les compteurs restent utiles même si la base ne répond pas
+		logging.FromContext(r.Context()).Error("Erreur de comptage pour les métriques", "error", err)
+	} else {
+		metrics.DiagnosticsTotal.Set(float64(diagnostics))
+		metrics.MachinesTotal.Set(float64(machines))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := metrics.Default.Write(w); err != nil {
+		logging.FromContext(r.Context()).Warn("Écriture des métriques interrompue", "error", err)
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+	"diagnostic-backend/report"
+
+	"github.com/gorilla/mux"
+)
+
+// GetReport produit la fiche imprimable d'un diagnostic (format=html|pdf, html par défaut)
+func (s *Server) GetReport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "pdf" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("Format de fiche inconnu %q (valeurs possibles: html, pdf)", format),
+		})
+		return
+	}
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	diagnostic, err := s.store.GetDiagnosticByID(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Diagnostic non trouvé",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de récupération du diagnostic", "diagnostic_id", id, "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Erreur lors de la récupération du diagnostic",
+		})
+		return
+	}
+
+	if !canReadDiagnostic(r, diagnostic) {
+		writeForbidden(w, models.PermReadDiagnostics)
+		return
+	}
+
+	// Rendu complet avant l'envoi : une erreur donne un 500, pas une fiche tronquée
+	var buf bytes.Buffer
+	if format == "pdf" {
+		err = report.WritePDF(&buf, *diagnostic, time.Now())
+	} else {
+		err = report.WriteHTML(&buf, *diagnostic, time.Now())
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de génération de la fiche", "diagnostic_id", id, "format", format, "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Erreur lors de la génération de la fiche",
+		})
+		return
+	}
+
+	if format == "pdf" {
+		w.Header().Set("Content-Type", "application/pdf")
+		// inline : le navigateur l'affiche pour l'imprimer, le nom sert à l'enregistrement
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="fiche-%d.pdf"`, diagnostic.ID))
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"time"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/metrics"
+)
+
+// Options regroupe les réglages du serveur qui ne relèvent pas du store
+type Options struct {
+	// DataDir est le répertoire de la base, dont l'espace libre est vérifié par /readyz
+	DataDir string
+	// MinFreeDiskBytes est l'espace libre en dessous duquel le nœud n'est plus prêt
+	MinFreeDiskBytes uint64
+	// AuthRequired refuse les requêtes sans clé d'API sur les routes protégées
+	AuthRequired bool
+	// SignatureRequired refuse les diagnostics non signés par une station
+	SignatureRequired bool
+	// ClientCertRequired refuse les diagnostics envoyés sans certificat client
+	// vérifié (tls.client_auth: require) ; les autres routes n'en exigent pas
+	ClientCertRequired bool
+	// SignatureMaxSkew est l'écart toléré entre l'horodatage signé et l'heure du serveur
+	SignatureMaxSkew time.Duration
+	// CertificateKey signe les certificats de diagnostic
+	CertificateKey ed25519.PrivateKey
+	// MaxBodyBytes et MaxBatchBodyBytes bornent le body des envois (413 au-delà)
+	MaxBodyBytes      int64
+	MaxBatchBodyBytes int64
+	// RatePerSecond et RateBurst règlent la limitation de débit par clé ou IP (0 = aucune)
+	RatePerSecond float64
+	RateBurst     int
+}
+
+// Server regroupe les dépendances partagées par les handlers HTTP
+type Server struct {
+	store   database.Store
+	opts    Options
+	limiter *rateLimiter // nil si la limitation de débit est désactivée
+}
+
+// NewServer crée un serveur utilisant le store fourni
+func NewServer(store database.Store, opts Options) *Server {
+	s := &Server{store: store, opts: opts}
+	if opts.RatePerSecond > 0 {
+		s.limiter = newRateLimiter(opts.RatePerSecond, opts.RateBurst)
+	}
+
+	metrics.MaxBodyBytes.Set(float64(opts.MaxBodyBytes))
+	metrics.MaxBatchBodyBytes.Set(float64(opts.MaxBatchBodyBytes))
+	metrics.RateLimitPerSecond.Set(opts.RatePerSecond)
+	metrics.RateLimitBurst.Set(float64(opts.RateBurst))
+	return s
+}
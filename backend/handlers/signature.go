@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/metrics"
+	"diagnostic-backend/models"
+)
+
+// signaturePrefix précède le HMAC hexadécimal dans X-Signature
+const signaturePrefix = "sha256="
+
+// signPayload calcule la signature d'un envoi : HMAC-SHA256 du message
+// "<horodatage>.<body brut>" avec le secret partagé de la station
+func signPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// identifyStation identifie la station d'un envoi par son certificat client
+// (mTLS) et/ou par la signature HMAC du body ; présents ensemble, ils doivent
+// désigner la même station. En cas d'échec, la réponse est déjà écrite.
+func (s *Server) identifyStation(w http.ResponseWriter, r *http.Request, body []byte) (*models.StationSignature, bool) {
+	fromCert, ok := s.clientCertificateStation(w, r)
+	if !ok {
+		return nil, false
+	}
+	// Un certificat vérifié par la CA interne suffit à satisfaire signing.required
+	if fromCert != nil && r.Header.Get(models.HeaderSignature) == "" {
+		return fromCert, true
+	}
+
+	sig, ok := s.verifySignature(w, r, body)
+	if !ok {
+		return nil, false
+	}
+	if fromCert != nil && sig.StationID != fromCert.StationID {
+		rejectSignature(w, r, "station_mismatch", http.StatusUnauthorized,
+			"Le certificat client et la signature désignent deux stations différentes")
+		return nil, false
+	}
+	return sig, true
+}
+
+// clientCertificateStation retourne la station dont le nom est le CN du
+// certificat client vérifié par la CA interne (nil sans certificat, si
+// ClientCertRequired est désactivé)
+func (s *Server) clientCertificateStation(w http.ResponseWriter, r *http.Request) (*models.StationSignature, bool) {
+	// VerifiedChains n'est rempli que si le certificat a été vérifié (tls.client_auth)
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		if s.opts.ClientCertRequired {
+			rejectSignature(w, r, "missing_certificate", http.StatusUnauthorized,
+				"Certificat client requis pour l'envoi de diagnostics")
+			return nil, false
+		}
+		return nil, true
+	}
+	subject := r.TLS.VerifiedChains[0][0].Subject.CommonName
+
+	station, err := s.store.AuthenticateStation(r.Context(), subject)
+	if errors.Is(err, database.ErrNotFound) {
+		rejectSignature(w, r, "unknown_certificate", http.StatusUnauthorized,
+			"Aucune station active ne correspond au certificat client (CN="+subject+")")
+		return nil, false
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de lecture de la station", "station", subject, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Erreur lors de la vérification du certificat client",
+		})
+		return nil, false
+	}
+
+	logging.FromContext(r.Context()).Debug("Certificat client vérifié", "station", station.Name, "station_id", station.ID)
+	return &models.StationSignature{StationID: station.ID}, true
+}
+
+// verifySignature vérifie la signature d'une station sur le body brut.
+// Retourne nil pour un envoi non signé accepté (signing.required désactivé) ;
+// en cas d'échec, la réponse est déjà écrite.
+func (s *Server) verifySignature(w http.ResponseWriter, r *http.Request, body []byte) (*models.StationSignature, bool) {
+	stationName := r.Header.Get(models.HeaderStationID)
+	timestamp := r.Header.Get(models.HeaderSignatureTimestamp)
+	provided := r.Header.Get(models.HeaderSignature)
+
+	if stationName == "" && timestamp == "" && provided == "" {
+		if s.opts.SignatureRequired {
+			rejectSignature(w, r, "missing", http.StatusUnauthorized,
+				"Signature requise ("+models.HeaderStationID+", "+models.HeaderSignatureTimestamp+", "+models.HeaderSignature+")")
+			return nil, false
+		}
+		return nil, true
+	}
+	if stationName == "" || timestamp == "" || provided == "" {
+		rejectSignature(w, r, "incomplete", http.StatusUnauthorized, "En-têtes de signature incomplets")
+		return nil, false
+	}
+
+	// Refuser un envoi trop ancien (ou trop en avance) : une signature
+	// interceptée ne peut être rejouée que pendant cette fenêtre
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		rejectSignature(w, r, "timestamp", http.StatusUnauthorized, models.HeaderSignatureTimestamp+" invalide (secondes Unix attendues)")
+		return nil, false
+	}
+	signedAt := time.Unix(unix, 0).UTC()
+	if skew := time.Since(signedAt); skew > s.opts.SignatureMaxSkew || skew < -s.opts.SignatureMaxSkew {
+		rejectSignature(w, r, "expired", http.StatusUnauthorized, "Horodatage de signature hors de la fenêtre autorisée")
+		return nil, false
+	}
+
+	station, secret, err := s.store.GetStationSecret(r.Context(), stationName)
+	if errors.Is(err, database.ErrNotFound) {
+		rejectSignature(w, r, "unknown_station", http.StatusUnauthorized, "Station inconnue ou révoquée")
+		return nil, false
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de lecture de la station", "station", stationName, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Erreur lors de la vérification de la signature",
+		})
+		return nil, false
+	}
+
+	expected := signPayload(secret, timestamp, body)
+	given := strings.ToLower(strings.TrimPrefix(provided, signaturePrefix))
+	if !hmac.Equal([]byte(given), []byte(expected)) {
+		rejectSignature(w, r, "mismatch", http.StatusUnauthorized, "Signature invalide")
+		return nil, false
+	}
+
+	sig := models.StationSignature{StationID: station.ID, Signature: expected, SignedAt: signedAt}
+
+	// Une signature n'est acceptée qu'une fois : passé la fenêtre, elle est
+	// de toute façon refusée pour son horodatage. Elle est libérée si l'envoi
+	// est refusé ensuite (voir holdSignature).
+	err = s.store.RecordSignature(r.Context(), sig, 2*s.opts.SignatureMaxSkew)
+	if errors.Is(err, database.ErrSignatureReplayed) {
+		rejectSignature(w, r, "replayed", http.StatusConflict, "Signature déjà utilisée : signez à nouveau l'envoi avec un nouvel horodatage")
+		return nil, false
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur d'enregistrement de la signature", "station", stationName, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Erreur lors de la vérification de la signature",
+		})
+		return nil, false
+	}
+
+	logging.FromContext(r.Context()).Debug("Signature vérifiée", "station", station.Name, "station_id", station.ID)
+	return &sig, true
+}
+
+// signatureHold retient le statut de la réponse d'un envoi signé
+type signatureHold struct {
+	http.ResponseWriter
+	status int
+}
+
+func (h *signatureHold) WriteHeader(status int) {
+	if h.status == 0 {
+		h.status = status
+	}
+	h.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap donne accès au ResponseWriter d'origine (http.ResponseController)
+func (h *signatureHold) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
+
+// holdSignature retourne le ResponseWriter à utiliser pour la suite de
+// l'envoi et une fonction à différer : si la réponse est un refus (statut
+// >= 400 : décodage, validation, run_id en conflit, erreur de base), la
+// signature est libérée et l'envoi corrigé ou renvoyé n'est pas pris pour un rejeu
+func (s *Server) holdSignature(w http.ResponseWriter, r *http.Request, sig *models.StationSignature) (http.ResponseWriter, func()) {
+	if sig == nil || sig.Signature == "" {
+		// Envoi anonyme ou authentifié par certificat : rien n'a été enregistré
+		return w, func() {}
+	}
+
+	hold := &signatureHold{ResponseWriter: w}
+	return hold, func() {
+		if hold.status < http.StatusBadRequest {
+			return
+		}
+		// La requête peut avoir été annulée : la libération doit aboutir quand même
+		ctx := context.WithoutCancel(r.Context())
+		if err := s.store.ReleaseSignature(ctx, *sig); err != nil {
+			logging.FromContext(ctx).Error("Erreur de libération de la signature",
+				"station_id", sig.StationID, "error", err)
+		}
+	}
+}
+
+// rejectSignature écrit la réponse d'un envoi dont la signature ou le certificat est refusé
+func rejectSignature(w http.ResponseWriter, r *http.Request, reason string, status int, message string) {
+	metrics.SignatureFailures.Inc(reason)
+	logging.FromContext(r.Context()).Warn("Signature refusée",
+		"reason", reason, "station", r.Header.Get(models.HeaderStationID), "remote_addr", r.RemoteAddr)
+
+	errorCode := "invalid_signature"
+	switch reason {
+	case "replayed":
+		errorCode = "replayed_signature"
+	case "missing_certificate", "unknown_certificate", "station_mismatch":
+		errorCode = "invalid_client_certificate"
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Success: false,
+		Error:   errorCode,
+		Message: message,
+	})
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+// signedRequest prépare un envoi signé comme le ferait une station
+func signedRequest(body []byte, station, secret string, signedAt time.Time) *http.Request {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/diagnostics", bytes.NewReader(body))
+	req.Header.Set(models.HeaderStationID, station)
+	req.Header.Set(models.HeaderSignatureTimestamp, timestamp)
+	req.Header.Set(models.HeaderSignature, signaturePrefix+signPayload(secret, timestamp, body))
+	return req
+}
+
+// TestVerifySignature vérifie l'acceptation et les motifs de refus d'une signature HMAC
+func TestVerifySignature(t *testing.T) {
+	s, store, _ := newTestServer(t, Options{SignatureRequired: true, SignatureMaxSkew: 5 * time.Minute})
+	ctx := context.Background()
+
+	station, secret, err := store.CreateStation(ctx, "poste-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedSecret, err := store.CreateStation(ctx, "poste-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeStation(ctx, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"status":"success"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		want    int // 0 si la signature est acceptée
+	}{
+		{"signature valide", func() *http.Request {
+			return signedRequest(body, "poste-1", secret, now)
+		}, 0},
+		{"hexadécimal en majuscules", func() *http.Request {
+			req := signedRequest(body, "poste-1", secret, now.Add(-time.Second))
+			hexSig := strings.TrimPrefix(req.Header.Get(models.HeaderSignature), signaturePrefix)
+			req.Header.Set(models.HeaderSignature, signaturePrefix+strings.ToUpper(hexSig))
+			return req
+		}, 0},
+		{"sans préfixe sha256=", func() *http.Request {
+			req := signedRequest(body, "poste-1", secret, now.Add(-2*time.Second))
+			req.Header.Set(models.HeaderSignature, strings.TrimPrefix(req.Header.Get(models.HeaderSignature), signaturePrefix))
+			return req
+		}, 0},
+		{"mauvais secret", func() *http.Request {
+			return signedRequest(body, "poste-1", "autre-secret", now)
+		}, http.StatusUnauthorized},
+		{"body modifié après signature", func() *http.Request {
+			req := signedRequest(body, "poste-1", secret, now)
+			req.Body = io.NopCloser(strings.NewReader(`{"status":"failed"}`))
+			return req
+		}, http.StatusUnauthorized},
+		{"horodatage expiré", func() *http.Request {
+			return signedRequest(body, "poste-1", secret, now.Add(-10*time.Minute))
+		}, http.StatusUnauthorized},
+		{"horodatage dans le futur", func() *http.Request {
+			return signedRequest(body, "poste-1", secret, now.Add(10*time.Minute))
+		}, http.StatusUnauthorized},
+		{"horodatage illisible", func() *http.Request {
+			req := signedRequest(body, "poste-1", secret, now)
+			req.Header.Set(models.HeaderSignatureTimestamp, now.Format(time.RFC3339))
+			return req
+		}, http.StatusUnauthorized},
+		{"station inconnue", func() *http.Request {
+			return signedRequest(body, "poste-9", secret, now)
+		}, http.StatusUnauthorized},
+		{"station révoquée", func() *http.Request {
+			return signedRequest(body, "poste-2", revokedSecret, now)
+		}, http.StatusUnauthorized},
+		{"en-têtes incomplets", func() *http.Request {
+			req := signedRequest(body, "poste-1", secret, now)
+			req.Header.Del(models.HeaderSignatureTimestamp)
+			return req
+		}, http.StatusUnauthorized},
+		{"signature absente et requise", func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/api/v1/diagnostics", bytes.NewReader(body))
+		}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.request()
+			raw, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := httptest.NewRecorder()
+
+			sig, ok := s.identifyStation(rec, req, raw)
+			if tt.want == 0 {
+				if !ok {
+					t.Fatalf("signature refusée (%d) : %s", rec.Code, rec.Body)
+				}
+				if sig == nil || sig.StationID != station.ID {
+					t.Errorf("station %+v, attendu %d", sig, station.ID)
+				}
+				return
+			}
+			if ok {
+				t.Fatal("signature acceptée")
+			}
+			if rec.Code != tt.want {
+				t.Errorf("statut %d, attendu %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+// TestSignatureReplay vérifie qu'une signature n'est acceptée qu'une fois,
+// sauf si l'envoi qu'elle couvrait a été refusé
+func TestSignatureReplay(t *testing.T) {
+	s, store, _ := newTestServer(t, Options{SignatureRequired: true, SignatureMaxSkew: 5 * time.Minute})
+
+	_, secret, err := store.CreateStation(context.Background(), "poste-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := []byte(`{"system_info":{"machine_name":"MacBook Pro","serial_number":"C02SIGNED",` +
+		`"model":"MacBookPro18,3","os_version":"macOS 14.4.1"},"cpu":{"model":"Apple M1 Pro","cores":8},` +
+		`"ram":{"total":"16 GB"},"storage":{"type":"SSD","capacity":"512 GB"},"status":"success"}`)
+	invalid := []byte(`{"status":"success"}`)
+	signedAt := time.Now()
+
+	tests := []struct {
+		name string
+		body []byte
+		want []int // statuts des envois successifs de la même requête signée
+	}{
+		{"envoi accepté puis rejoué", valid, []int{http.StatusCreated, http.StatusConflict, http.StatusConflict}},
+		{"envoi refusé puis renvoyé", invalid, []int{http.StatusBadRequest, http.StatusBadRequest}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.want {
+				rec := httptest.NewRecorder()
+				s.CreateDiagnostic(rec, signedRequest(tt.body, "poste-1", secret, signedAt))
+				if rec.Code != want {
+					t.Errorf("envoi %d : statut %d, attendu %d (%s)", i+1, rec.Code, want, rec.Body)
+				}
+			}
+		})
+	}
+}
+
+// TestClientCertificateRequired vérifie qu'avec tls.client_auth: require,
+// l'envoi exige un certificat client vérifié, même signé par HMAC
+func TestClientCertificateRequired(t *testing.T) {
+	s, store, _ := newTestServer(t, Options{ClientCertRequired: true, SignatureMaxSkew: 5 * time.Minute})
+
+	station, secret, err := store.CreateStation(context.Background(), "poste-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"status":"success"}`)
+	verified := func(cn string) *tls.ConnectionState {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+
+	tests := []struct {
+		name string
+		tls  *tls.ConnectionState
+		sign bool
+		want int // 0 si l'envoi est accepté
+	}{
+		{"sans TLS", nil, false, http.StatusUnauthorized},
+		{"signé sans certificat", nil, true, http.StatusUnauthorized},
+		{"certificat non présenté", &tls.ConnectionState{}, false, http.StatusUnauthorized},
+		{"certificat vérifié", verified("poste-1"), false, 0},
+		{"certificat d'une station inconnue", verified("poste-9"), false, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/diagnostics", bytes.NewReader(body))
+			if tt.sign {
+				req = signedRequest(body, "poste-1", secret, time.Now())
+			}
+			req.TLS = tt.tls
+			rec := httptest.NewRecorder()
+
+			sig, ok := s.identifyStation(rec, req, body)
+			if tt.want == 0 {
+				if !ok || sig == nil || sig.StationID != station.ID {
+					t.Fatalf("envoi refusé (%d) : %s", rec.Code, rec.Body)
+				}
+				return
+			}
+			if ok {
+				t.Fatal("envoi accepté")
+			}
+			if rec.Code != tt.want || !strings.Contains(rec.Body.String(), `"invalid_client_certificate"`) {
+				t.Errorf("statut %d (%s), attendu %d invalid_client_certificate", rec.Code, rec.Body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// GetStations liste les stations enregistrées (sans leur secret)
+func (s *Server) GetStations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	stations, err := s.store.ListStations(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de récupération des stations", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.StationsListResponse{Success: false})
+		return
+	}
+
+	if stations == nil {
+		stations = []models.Station{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.StationsListResponse{
+		Success:  true,
+		Count:    len(stations),
+		Stations: stations,
+	})
+}
+
+// CreateStation enregistre une station ; son secret n'est retourné que dans cette réponse
+func (s *Server) CreateStation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.StationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.StationResponse{
+			Success: false,
+			Message: "Format JSON invalide: " + err.Error(),
+		})
+		return
+	}
+
+	name, err := models.ParseStationName(req.Name)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.StationResponse{
+			Success: false,
+			Message: "Validation échouée: " + err.Error(),
+		})
+		return
+	}
+
+	station, secret, err := s.store.CreateStation(r.Context(), name)
+	if errors.Is(err, database.ErrDuplicateStation) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(models.StationResponse{
+			Success: false,
+			Message: "Une station porte déjà ce nom",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de création de la station", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.StationResponse{
+			Success: false,
+			Message: "Erreur lors de la création de la station: " + err.Error(),
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Station créée", "station_id", station.ID, "station", station.Name)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.StationResponse{
+		Success: true,
+		Message: "Station créée : conservez son secret, il ne sera plus affiché",
+		Secret:  secret,
+		Station: station,
+	})
+}
+
+// RotateStationSecret remplace le secret d'une station ; l'ancien est aussitôt refusé
+func (s *Server) RotateStationSecret(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	station, secret, err := s.store.RotateStationSecret(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.StationResponse{
+			Success: false,
+			Message: "Station non trouvée ou révoquée",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de renouvellement du secret de station", "station_id", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.StationResponse{
+			Success: false,
+			Message: "Erreur lors du renouvellement du secret: " + err.Error(),
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Secret de station renouvelé", "station_id", station.ID, "station", station.Name)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.StationResponse{
+		Success: true,
+		Message: "Secret renouvelé : conservez-le, il ne sera plus affiché",
+		Secret:  secret,
+		Station: station,
+	})
+}
+
+// RevokeStation refuse désormais les envois signés par la station
+func (s *Server) RevokeStation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	err := s.store.RevokeStation(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.StationResponse{
+			Success: false,
+			Message: "Station non trouvée ou déjà révoquée",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de révocation de la station", "station_id", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.StationResponse{
+			Success: false,
+			Message: "Erreur lors de la révocation de la station: " + err.Error(),
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Station révoquée", "station_id", id)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.StationResponse{
+		Success: true,
+		Message: "Station révoquée",
+	})
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"diagnostic-backend/database"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// GetUsers liste les utilisateurs et leur rôle
+func (s *Server) GetUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	users, err := s.store.ListUsers(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de récupération des utilisateurs", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.UsersListResponse{Success: false})
+		return
+	}
+
+	if users == nil {
+		users = []models.User{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.UsersListResponse{
+		Success: true,
+		Count:   len(users),
+		Users:   users,
+	})
+}
+
+// CreateUser crée un utilisateur avec son rôle
+func (s *Server) CreateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.UserResponse{
+			Success: false,
+			Message: "Format JSON invalide: " + err.Error(),
+		})
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	role, err := models.ParseRole(req.Role)
+	if err == nil && req.Username == "" {
+		err = errors.New("username est requis")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.UserResponse{
+			Success: false,
+			Message: "Validation échouée: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := s.store.CreateUser(r.Context(), req.Username, strings.TrimSpace(req.DisplayName), role)
+	if errors.Is(err, database.ErrDuplicateUsername) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(models.UserResponse{
+			Success: false,
+			Message: "Un utilisateur porte déjà ce nom",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de création de l'utilisateur", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.UserResponse{
+			Success: false,
+			Message: "Erreur lors de la création de l'utilisateur: " + err.Error(),
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Utilisateur créé", "user_id", user.ID, "username", user.Username, "role", user.Role)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Success: true,
+		Message: "Utilisateur créé",
+		User:    user,
+	})
+}
+
+// SetUserRole change le rôle d'un utilisateur ({"role": "manager"})
+func (s *Server) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	var req models.UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.UserResponse{
+			Success: false,
+			Message: "Format JSON invalide: " + err.Error(),
+		})
+		return
+	}
+
+	role, err := models.ParseRole(req.Role)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.UserResponse{
+			Success: false,
+			Message: "Validation échouée: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := s.store.SetUserRole(r.Context(), id, role)
+	if errors.Is(err, database.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.UserResponse{
+			Success: false,
+			Message: "Utilisateur non trouvé",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de modification du rôle", "user_id", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.UserResponse{
+			Success: false,
+			Message: "Erreur lors de la modification du rôle: " + err.Error(),
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Rôle modifié", "user_id", user.ID, "username", user.Username, "role", user.Role)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Success: true,
+		Message: "Rôle modifié",
+		User:    user,
+	})
+}
+
+// DisableUser désactive un utilisateur et toutes ses clés
+func (s *Server) DisableUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+
+	err := s.store.DisableUser(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.UserResponse{
+			Success: false,
+			Message: "Utilisateur non trouvé ou déjà désactivé",
+		})
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Erreur de désactivation de l'utilisateur", "user_id", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.UserResponse{
+			Success: false,
+			Message: "Erreur lors de la désactivation de l'utilisateur: " + err.Error(),
+		})
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Utilisateur désactivé", "user_id", id)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Success: true,
+		Message: "Utilisateur désactivé",
+	})
+}
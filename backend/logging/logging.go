@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// contextKey évite les collisions avec les clés de contexte d'autres paquets
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	loggerKey
+)
+
+// Setup crée le logger global à partir du niveau (debug, info, warn, error)
+// et du format (json, text), et l'installe comme logger par défaut :
+// les appels restants au paquet log standard passent aussi par lui.
+func Setup(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		lvl = slog.LevelDebug
+	case "", "info":
+		lvl = slog.LevelInfo
+	case "warn", "warning":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		return nil, fmt.Errorf("niveau de log inconnu %q (debug, info, warn, error)", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("format de log inconnu %q (json, text)", format)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	log.SetFlags(0) // l'horodatage est ajouté par slog
+
+	return logger, nil
+}
+
+// NewRequestID génère un identifiant de requête aléatoire (16 caractères hexadécimaux)
+func NewRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// WithRequestID attache l'identifiant de requête au contexte,
+// ainsi qu'un logger qui l'ajoute à chaque message
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey, requestID)
+	return context.WithValue(ctx, loggerKey, slog.Default().With("request_id", requestID))
+}
+
+// RequestID retourne l'identifiant de requête du contexte ("" si absent)
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// FromContext retourne le logger de la requête, ou le logger par défaut
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With retourne un contexte dont le logger ajoute les attributs donnés
+// (ex: la clé d'API authentifiée) à chaque message
+func With(ctx context.Context, args ...any) context.Context {
+	return context.WithValue(ctx, loggerKey, FromContext(ctx).With(args...))
+}
@@ -1,43 +1,106 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 
+	"diagnostic-backend/certificate"
+	"diagnostic-backend/config"
 	"diagnostic-backend/database"
 	"diagnostic-backend/handlers"
-)
-
-const (
-	defaultPort   = "8080"
-	defaultDBPath = "./diagnostics.db"
+	"diagnostic-backend/logging"
+	"diagnostic-backend/metrics"
+	"diagnostic-backend/models"
 )
 
 func main() {
-	log.Println("Démarrage du backend de diagnostic...")
+	// Configuration : valeurs par défaut, puis config.yaml (ou CONFIG_FILE),
+	// puis variables d'environnement
+	cfg, err := config.Load()
+	if err != nil {
+		// Les logs ne sont pas encore configurés : message lisible sur stderr
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Logs structurés (JSON par défaut, "text" pour le développement)
+	if _, err := logging.Setup(os.Stderr, cfg.Log.Level, cfg.Log.Format); err != nil {
+		slog.Error("Configuration des logs invalide", "error", err)
+		os.Exit(1)
+	}
 
-	// Configuration
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	// Sous-commandes CLI (ex: "migrate status")
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:], cfg); err != nil {
+			slog.Error("Commande échouée", "command", os.Args[1], "error", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	//:= : Déclaration + assignation (type inféré)
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = defaultDBPath
+	slog.Info("Démarrage du backend de diagnostic...", "config_file", cfg.Source)
+
+	// Clé de signature des certificats, générée au premier démarrage
+	certKey, created, err := certificate.LoadOrCreateKey(cfg.Certificates.KeyFile)
+	if err != nil {
+		slog.Error("Erreur de chargement de la clé de signature des certificats", "error", err)
+		os.Exit(1)
+	}
+	certKeyID := certificate.KeyID(certKey.Public().(ed25519.PublicKey))
+	if created {
+		slog.Warn("Clé de signature des certificats générée : la sauvegarder, les certificats émis en dépendent",
+			"path", cfg.Certificates.KeyFile, "key_id", certKeyID)
+	}
+
+	// Certificats HTTPS (et CA des stations en mTLS), rechargés à chaud
+	var certs *certReloader
+	if cfg.TLS.Enabled() {
+		certs, err = newCertReloader(cfg.TLS)
+		if err != nil {
+			slog.Error("Erreur de chargement des certificats TLS", "error", err)
+			os.Exit(1)
+		}
 	}
 
 	// Initialiser la base de données
-	if err := database.InitDB(dbPath); err != nil {
-		log.Fatalf(" Erreur d'initialisation de la base de données: %v", err)
+	dbPath := cfg.Database.Path
+	store, err := database.NewSQLiteStore(dbPath, cfg.Database.Pragmas)
+	if err != nil {
+		slog.Error("Erreur d'initialisation de la base de données", "error", err, "db_path", dbPath)
+		os.Exit(1)
+	}
+
+	// Les handlers reçoivent le store par injection
+	srv := handlers.NewServer(store, handlers.Options{
+		DataDir:            filepath.Dir(dbPath),
+		MinFreeDiskBytes:   cfg.Server.MinFreeDiskMB << 20,
+		AuthRequired:       cfg.Auth.Required,
+		SignatureRequired:  cfg.Signing.Required,
+		ClientCertRequired: cfg.TLS.ClientAuth == "require",
+		SignatureMaxSkew:   cfg.Signing.MaxSkew.D(),
+		CertificateKey:     certKey,
+		MaxBodyBytes:       int64(cfg.Limits.MaxBodyMB) << 20,
+		MaxBatchBodyBytes:  int64(cfg.Limits.MaxBatchBodyMB) << 20,
+		RatePerSecond:      cfg.Limits.RatePerSecond,
+		RateBurst:          cfg.Limits.Burst,
+	})
+	if !cfg.Auth.Required {
+		slog.Warn("Authentification facultative : les requêtes sans clé d'API sont acceptées (auth.required: false)")
 	}
-	defer database.CloseDB() //defer = exécute à la fin de la fonction main
 
 	// Créer le routeur
 	router := mux.NewRouter()
@@ -46,64 +109,266 @@ func main() {
 	api := router.PathPrefix("/api/v1").Subrouter()
 
 	// Health check
-	api.HandleFunc("/health", handlers.HealthCheck).Methods("GET")
+	api.HandleFunc("/health", srv.HealthCheck).Methods("GET")
 
+	// Chaque route déclare la permission requise (voir models.RolePermissions)
+	// Les envois sont limités par IP avant l'authentification, puis par clé d'API
 	// Diagnostics
-	api.HandleFunc("/diagnostics", handlers.CreateDiagnostic).Methods("POST")
-	api.HandleFunc("/diagnostics", handlers.GetDiagnostics).Methods("GET")
-	api.HandleFunc("/diagnostics/{id:[0-9]+}", handlers.GetDiagnosticByID).Methods("GET")
-	api.HandleFunc("/diagnostics/serial/{serial}", handlers.GetDiagnosticsBySerial).Methods("GET")
+	api.HandleFunc("/diagnostics", srv.RateLimit(srv.Require(models.PermSubmitDiagnostics, srv.RateLimitKey(srv.CreateDiagnostic)))).Methods("POST")
+	api.HandleFunc("/diagnostics/batch", srv.RateLimit(srv.Require(models.PermSubmitDiagnostics, srv.RateLimitKey(srv.CreateDiagnosticsBatch)))).Methods("POST")
+	api.HandleFunc("/import", srv.RateLimit(srv.Require(models.PermImportDiagnostics, srv.RateLimitKey(srv.ImportDiagnostics)))).Methods("POST")
+	api.HandleFunc("/diagnostics", srv.Require(models.PermReadOwnDiagnostics, srv.GetDiagnostics)).Methods("GET")
+	api.HandleFunc("/diagnostics/export", srv.Require(models.PermReadOwnDiagnostics, srv.ExportDiagnostics)).Methods("GET")
+	api.HandleFunc("/diagnostics/{id:[0-9]+}", srv.Require(models.PermReadOwnDiagnostics, srv.GetDiagnosticByID)).Methods("GET")
+	api.HandleFunc("/diagnostics/{id:[0-9]+}", srv.Require(models.PermDeleteDiagnostics, srv.DeleteDiagnostic)).Methods("DELETE")
+	api.HandleFunc("/diagnostics/{id:[0-9]+}/report", srv.Require(models.PermReadOwnDiagnostics, srv.GetReport)).Methods("GET")
+	api.HandleFunc("/diagnostics/{id:[0-9]+}/certificate", srv.Require(models.PermReadOwnDiagnostics, srv.GetCertificate)).Methods("GET")
+	api.HandleFunc("/diagnostics/serial/{serial}", srv.Require(models.PermReadOwnDiagnostics, srv.GetDiagnosticsBySerial)).Methods("GET")
+
+	// Registre des machines
+	api.HandleFunc("/machines", srv.Require(models.PermReadDiagnostics, srv.GetMachines)).Methods("GET")
+	api.HandleFunc("/machines/{serial}", srv.Require(models.PermReadDiagnostics, srv.GetMachine)).Methods("GET")
 
 	// Statistiques
-	api.HandleFunc("/statistics", handlers.GetStatistics).Methods("GET")
+	api.HandleFunc("/statistics", srv.Require(models.PermReadStatistics, srv.GetStatistics)).Methods("GET")
+
+	// Intégrité : chaîne de hachage, et clé publique des certificats (sans authentification)
+	api.HandleFunc("/chain/verify", srv.Require(models.PermVerifyChain, srv.VerifyChain)).Methods("GET")
+	api.HandleFunc("/certificates/public-key", srv.CertificatePublicKey).Methods("GET")
+
+	// Administration des clés d'API et des utilisateurs
+	api.HandleFunc("/admin/keys", srv.Require(models.PermManageAccess, srv.GetAPIKeys)).Methods("GET")
+	api.HandleFunc("/admin/keys", srv.Require(models.PermManageAccess, srv.CreateAPIKey)).Methods("POST")
+	api.HandleFunc("/admin/keys/{id:[0-9]+}/rotate", srv.Require(models.PermManageAccess, srv.RotateAPIKey)).Methods("POST")
+	api.HandleFunc("/admin/keys/{id:[0-9]+}", srv.Require(models.PermManageAccess, srv.RevokeAPIKey)).Methods("DELETE")
+	api.HandleFunc("/admin/users", srv.Require(models.PermManageAccess, srv.GetUsers)).Methods("GET")
+	api.HandleFunc("/admin/users", srv.Require(models.PermManageAccess, srv.CreateUser)).Methods("POST")
+	api.HandleFunc("/admin/users/{id:[0-9]+}/role", srv.Require(models.PermManageAccess, srv.SetUserRole)).Methods("PUT")
+	api.HandleFunc("/admin/users/{id:[0-9]+}", srv.Require(models.PermManageAccess, srv.DisableUser)).Methods("DELETE")
+	api.HandleFunc("/admin/stations", srv.Require(models.PermManageAccess, srv.GetStations)).Methods("GET")
+	api.HandleFunc("/admin/stations", srv.Require(models.PermManageAccess, srv.CreateStation)).Methods("POST")
+	api.HandleFunc("/admin/stations/{id:[0-9]+}/rotate", srv.Require(models.PermManageAccess, srv.RotateStationSecret)).Methods("POST")
+	api.HandleFunc("/admin/stations/{id:[0-9]+}", srv.Require(models.PermManageAccess, srv.RevokeStation)).Methods("DELETE")
 
-	// Middleware de logging
+	// Sondes pour le superviseur : vivant (processus) et prêt (base, schéma, disque)
+	router.HandleFunc("/livez", srv.Livez).Methods("GET")
+	router.HandleFunc("/readyz", srv.Readyz).Methods("GET")
+
+	// Métriques Prometheus (hors /api/v1, comme le veut la convention), lues
+	// avec une clé de portée read (authorization du scrape_config Prometheus)
+	router.HandleFunc("/metrics", srv.Require(models.PermReadMetrics, srv.Metrics)).Methods("GET")
+
+	// Le modèle de la route servie est transmis à loggingMiddleware
 	//Un middleware est un intercepteur qui s'exécute avant chaque requête (comme un filtre en Java).
-	router.Use(loggingMiddleware)
+	router.Use(routeMiddleware)
 
 	// Configuration CORS
 	//Sans CORS, le navigateur bloque les requêtes cross-origin qui permettent de communiquer entre le frontend et le backend.
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"}, // En production, spécifier les origines exactes
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowCredentials: false,
-		MaxAge:           300,
+		AllowedHeaders:   append([]string{"Content-Type", "Authorization", "Idempotency-Key", requestIDHeader}, signatureHeaders...),
+		ExposedHeaders:   []string{requestIDHeader, "Idempotent-Replayed", "Retry-After"},
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           int(cfg.CORS.MaxAge.D().Seconds()),
 	})
 
-	handler := c.Handler(router)
+	// Logging et métriques autour du routeur et de CORS : les requêtes sans
+	// route (404, 405, pré-vol CORS) sont aussi comptées
+	handler := loggingMiddleware(c.Handler(router))
+
+	scheme := "http"
+	if cfg.TLS.Enabled() {
+		scheme = "https"
+	}
+	slog.Info("Serveur démarré", "listen", cfg.Server.Listen, "tls", cfg.TLS.Enabled(), "client_auth", cfg.TLS.ClientAuth, "db_path", dbPath,
+		"api", scheme+"://"+displayAddr(cfg.Server.Listen)+"/api/v1")
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+		if err == nil && len(methods) > 0 {
+			slog.Debug("Endpoint disponible", "methods", methods, "path", tpl)
+		}
+		return nil
+	})
+
+	server := &http.Server{
+		Addr:              cfg.Server.Listen,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.Server.ReadTimeout.D(),
+		ReadTimeout:       cfg.Server.ReadTimeout.D(),
+		WriteTimeout:      cfg.Server.WriteTimeout.D(),
+		IdleTimeout:       cfg.Server.IdleTimeout.D(),
+	}
+	if certs != nil {
+		server.TLSConfig = certs.tlsConfig()
+	}
+
+	// Arrêt propre sur SIGINT/SIGTERM : on cesse d'accepter des connexions et
+	// on laisse aux requêtes en cours (ex: un POST de lot) le délai de grâce
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Purge périodique des anciennes données
+	retentionDone := make(chan struct{})
+	go func() {
+		defer close(retentionDone)
+		runRetention(ctx, store, cfg.Retention)
+	}()
+
+	if certs != nil {
+		go certs.watch(ctx)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if certs != nil {
+			// Certificats fournis par server.TLSConfig
+			serveErr <- server.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	exitCode := 0
+	shutdownTimeout := cfg.Server.ShutdownTimeout.D()
+	select {
+	case err := <-serveErr:
+		slog.Error("Erreur du serveur", "error", err)
+		exitCode = 1
+	case <-ctx.Done():
+		stop() // un second signal interrompt immédiatement
+		slog.Info("Arrêt demandé, fin des requêtes en cours", "grace_period", shutdownTimeout.String())
 
-	log.Printf("Serveur démarré sur le port %s", port)
-	log.Printf("API disponible sur http://localhost:%s/api/v1", port)
-	log.Printf(" Health check: http://localhost:%s/api/v1/health", port)
-	log.Printf(" Base de données: %s", dbPath)
-	log.Println(" Endpoints disponibles:")
-	log.Println("   POST   /api/v1/diagnostics")
-	log.Println("   GET    /api/v1/diagnostics")
-	log.Println("   GET    /api/v1/diagnostics/{id}")
-	log.Println("   GET    /api/v1/diagnostics/serial/{serial}")
-	log.Println("   GET    /api/v1/statistics")
-	log.Println("")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Délai de grâce dépassé, connexions fermées de force", "error", err)
+			server.Close()
+			exitCode = 1
+		}
+		cancel()
+	}
+
+	// Fermer la base après la dernière requête et la dernière purge (checkpoint du WAL)
+	stop()
+	<-retentionDone
+	if err := store.Close(); err != nil {
+		slog.Error("Erreur de fermeture de la base de données", "error", err)
+		exitCode = 1
+	}
+
+	slog.Info("Serveur arrêté")
+	os.Exit(exitCode)
+}
 
-	// Démarrer le serveur
-	if err := http.ListenAndServe(":"+port, handler); err != nil {
-		log.Fatalf(" Erreur du serveur: %v", err)
+// displayAddr complète une adresse d'écoute sans hôte (":8080") pour l'affichage
+func displayAddr(listen string) string {
+	if strings.HasPrefix(listen, ":") {
+		return "localhost" + listen
 	}
+	return listen
 }
 
-// loggingMiddleware enregistre toutes les requêtes
+// signatureHeaders sont les en-têtes d'un envoi signé par une station
+var signatureHeaders = []string{models.HeaderStationID, models.HeaderSignatureTimestamp, models.HeaderSignature}
+
+// requestIDHeader transporte l'identifiant de requête entre client, proxy et serveur
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID limite les identifiants fournis par le client à une forme sûre pour les logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// statusRecorder retient le statut et la taille de la réponse pour le log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap donne accès au ResponseWriter d'origine (http.ResponseController)
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// routeLabel reçoit du routeur le modèle de la route servie ; il reste vide
+// pour une requête sans route
+type routeLabel struct {
+	template string
+}
+
+type routeLabelKey struct{}
+
+// routeMiddleware transmet à loggingMiddleware, qui enveloppe le routeur et
+// ne voit donc pas la route choisie, le modèle de la route servie
+func routeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if label, ok := r.Context().Value(routeLabelKey{}).(*routeLabel); ok {
+			if current := mux.CurrentRoute(r); current != nil {
+				if tpl, err := current.GetPathTemplate(); err == nil {
+					label.template = tpl
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loggingMiddleware attribue un identifiant à chaque requête (repris de
+// X-Request-ID s'il est fourni), enregistre une ligne par requête traitée
+// et alimente les métriques HTTP
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		// Log de la requête
-		log.Printf("%s %s - %s", r.Method, r.RequestURI, r.RemoteAddr)
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = logging.NewRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		ctx := logging.WithRequestID(r.Context(), requestID)
+		label := &routeLabel{}
+		ctx = context.WithValue(ctx, routeLabelKey{}, label)
+		rec := &statusRecorder{ResponseWriter: w}
 
 		// Passer à la prochaine étape
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(rec, r.WithContext(ctx))
 
-		// Log du temps de traitement
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
 		duration := time.Since(start)
-		log.Printf("Traité en %v", duration)
+
+		// Le modèle de route ("/api/v1/diagnostics/{id}") borne le nombre de
+		// séries ; les chemins sans route sont regroupés
+		route := "unmatched"
+		if label.template != "" {
+			route = label.template
+		}
+		metrics.HTTPRequests.Inc(r.Method, route, strconv.Itoa(rec.status))
+		metrics.HTTPDuration.Observe(duration.Seconds(), r.Method, route)
+
+		logging.FromContext(ctx).Info("Requête traitée",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration_ms", float64(duration.Microseconds())/1000,
+			"remote_addr", r.RemoteAddr,
+		)
 	})
 }
@@ -0,0 +1,69 @@
+package metrics
+
+// Métriques exposées par le backend sur /metrics
+
+var (
+	// HTTPRequests compte les requêtes par route (modèle mux), méthode et statut
+	HTTPRequests = NewCounterVec("diagnostic_http_requests_total",
+		"Nombre de requêtes HTTP traitées.", "method", "route", "status")
+
+	// HTTPDuration mesure la latence des requêtes par route et méthode
+	HTTPDuration = NewHistogramVec("diagnostic_http_request_duration_seconds",
+		"Durée de traitement des requêtes HTTP en secondes.", DefaultBuckets, "method", "route")
+
+	// ValidationFailures compte les diagnostics rejetés par champ en erreur
+	ValidationFailures = NewCounterVec("diagnostic_validation_failures_total",
+		"Nombre de diagnostics rejetés par la validation, par champ.", "field")
+
+	// SignatureFailures compte les envois signés refusés, par motif
+	SignatureFailures = NewCounterVec("diagnostic_signature_failures_total",
+		"Nombre d'envois refusés à la vérification de signature, par motif.", "reason")
+
+	// RateLimited compte les requêtes refusées par la limitation de débit (429)
+	RateLimited = NewCounterVec("diagnostic_rate_limited_total",
+		"Nombre de requêtes refusées par la limitation de débit, par route.", "route")
+
+	// BodyTooLarge compte les requêtes refusées pour un body trop volumineux (413)
+	BodyTooLarge = NewCounterVec("diagnostic_request_body_too_large_total",
+		"Nombre de requêtes refusées pour un body trop volumineux, par route.", "route")
+
+	// RateLimitClients est le nombre de clients (clés ou IP) suivis par la limitation de débit
+	RateLimitClients = NewGauge("diagnostic_rate_limit_clients",
+		"Nombre de clients (clés d'API ou IP) suivis par la limitation de débit.")
+
+	// RateLimitPerSecond est le débit soutenu configuré par client (0 = pas de limite)
+	RateLimitPerSecond = NewGauge("diagnostic_rate_limit_per_second",
+		"Débit soutenu autorisé par client en requêtes par seconde (0 = pas de limite).")
+
+	// RateLimitBurst est le nombre de requêtes acceptées d'affilée par client
+	RateLimitBurst = NewGauge("diagnostic_rate_limit_burst",
+		"Nombre de requêtes acceptées d'affilée par client.")
+
+	// MaxBodyBytes est la taille maximale du body d'un diagnostic
+	MaxBodyBytes = NewGauge("diagnostic_max_body_bytes",
+		"Taille maximale du body de POST /diagnostics en octets.")
+
+	// MaxBatchBodyBytes est la taille maximale du body d'un lot
+	MaxBatchBodyBytes = NewGauge("diagnostic_max_batch_body_bytes",
+		"Taille maximale du body de POST /diagnostics/batch en octets.")
+
+	// TLSReloads compte les rechargements à chaud des certificats TLS, par résultat
+	TLSReloads = NewCounterVec("diagnostic_tls_reloads_total",
+		"Nombre de rechargements des certificats TLS depuis le disque, par résultat.", "result")
+
+	// TLSCertificateNotAfter est la date d'expiration du certificat serveur en service
+	TLSCertificateNotAfter = NewGauge("diagnostic_tls_certificate_not_after_seconds",
+		"Date d'expiration du certificat TLS du serveur (secondes Unix).")
+
+	// DBQueryDuration mesure la durée des opérations du store
+	DBQueryDuration = NewHistogramVec("diagnostic_db_query_duration_seconds",
+		"Durée des opérations de base de données en secondes.", DefaultBuckets, "operation")
+
+	// DiagnosticsTotal est le nombre de diagnostics enregistrés (mis à jour à chaque collecte)
+	DiagnosticsTotal = NewGauge("diagnostic_rows_total",
+		"Nombre de diagnostics enregistrés (lignes de la table diagnostics).")
+
+	// MachinesTotal est le nombre de machines distinctes du registre
+	MachinesTotal = NewGauge("diagnostic_machines",
+		"Nombre de machines distinctes (numéros de série) connues.")
+)
@@ -0,0 +1,274 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Implémentation minimale du format texte Prometheus (compteurs, jauges et
+// histogrammes avec labels), pour ne pas dépendre de client_golang.
+// Référence: https://prometheus.io/docs/instrumenting/exposition_formats/
+
+// DefaultBuckets sont les bornes (en secondes) des histogrammes de latence
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector est une famille de métriques exposée par le registre
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry regroupe les métriques exposées sur /metrics
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// Default est le registre utilisé par les métriques du paquet
+var Default = &Registry{}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Write écrit toutes les métriques au format texte Prometheus
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// series est l'ensemble des valeurs de labels d'une famille, indexé par clé
+type series struct {
+	labels []string
+}
+
+func (s *series) key(values []string) string {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: %d valeurs de labels pour %d labels", len(values), len(s.labels)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// sorted retourne les clés triées pour une sortie stable
+func sorted[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec est un compteur monotone, éventuellement décliné par labels
+type CounterVec struct {
+	name, help string
+	series
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounterVec crée et enregistre un compteur dans le registre par défaut
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, series: series{labels: labels}, values: make(map[string]float64)}
+	Default.register(c)
+	return c
+}
+
+// Inc incrémente le compteur pour les valeurs de labels données
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add ajoute v (positif) au compteur
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	k := c.key(labelValues)
+	c.mu.Lock()
+	c.values[k] += v
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.name, c.help, "counter")
+	for _, k := range sorted(c.values) {
+		writeSample(w, c.name, c.labels, splitKey(k, len(c.labels)), "", "", c.values[k])
+	}
+}
+
+// Gauge est une valeur instantanée sans label
+type Gauge struct {
+	name, help string
+	mu         sync.Mutex
+	value      float64
+}
+
+// NewGauge crée et enregistre une jauge dans le registre par défaut
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	Default.register(g)
+	return g
+}
+
+// Set fixe la valeur de la jauge
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.value = v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	writeHeader(w, g.name, g.help, "gauge")
+	writeSample(w, g.name, nil, nil, "", "", g.value)
+}
+
+// HistogramVec répartit des observations (ex: durées) dans des buckets cumulés
+type HistogramVec struct {
+	name, help string
+	series
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // une case par bucket (non cumulée)
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec crée et enregistre un histogramme dans le registre par défaut
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		name:    name,
+		help:    help,
+		series:  series{labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogram),
+	}
+	Default.register(h)
+	return h
+}
+
+// Observe enregistre une valeur pour les valeurs de labels données
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	k := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist, ok := h.values[k]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hist
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hist.counts[i]++
+			break
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+// ObserveSince enregistre la durée écoulée depuis start, en secondes
+func (h *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, k := range sorted(h.values) {
+		hist := h.values[k]
+		values := splitKey(k, len(h.labels))
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += hist.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, values, "le", formatFloat(upper), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, values, "le", "+Inf", float64(hist.count))
+		writeSample(w, h.name+"_sum", h.labels, values, "", "", hist.sum)
+		writeSample(w, h.name+"_count", h.labels, values, "", "", float64(hist.count))
+	}
+}
+
+func splitKey(k string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	return strings.Split(k, "\xff")
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// writeSample écrit une ligne "nom{labels} valeur" ; extraName/extraValue
+// ajoutent un label supplémentaire (le "le" des buckets)
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabel(values[i]))
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
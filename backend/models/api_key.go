@@ -0,0 +1,109 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Portées des clés d'API
+const (
+	ScopeIngest = "ingest" // envoi de diagnostics (stations)
+	ScopeRead   = "read"   // consultation des diagnostics et des machines
+	ScopeAdmin  = "admin"  // gestion des clés, implique toutes les autres portées
+)
+
+// Scopes liste les portées acceptées
+var Scopes = []string{ScopeIngest, ScopeRead, ScopeAdmin}
+
+// APIKey représente une clé d'API (jamais la clé elle-même, seulement son préfixe)
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// User est l'utilisateur rattaché (nil pour une clé de station)
+	User *User `json:"user,omitempty"`
+}
+
+// Permissions retourne les droits de la clé : ceux de ses portées,
+// limités à ceux du rôle de l'utilisateur rattaché
+func (k *APIKey) Permissions() map[Permission]bool {
+	perms := make(map[Permission]bool)
+	for _, scope := range k.Scopes {
+		for _, p := range ScopePermissions[scope] {
+			perms[p] = true
+		}
+	}
+
+	if k.User != nil {
+		allowed := make(map[Permission]bool)
+		for _, p := range RolePermissions[k.User.Role] {
+			allowed[p] = true
+		}
+		for p := range perms {
+			if !allowed[p] {
+				delete(perms, p)
+			}
+		}
+	}
+
+	return perms
+}
+
+// ParseScopes valide une liste de portées ("ingest,read")
+func ParseScopes(list []string) ([]string, error) {
+	var scopes []string
+	seen := make(map[string]bool)
+
+	for _, s := range list {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || seen[s] {
+			continue
+		}
+		valid := false
+		for _, known := range Scopes {
+			if s == known {
+				valid = true
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("portée inconnue %q (valeurs possibles: %s)", s, strings.Join(Scopes, ", "))
+		}
+		seen[s] = true
+		scopes = append(scopes, s)
+	}
+
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("au moins une portée est requise (%s)", strings.Join(Scopes, ", "))
+	}
+	return scopes, nil
+}
+
+// APIKeyRequest représente la création d'une clé via l'API d'administration
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	UserID *int64   `json:"user_id,omitempty"` // rattache la clé à un utilisateur
+}
+
+// APIKeyResponse représente une clé créée ou renouvelée.
+// Key (la clé en clair) n'est retournée qu'à cette occasion.
+type APIKeyResponse struct {
+	Success bool    `json:"success"`
+	Message string  `json:"message"`
+	Key     string  `json:"key,omitempty"`
+	APIKey  *APIKey `json:"api_key,omitempty"`
+}
+
+// APIKeysListResponse représente la liste des clés
+type APIKeysListResponse struct {
+	Success bool     `json:"success"`
+	Count   int      `json:"count"`
+	Keys    []APIKey `json:"keys"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// CanonicalJSON retourne la forme JSON canonique de v : clés triées à tous
+// les niveaux, sans espaces, nombres recopiés tels quels. Deux documents
+// équivalents (ex: un certificat réindenté) ont la même forme canonique.
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	data, ok := v.(json.RawMessage)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	// Repasser par une map trie les clés ; UseNumber garde les nombres tels quels
+	var doc map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(doc)
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// CertifiedDiagnostic est la partie d'un diagnostic reprise dans un certificat :
+// le matériel et les résultats des tests, sans les informations internes
+// (auteur, station, horodatages de réception)
+type CertifiedDiagnostic struct {
+	ID         int64        `json:"id"`
+	RunID      string       `json:"run_id,omitempty"`
+	SystemInfo SystemInfo   `json:"system_info"`
+	CPU        CPUInfo      `json:"cpu"`
+	RAM        RAMInfo      `json:"ram"`
+	Storage    StorageInfo  `json:"storage"`
+	Battery    BatteryInfo  `json:"battery"`
+	Status     string       `json:"status"`
+	Duration   float64      `json:"duration"`
+	Tests      []TestResult `json:"tests"`
+	Timestamp  time.Time    `json:"timestamp"`
+	ChainHash  string       `json:"chain_hash,omitempty"` // hash du diagnostic dans la chaîne
+}
+
+// CertificatePayload est la partie signée d'un certificat
+type CertificatePayload struct {
+	Version    int                 `json:"version"`
+	Issuer     string              `json:"issuer"`
+	KeyID      string              `json:"key_id"`
+	IssuedAt   time.Time           `json:"issued_at"`
+	Diagnostic CertifiedDiagnostic `json:"diagnostic"`
+}
+
+// Certificate est un certificat de diagnostic vérifiable hors ligne :
+// Signature est la signature Ed25519 de la forme JSON canonique de Payload
+type Certificate struct {
+	Payload   CertificatePayload `json:"payload"`
+	Algorithm string             `json:"algorithm"`  // Ed25519
+	PublicKey string             `json:"public_key"` // base64
+	Signature string             `json:"signature"`  // base64
+}
+
+// PublicKeyResponse représente la clé publique de signature des certificats
+type PublicKeyResponse struct {
+	Success   bool   `json:"success"`
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"` // base64
+	PEM       string `json:"pem"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// ChainHead est le dernier maillon de la chaîne de hachage des diagnostics
+type ChainHead struct {
+	ID   int64  `json:"id"`
+	Hash string `json:"hash"`
+}
+
+// ChainBreak décrit le premier maillon invalide de la chaîne
+type ChainBreak struct {
+	ID       int64  `json:"id"`
+	Reason   string `json:"reason"`
+	Expected string `json:"expected,omitempty"`
+	Found    string `json:"found,omitempty"`
+}
+
+// ChainReport est le résultat d'une vérification complète de la chaîne
+type ChainReport struct {
+	Valid      bool        `json:"valid"`
+	Checked    int         `json:"checked"`    // diagnostics dont le contenu a été recalculé
+	Tombstones int         `json:"tombstones"` // maillons de diagnostics supprimés par l'API
+	Head       *ChainHead  `json:"head,omitempty"`
+	Break      *ChainBreak `json:"first_broken_link,omitempty"`
+	VerifiedAt time.Time   `json:"verified_at"`
+}
+
+// ChainVerifyResponse représente la réponse de GET /chain/verify
+type ChainVerifyResponse struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Chain   *ChainReport `json:"chain,omitempty"`
+}
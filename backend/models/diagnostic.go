@@ -19,6 +19,11 @@ type RAMInfo struct {
 	Used      string `json:"used"`
 	Available string `json:"available"`
 	Type      string `json:"type,omitempty"`
+
+	// Valeurs numériques canoniques (octets), dérivées des chaînes si absentes
+	TotalBytes     *int64 `json:"total_bytes,omitempty"`
+	UsedBytes      *int64 `json:"used_bytes,omitempty"`
+	AvailableBytes *int64 `json:"available_bytes,omitempty"`
 }
 
 // StorageInfo représente les informations du stockage
@@ -29,6 +34,11 @@ type StorageInfo struct {
 	Available  string `json:"available"`
 	Health     string `json:"health,omitempty"`
 	DeviceName string `json:"device_name,omitempty"`
+
+	// Valeurs numériques canoniques (octets), dérivées des chaînes si absentes
+	CapacityBytes  *int64 `json:"capacity_bytes,omitempty"`
+	UsedBytes      *int64 `json:"used_bytes,omitempty"`
+	AvailableBytes *int64 `json:"available_bytes,omitempty"`
 }
 
 // BatteryInfo représente les informations de la batterie
@@ -40,6 +50,10 @@ type BatteryInfo struct {
 	Condition    string `json:"condition,omitempty"`
 	IsCharging   bool   `json:"is_charging"`
 	PowerAdapter string `json:"power_adapter,omitempty"`
+
+	// Valeurs numériques canoniques (pourcentage), dérivées des chaînes si absentes
+	CapacityPercent    *float64 `json:"capacity_percent,omitempty"`
+	MaxCapacityPercent *float64 `json:"max_capacity_percent,omitempty"`
 }
 
 // SystemInfo représente les informations générales du système
@@ -53,27 +67,66 @@ type SystemInfo struct {
 
 // Diagnostic représente le diagnostic complet d'une machine
 type Diagnostic struct {
-	ID         int64       `json:"id"`
-	SystemInfo SystemInfo  `json:"system_info"`
-	CPU        CPUInfo     `json:"cpu"`
-	RAM        RAMInfo     `json:"ram"`
-	Storage    StorageInfo `json:"storage"`
-	Battery    BatteryInfo `json:"battery"`
-	Status     string      `json:"status"`   // success, partial, failed
-	Duration   float64     `json:"duration"` // en secondes
-	Timestamp  time.Time   `json:"timestamp"`
-	CreatedAt  time.Time   `json:"created_at"`
+	ID         int64        `json:"id"`
+	RunID      string       `json:"run_id,omitempty"` // UUID généré par le client
+	SystemInfo SystemInfo   `json:"system_info"`
+	CPU        CPUInfo      `json:"cpu"`
+	RAM        RAMInfo      `json:"ram"`
+	Storage    StorageInfo  `json:"storage"`
+	Battery    BatteryInfo  `json:"battery"`
+	Status     string       `json:"status"`   // success, partial, failed
+	Duration   float64      `json:"duration"` // en secondes
+	Tests      []TestResult `json:"tests"`
+	Timestamp  time.Time    `json:"timestamp"` // heure effective du diagnostic
+	CreatedAt  time.Time    `json:"created_at"`
+
+	CapturedAt       *time.Time `json:"captured_at,omitempty"`        // heure de capture annoncée par le client
+	ReceivedAt       time.Time  `json:"received_at"`                  // heure de réception par le serveur
+	ClockSkewSeconds *float64   `json:"clock_skew_seconds,omitempty"` // avance (<0) ou retard (>0) de l'horloge client
+	TimestampFlag    string     `json:"timestamp_flag,omitempty"`     // "future" si captured_at était dans le futur
+
+	SubmittedBy *int64 `json:"submitted_by,omitempty"` // utilisateur ayant envoyé le diagnostic
+
+	// Station ayant signé l'envoi (HMAC) ou présenté son certificat client
+	// (mTLS, sans signature ni signed_at), absente pour un envoi anonyme
+	StationID *int64     `json:"station_id,omitempty"`
+	Signature string     `json:"signature,omitempty"`
+	SignedAt  *time.Time `json:"signed_at,omitempty"`
+
+	// Chaîne de hachage (voir database/chain.go)
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
 }
 
 // DiagnosticRequest représente la requête pour créer un diagnostic
 type DiagnosticRequest struct {
-	SystemInfo SystemInfo  `json:"system_info"`
-	CPU        CPUInfo     `json:"cpu"`
-	RAM        RAMInfo     `json:"ram"`
-	Storage    StorageInfo `json:"storage"`
-	Battery    BatteryInfo `json:"battery"`
-	Status     string      `json:"status"`
-	Duration   float64     `json:"duration"`
+	RunID      string       `json:"run_id,omitempty"` // UUID généré par le client, rend l'envoi idempotent
+	SystemInfo SystemInfo   `json:"system_info"`
+	CPU        CPUInfo      `json:"cpu"`
+	RAM        RAMInfo      `json:"ram"`
+	Storage    StorageInfo  `json:"storage"`
+	Battery    BatteryInfo  `json:"battery"`
+	Status     string       `json:"status"` // déduit de Tests si absent
+	Duration   float64      `json:"duration"`
+	Tests      []TestResult `json:"tests,omitempty"`
+
+	// Horodatage côté client (optionnels, RFC 3339) : captured_at = fin du test,
+	// sent_at = moment de l'envoi, utilisé pour mesurer le décalage d'horloge
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
+	SentAt     *time.Time `json:"sent_at,omitempty"`
+
+	// Calculés par ApplyReceipt à la réception
+	ReceivedAt       time.Time `json:"-"`
+	Timestamp        time.Time `json:"-"`
+	ClockSkewSeconds *float64  `json:"-"`
+	TimestampFlag    string    `json:"-"`
+
+	// Utilisateur authentifié à l'origine de l'envoi (nil pour une clé de station)
+	SubmittedBy *int64 `json:"-"`
+	// Clé d'API de l'envoi (nil pour un envoi anonyme)
+	APIKeyID *int64 `json:"-"`
+	// Station authentifiée par signature ou certificat (nil pour un envoi anonyme)
+	Signature *StationSignature `json:"-"`
 }
 
 // DiagnosticResponse représente la réponse après création d'un diagnostic
@@ -83,15 +136,36 @@ type DiagnosticResponse struct {
 	ID      int64  `json:"id,omitempty"`
 }
 
+// BatchItemResult représente le résultat d'un élément d'un envoi en lot
+type BatchItemResult struct {
+	Index     int    `json:"index"` // position dans le lot (à partir de 0)
+	Success   bool   `json:"success"`
+	ID        int64  `json:"id,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"` // run_id déjà enregistré, ID existant
+	Error     string `json:"error,omitempty"`
+}
+
+// BatchResponse représente la réponse d'un envoi en lot
+type BatchResponse struct {
+	Success bool              `json:"success"`
+	Message string            `json:"message"`
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Results []BatchItemResult `json:"results"`
+}
+
 // DiagnosticsListResponse représente la liste des diagnostics
 type DiagnosticsListResponse struct {
 	Success     bool         `json:"success"`
 	Count       int          `json:"count"`
 	Diagnostics []Diagnostic `json:"diagnostics"`
+	NextCursor  string       `json:"next_cursor,omitempty"` // à passer en ?cursor= pour la page suivante
 }
 
 // SwiftDiagnosticRequest représente le format envoyé par l'application Swift
 type SwiftDiagnosticRequest struct {
+	RunID               string  `json:"run_id,omitempty"`
 	MachineName         string  `json:"machine_name"`
 	SerialNumber        string  `json:"serial_number"`
 	CPUModel            string  `json:"cpu_model"`
@@ -105,14 +179,20 @@ type SwiftDiagnosticRequest struct {
 	BatteryHealth       string  `json:"battery_health"`
 	TestDurationSeconds float64 `json:"test_duration_seconds"`
 	Status              string  `json:"status"`
+
+	Tests      []TestResult `json:"tests,omitempty"`
+	CapturedAt *time.Time   `json:"captured_at,omitempty"`
+	SentAt     *time.Time   `json:"sent_at,omitempty"`
 }
 
 // ToStandardRequest convertit le format Swift vers le format standard
 func (s *SwiftDiagnosticRequest) ToStandardRequest() DiagnosticRequest {
-	ramAvailable := s.RAMTotalGB - s.RAMUsedGB
-	storageAvailable := s.StorageTotalGB - s.StorageUsedGB
+	ramAvailable, ramAvailableBytes := availableGB(s.RAMTotalGB, s.RAMUsedGB)
+	storageAvailable, storageAvailableBytes := availableGB(s.StorageTotalGB, s.StorageUsedGB)
+	batteryPercent := float64(s.BatteryPercentage)
 
 	return DiagnosticRequest{
+		RunID: s.RunID,
 		SystemInfo: SystemInfo{
 			MachineName:  s.MachineName,
 			SerialNumber: s.SerialNumber,
@@ -127,22 +207,35 @@ func (s *SwiftDiagnosticRequest) ToStandardRequest() DiagnosticRequest {
 		RAM: RAMInfo{
 			Total:     formatGB(s.RAMTotalGB),
 			Used:      formatGB(s.RAMUsedGB),
-			Available: formatGB(ramAvailable),
+			Available: ramAvailable,
+
+			TotalBytes:     int64Ptr(GBToBytes(s.RAMTotalGB)),
+			UsedBytes:      int64Ptr(GBToBytes(s.RAMUsedGB)),
+			AvailableBytes: ramAvailableBytes,
 		},
 		Storage: StorageInfo{
 			Type:      "SSD",
 			Capacity:  formatGB(s.StorageTotalGB),
 			Used:      formatGB(s.StorageUsedGB),
-			Available: formatGB(storageAvailable),
+			Available: storageAvailable,
+
+			CapacityBytes:  int64Ptr(GBToBytes(s.StorageTotalGB)),
+			UsedBytes:      int64Ptr(GBToBytes(s.StorageUsedGB)),
+			AvailableBytes: storageAvailableBytes,
 		},
 		Battery: BatteryInfo{
 			CycleCount: s.BatteryCycleCount,
 			Health:     s.BatteryHealth,
 			Capacity:   formatPercent(s.BatteryPercentage),
 			IsCharging: false,
+
+			CapacityPercent: &batteryPercent,
 		},
-		Status:   mapStatus(s.Status),
-		Duration: s.TestDurationSeconds,
+		Status:     s.mappedStatus(),
+		Duration:   s.TestDurationSeconds,
+		Tests:      s.Tests,
+		CapturedAt: s.CapturedAt,
+		SentAt:     s.SentAt,
 	}
 }
 
@@ -151,11 +244,34 @@ func formatGB(gb float64) string {
 	return fmt.Sprintf("%.2f GB", gb)
 }
 
+// availableGB calcule l'espace disponible du format Swift (total - utilisé).
+// Un utilisé supérieur au total est une mesure incohérente : la valeur
+// disponible reste inconnue ("N/A", sans octets) plutôt que négative.
+func availableGB(totalGB, usedGB float64) (string, *int64) {
+	if usedGB > totalGB {
+		return "N/A", nil
+	}
+	return formatGB(totalGB - usedGB), int64Ptr(GBToBytes(totalGB - usedGB))
+}
+
+// int64Ptr retourne un pointeur vers v
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
 // formatPercent formate un nombre en pourcentage
 func formatPercent(percent int) string {
 	return fmt.Sprintf("%d%%", percent)
 }
 
+// mappedStatus convertit le statut Swift, ou le déduit des étapes s'il est absent
+func (s *SwiftDiagnosticRequest) mappedStatus() string {
+	if s.Status == "" && len(s.Tests) > 0 {
+		return StatusFromTests(s.Tests)
+	}
+	return mapStatus(s.Status)
+}
+
 // mapStatus convertit le statut Swift vers le format backend
 func mapStatus(swiftStatus string) string {
 	switch swiftStatus {
@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// exportColumn est une colonne de l'export à plat d'un diagnostic
+type exportColumn struct {
+	name  string
+	value func(d *Diagnostic) interface{}
+}
+
+// exportColumns définit l'export CSV / NDJSON. Les noms reprennent ceux des
+// colonnes SQL : ils sont stables, une nouvelle colonne s'ajoute à la fin.
+var exportColumns = []exportColumn{
+	{"id", func(d *Diagnostic) interface{} { return d.ID }},
+	{"run_id", func(d *Diagnostic) interface{} { return d.RunID }},
+	{"machine_name", func(d *Diagnostic) interface{} { return d.SystemInfo.MachineName }},
+	{"serial_number", func(d *Diagnostic) interface{} { return d.SystemInfo.SerialNumber }},
+	{"model", func(d *Diagnostic) interface{} { return d.SystemInfo.Model }},
+	{"os_version", func(d *Diagnostic) interface{} { return d.SystemInfo.OSVersion }},
+	{"macos_version", func(d *Diagnostic) interface{} { return d.SystemInfo.MacOSVersion }},
+	{"cpu_model", func(d *Diagnostic) interface{} { return d.CPU.Model }},
+	{"cpu_cores", func(d *Diagnostic) interface{} { return d.CPU.Cores }},
+	{"cpu_frequency", func(d *Diagnostic) interface{} { return d.CPU.Frequency }},
+	{"cpu_temperature", func(d *Diagnostic) interface{} { return d.CPU.Temperature }},
+	{"ram_total", func(d *Diagnostic) interface{} { return d.RAM.Total }},
+	{"ram_used", func(d *Diagnostic) interface{} { return d.RAM.Used }},
+	{"ram_available", func(d *Diagnostic) interface{} { return d.RAM.Available }},
+	{"ram_type", func(d *Diagnostic) interface{} { return d.RAM.Type }},
+	{"ram_total_bytes", func(d *Diagnostic) interface{} { return d.RAM.TotalBytes }},
+	{"ram_used_bytes", func(d *Diagnostic) interface{} { return d.RAM.UsedBytes }},
+	{"ram_available_bytes", func(d *Diagnostic) interface{} { return d.RAM.AvailableBytes }},
+	{"storage_type", func(d *Diagnostic) interface{} { return d.Storage.Type }},
+	{"storage_capacity", func(d *Diagnostic) interface{} { return d.Storage.Capacity }},
+	{"storage_used", func(d *Diagnostic) interface{} { return d.Storage.Used }},
+	{"storage_available", func(d *Diagnostic) interface{} { return d.Storage.Available }},
+	{"storage_health", func(d *Diagnostic) interface{} { return d.Storage.Health }},
+	{"storage_device_name", func(d *Diagnostic) interface{} { return d.Storage.DeviceName }},
+	{"storage_capacity_bytes", func(d *Diagnostic) interface{} { return d.Storage.CapacityBytes }},
+	{"storage_used_bytes", func(d *Diagnostic) interface{} { return d.Storage.UsedBytes }},
+	{"storage_available_bytes", func(d *Diagnostic) interface{} { return d.Storage.AvailableBytes }},
+	{"battery_cycle_count", func(d *Diagnostic) interface{} { return d.Battery.CycleCount }},
+	{"battery_health", func(d *Diagnostic) interface{} { return d.Battery.Health }},
+	{"battery_capacity", func(d *Diagnostic) interface{} { return d.Battery.Capacity }},
+	{"battery_max_capacity", func(d *Diagnostic) interface{} { return d.Battery.MaxCapacity }},
+	{"battery_condition", func(d *Diagnostic) interface{} { return d.Battery.Condition }},
+	{"battery_is_charging", func(d *Diagnostic) interface{} { return d.Battery.IsCharging }},
+	{"battery_power_adapter", func(d *Diagnostic) interface{} { return d.Battery.PowerAdapter }},
+	{"battery_capacity_percent", func(d *Diagnostic) interface{} { return d.Battery.CapacityPercent }},
+	{"battery_max_capacity_percent", func(d *Diagnostic) interface{} { return d.Battery.MaxCapacityPercent }},
+	{"status", func(d *Diagnostic) interface{} { return d.Status }},
+	{"duration", func(d *Diagnostic) interface{} { return d.Duration }},
+	{"timestamp", func(d *Diagnostic) interface{} { return d.Timestamp }},
+	{"captured_at", func(d *Diagnostic) interface{} { return d.CapturedAt }},
+	{"received_at", func(d *Diagnostic) interface{} { return d.ReceivedAt }},
+	{"created_at", func(d *Diagnostic) interface{} { return d.CreatedAt }},
+	{"clock_skew_seconds", func(d *Diagnostic) interface{} { return d.ClockSkewSeconds }},
+	{"timestamp_flag", func(d *Diagnostic) interface{} { return d.TimestampFlag }},
+	{"submitted_by", func(d *Diagnostic) interface{} { return d.SubmittedBy }},
+	{"station_id", func(d *Diagnostic) interface{} { return d.StationID }},
+	{"hash", func(d *Diagnostic) interface{} { return d.Hash }},
+}
+
+// ExportColumns retourne les noms des colonnes de l'export, dans l'ordre
+func ExportColumns() []string {
+	names := make([]string, len(exportColumns))
+	for i, c := range exportColumns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// ExportValues retourne les valeurs du diagnostic dans l'ordre de
+// ExportColumns : nil pour une valeur absente, dates en UTC
+func (d *Diagnostic) ExportValues() []interface{} {
+	values := make([]interface{}, len(exportColumns))
+	for i, c := range exportColumns {
+		values[i] = exportValue(c.value(d))
+	}
+	return values
+}
+
+// exportValue déréférence les pointeurs (nil si absent) et ramène les dates en UTC
+func exportValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *int64:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *float64:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.UTC()
+	case time.Time:
+		return v.UTC()
+	default:
+		return v
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+// Statuts d'une vérification de disponibilité
+const (
+	CheckStatusOK   = "ok"
+	CheckStatusFail = "fail"
+)
+
+// HealthCheckResult représente le résultat d'une vérification de /readyz
+type HealthCheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"` // ok, fail
+	LatencyMs float64 `json:"latency_ms"`
+	Message   string  `json:"message,omitempty"`
+}
+
+// ReadinessResponse représente la réponse de /readyz (et de /livez, sans vérifications)
+type ReadinessResponse struct {
+	Status string              `json:"status"` // ok, fail
+	Checks []HealthCheckResult `json:"checks,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+// ImportMapping décrit comment lire un CSV de diagnostics historiques
+// (fichier YAML, voir import_mapping.example.yaml)
+type ImportMapping struct {
+	// Columns associe un champ du diagnostic (ex: "cpu.cores") au nom de la colonne CSV
+	Columns map[string]string `yaml:"columns"`
+	// Defaults donne la valeur d'un champ absent du CSV ou vide sur une ligne
+	Defaults map[string]string `yaml:"defaults"`
+	// Delimiter est le séparateur de colonnes ("," par défaut, ";" pour un export Excel français)
+	Delimiter string `yaml:"delimiter"`
+	// DateFormat est le format Go de captured_at (RFC 3339 par défaut, ex: "02/01/2006 15:04")
+	DateFormat string `yaml:"date_format"`
+	// Timezone interprète les dates sans fuseau (UTC par défaut, ex: "Europe/Paris")
+	Timezone string `yaml:"timezone"`
+}
+
+// ImportLineError signale une ligne du CSV refusée
+type ImportLineError struct {
+	Line  int    `json:"line"` // numéro de ligne dans le fichier (en-tête = 1)
+	Error string `json:"error"`
+}
+
+// ImportReport représente le résultat d'un import CSV
+type ImportReport struct {
+	Success    bool              `json:"success"`
+	Message    string            `json:"message"`
+	DryRun     bool              `json:"dry_run"`
+	Total      int               `json:"total"`      // lignes de données lues
+	Valid      int               `json:"valid"`      // lignes valides (importées ou à importer)
+	Imported   int               `json:"imported"`   // diagnostics créés (0 en dry-run)
+	Duplicates int               `json:"duplicates"` // lignes déjà importées (même run_id)
+	Failed     int               `json:"failed"`
+	Errors     []ImportLineError `json:"errors"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Machine représente une machine du registre, identifiée par son numéro de série
+type Machine struct {
+	SerialNumber         string    `json:"serial_number"`
+	MachineName          string    `json:"machine_name"`
+	Model                string    `json:"model"`
+	OSVersion            string    `json:"os_version"`
+	MacOSVersion         string    `json:"macos_version,omitempty"`
+	CPUModel             string    `json:"cpu_model"`
+	CPUCores             int       `json:"cpu_cores"`
+	RAMTotal             string    `json:"ram_total"`
+	RAMTotalBytes        *int64    `json:"ram_total_bytes,omitempty"`
+	StorageType          string    `json:"storage_type"`
+	StorageCapacity      string    `json:"storage_capacity"`
+	StorageCapacityBytes *int64    `json:"storage_capacity_bytes,omitempty"`
+	FirstSeen            time.Time `json:"first_seen"`
+	LastSeen             time.Time `json:"last_seen"`
+	RunCount             int       `json:"run_count"`
+
+	LatestDiagnostic *DiagnosticSummary `json:"latest_diagnostic,omitempty"`
+}
+
+// DiagnosticSummary résume le dernier diagnostic d'une machine
+type DiagnosticSummary struct {
+	ID                        int64     `json:"id"`
+	Status                    string    `json:"status"`
+	Timestamp                 time.Time `json:"timestamp"`
+	Duration                  float64   `json:"duration"`
+	BatteryHealth             string    `json:"battery_health"`
+	BatteryCycleCount         int       `json:"battery_cycle_count"`
+	BatteryMaxCapacityPercent *float64  `json:"battery_max_capacity_percent,omitempty"`
+	FailedTests               []string  `json:"failed_tests,omitempty"`
+}
+
+// MachinesListResponse représente la liste des machines
+type MachinesListResponse struct {
+	Success    bool      `json:"success"`
+	Count      int       `json:"count"`
+	Machines   []Machine `json:"machines"`
+	NextCursor string    `json:"next_cursor,omitempty"` // à passer en ?cursor= pour la page suivante
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// En-têtes d'un envoi signé par une station
+const (
+	HeaderStationID          = "X-Station-ID"          // nom de la station
+	HeaderSignatureTimestamp = "X-Signature-Timestamp" // secondes Unix
+	HeaderSignature          = "X-Signature"           // "sha256=" + HMAC hexadécimal
+)
+
+// Station représente une station de diagnostic enregistrée (sans son secret)
+type Station struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// StationSignature décrit la station authentifiée d'un envoi : par sa
+// signature HMAC, ou par son certificat client (Signature vide)
+type StationSignature struct {
+	StationID int64
+	Signature string
+	SignedAt  time.Time
+}
+
+// StationRequest représente la création d'une station via l'API d'administration
+type StationRequest struct {
+	Name string `json:"name"`
+}
+
+// StationResponse représente une station créée.
+// Secret n'est retourné qu'à la création.
+type StationResponse struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message"`
+	Secret  string   `json:"secret,omitempty"`
+	Station *Station `json:"station,omitempty"`
+}
+
+// StationsListResponse représente la liste des stations
+type StationsListResponse struct {
+	Success  bool      `json:"success"`
+	Count    int       `json:"count"`
+	Stations []Station `json:"stations"`
+}
+
+// ParseStationName vérifie un nom de station, transmis tel quel dans X-Station-ID
+func ParseStationName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("name est requis")
+	}
+	if len(name) > 64 {
+		return "", errors.New("name ne doit pas dépasser 64 caractères")
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return "", fmt.Errorf("name contient un caractère non autorisé %q (lettres, chiffres, - _ .)", c)
+		}
+	}
+	return name, nil
+}
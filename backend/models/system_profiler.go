@@ -0,0 +1,320 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SystemProfilerReport représente la sortie brute de
+// "system_profiler -json SPHardwareDataType SPStorageDataType SPPowerDataType SPMemoryDataType"
+// (SPSoftwareDataType, s'il est ajouté, fournit le nom d'hôte et la version de macOS).
+// Les champs du run (run_id, status, tests, ...) peuvent être ajoutés au premier niveau.
+type SystemProfilerReport struct {
+	Hardware []spHardware `json:"SPHardwareDataType"`
+	Software []spSoftware `json:"SPSoftwareDataType"`
+	Memory   []spMemory   `json:"SPMemoryDataType"`
+	Storage  []spVolume   `json:"SPStorageDataType"`
+	Power    []spPower    `json:"SPPowerDataType"`
+
+	RunID      string       `json:"run_id,omitempty"`
+	Status     string       `json:"status,omitempty"` // déduit de l'état du matériel si absent
+	Duration   float64      `json:"duration,omitempty"`
+	Tests      []TestResult `json:"tests,omitempty"`
+	CapturedAt *time.Time   `json:"captured_at,omitempty"`
+	SentAt     *time.Time   `json:"sent_at,omitempty"`
+}
+
+// spHardware est la vue d'ensemble du matériel (SPHardwareDataType)
+type spHardware struct {
+	MachineName    string `json:"machine_name"`            // "MacBook Pro"
+	MachineModel   string `json:"machine_model"`           // identifiant de modèle, "MacBookPro18,3"
+	ChipType       string `json:"chip_type"`               // Apple Silicon : "Apple M1 Pro"
+	CPUType        string `json:"cpu_type"`                // Intel : "Quad-Core Intel Core i7"
+	ProcessorSpeed string `json:"current_processor_speed"` // Intel seulement
+	Processors     spInt  `json:"number_processors"`       // "proc 10:8:2" (Apple Silicon) ou nombre de puces (Intel)
+	TotalCores     spInt  `json:"total_number_of_cores"`   // Intel seulement
+	PhysicalMemory string `json:"physical_memory"`         // "16 GB"
+	SerialNumber   string `json:"serial_number"`
+}
+
+// spSoftware est la vue d'ensemble du système (SPSoftwareDataType)
+type spSoftware struct {
+	LocalHostName string `json:"local_host_name"`
+	OSVersion     string `json:"os_version"` // "macOS 14.4.1 (23E224)"
+}
+
+// spMemory décrit la mémoire (SPMemoryDataType) : directement sur Apple
+// Silicon, barrette par barrette (_items) sur Intel
+type spMemory struct {
+	Size  string     `json:"SPMemoryDataType"`
+	Type  string     `json:"dimm_type"`
+	Items []spMemory `json:"_items"`
+}
+
+// spVolume est un volume monté (SPStorageDataType)
+type spVolume struct {
+	Name       string `json:"_name"`
+	MountPoint string `json:"mount_point"`
+	Size       int64  `json:"size_in_bytes"`
+	Free       int64  `json:"free_space_in_bytes"`
+	Drive      struct {
+		DeviceName  string `json:"device_name"`
+		MediumType  string `json:"medium_type"`  // "ssd" ou "rotational"
+		SmartStatus string `json:"smart_status"` // "Verified", "Failing", ...
+		Internal    string `json:"is_internal_disk"`
+	} `json:"physical_drive"`
+}
+
+// spPower est une entrée de SPPowerDataType, repérée par _name
+// (batterie, réglages d'énergie, chargeur)
+type spPower struct {
+	Name   string `json:"_name"`
+	Charge struct {
+		IsCharging      string `json:"sppower_battery_is_charging"`
+		StateOfCharge   spInt  `json:"sppower_battery_state_of_charge"`  // en %, macOS récents
+		CurrentCapacity spInt  `json:"sppower_battery_current_capacity"` // en mAh, macOS anciens
+		MaxCapacity     spInt  `json:"sppower_battery_max_capacity"`
+	} `json:"sppower_battery_charge_info"`
+	Health struct {
+		CycleCount      spInt  `json:"sppower_battery_cycle_count"`
+		Condition       string `json:"sppower_battery_health"` // "Normal", "Service Recommended", ...
+		MaximumCapacity string `json:"sppower_battery_health_maximum_capacity"`
+	} `json:"sppower_battery_health_info"`
+
+	ChargerConnected string `json:"sppower_battery_charger_connected"`
+	ChargerName      string `json:"sppower_ac_charger_name"`
+	ChargerWatts     spInt  `json:"sppower_ac_charger_watts"`
+}
+
+// Entrées de SPPowerDataType utilisées
+const (
+	spPowerBattery = "spbattery_information"
+	spPowerCharger = "sppower_ac_charger_information"
+)
+
+// spInt est un entier que system_profiler écrit en nombre ou en texte
+// selon les versions ("proc 10:8:2" donne le premier nombre, 10)
+type spInt int
+
+// UnmarshalJSON accepte 12, "12" et "proc 10:8:2"
+func (n *spInt) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*n = spInt(v)
+	case string:
+		s := strings.TrimSpace(strings.TrimPrefix(v, "proc "))
+		if i := strings.IndexAny(s, ": "); i >= 0 {
+			s = s[:i]
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("entier attendu, lu %q", v)
+		}
+		*n = spInt(i)
+	}
+	return nil
+}
+
+// ToStandardRequest convertit la sortie de system_profiler vers le format standard
+func (r *SystemProfilerReport) ToStandardRequest() DiagnosticRequest {
+	var hw spHardware
+	if len(r.Hardware) > 0 {
+		hw = r.Hardware[0]
+	}
+
+	req := DiagnosticRequest{
+		RunID: r.RunID,
+		SystemInfo: SystemInfo{
+			MachineName:  hw.MachineName,
+			SerialNumber: hw.SerialNumber,
+			Model:        hw.MachineModel,
+			OSVersion:    "macOS",
+		},
+		CPU: CPUInfo{
+			Model:     hw.ChipType,
+			Cores:     int(hw.TotalCores),
+			Frequency: hw.ProcessorSpeed,
+		},
+		RAM: RAMInfo{
+			Total: hw.PhysicalMemory,
+			Type:  r.memoryType(),
+		},
+		Duration:   r.Duration,
+		Tests:      r.Tests,
+		CapturedAt: r.CapturedAt,
+		SentAt:     r.SentAt,
+	}
+
+	if len(r.Software) > 0 {
+		sw := r.Software[0]
+		if sw.LocalHostName != "" {
+			req.SystemInfo.MachineName = sw.LocalHostName
+		}
+		if sw.OSVersion != "" {
+			req.SystemInfo.OSVersion = sw.OSVersion
+			// "macOS 14.4.1 (23E224)" -> "14.4.1"
+			if fields := strings.Fields(sw.OSVersion); len(fields) >= 2 {
+				req.SystemInfo.MacOSVersion = fields[1]
+			}
+		}
+	}
+
+	// Intel : type et fréquence séparés, nombre de cœurs explicite ;
+	// Apple Silicon : puce et "proc total:performance:efficacité"
+	if req.CPU.Model == "" {
+		req.CPU.Model = hw.CPUType
+	}
+	if req.CPU.Cores == 0 {
+		req.CPU.Cores = int(hw.Processors)
+	}
+	if req.RAM.Total == "" {
+		for _, m := range r.Memory {
+			if m.Size != "" {
+				req.RAM.Total = m.Size
+				break
+			}
+		}
+	}
+
+	if v := r.systemVolume(); v != nil {
+		used := v.Size - v.Free
+		req.Storage = StorageInfo{
+			Type:       mediumType(v.Drive.MediumType),
+			Capacity:   formatGB(float64(v.Size) / (1 << 30)),
+			Used:       formatGB(float64(used) / (1 << 30)),
+			Available:  formatGB(float64(v.Free) / (1 << 30)),
+			Health:     v.Drive.SmartStatus,
+			DeviceName: v.Drive.DeviceName,
+
+			CapacityBytes:  int64Ptr(v.Size),
+			UsedBytes:      int64Ptr(used),
+			AvailableBytes: int64Ptr(v.Free),
+		}
+	}
+
+	r.fillBattery(&req.Battery)
+
+	req.Status = r.Status
+	if req.Status == "" {
+		if len(r.Tests) > 0 {
+			req.Status = StatusFromTests(r.Tests)
+		} else {
+			req.Status = hardwareStatus(req)
+		}
+	}
+
+	return req
+}
+
+// memoryType retourne le type de mémoire (LPDDR5, DDR4, ...), barrettes vides ignorées
+func (r *SystemProfilerReport) memoryType() string {
+	for _, m := range r.Memory {
+		if m.Type != "" && m.Type != "empty" {
+			return m.Type
+		}
+		for _, item := range m.Items {
+			if item.Type != "" && item.Type != "empty" {
+				return item.Type
+			}
+		}
+	}
+	return ""
+}
+
+// systemVolume choisit le volume de démarrage ("/"), à défaut le premier
+// volume d'un disque interne, à défaut le premier volume
+func (r *SystemProfilerReport) systemVolume() *spVolume {
+	if len(r.Storage) == 0 {
+		return nil
+	}
+	for i := range r.Storage {
+		if r.Storage[i].MountPoint == "/" {
+			return &r.Storage[i]
+		}
+	}
+	for i := range r.Storage {
+		if spBool(r.Storage[i].Drive.Internal) {
+			return &r.Storage[i]
+		}
+	}
+	return &r.Storage[0]
+}
+
+// mediumType traduit le type de support de system_profiler
+func mediumType(medium string) string {
+	switch strings.ToLower(medium) {
+	case "ssd":
+		return "SSD"
+	case "rotational":
+		return "HDD"
+	default:
+		return strings.ToUpper(medium)
+	}
+}
+
+// fillBattery complète la batterie et le chargeur ; un Mac de bureau n'a
+// pas d'entrée batterie et garde des valeurs vides
+func (r *SystemProfilerReport) fillBattery(b *BatteryInfo) {
+	for _, p := range r.Power {
+		switch p.Name {
+		case spPowerBattery:
+			b.CycleCount = int(p.Health.CycleCount)
+			// "Condition" dans Informations système ; reprise comme santé
+			// pour les clients qui ne lisent que battery.health
+			b.Condition = p.Health.Condition
+			b.Health = p.Health.Condition
+			b.MaxCapacity = p.Health.MaximumCapacity
+			b.IsCharging = spBool(p.Charge.IsCharging)
+
+			switch {
+			case p.Charge.StateOfCharge > 0:
+				b.Capacity = formatPercent(int(p.Charge.StateOfCharge))
+			case p.Charge.MaxCapacity > 0:
+				b.Capacity = formatPercent(int(p.Charge.CurrentCapacity * 100 / p.Charge.MaxCapacity))
+			}
+
+		case spPowerCharger:
+			if !spBool(p.ChargerConnected) {
+				continue
+			}
+			switch {
+			case p.ChargerName != "":
+				b.PowerAdapter = p.ChargerName
+			case p.ChargerWatts > 0:
+				b.PowerAdapter = fmt.Sprintf("%d W", p.ChargerWatts)
+			default:
+				b.PowerAdapter = "Connecté"
+			}
+		}
+	}
+}
+
+// hardwareStatus déduit le statut sans étapes de test : failed si le SSD
+// signale une panne SMART, partial si la batterie demande un entretien
+func hardwareStatus(req DiagnosticRequest) string {
+	if strings.EqualFold(req.Storage.Health, "Failing") {
+		return "failed"
+	}
+	switch strings.ToLower(req.Battery.Condition) {
+	case "", "good", "normal":
+		return "success"
+	default:
+		return "partial"
+	}
+}
+
+// spBool interprète les booléens de system_profiler ("TRUE", "yes", ...)
+func spBool(s string) bool {
+	switch strings.ToLower(s) {
+	case "true", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package models
+
+// Résultats possibles d'une étape de test
+const (
+	TestOutcomePassed  = "passed"
+	TestOutcomeWarning = "warning"
+	TestOutcomeFailed  = "failed"
+	TestOutcomeSkipped = "skipped"
+)
+
+// TestOutcomes liste les résultats acceptés
+var TestOutcomes = []string{TestOutcomePassed, TestOutcomeWarning, TestOutcomeFailed, TestOutcomeSkipped}
+
+// TestResult représente le résultat d'une étape du diagnostic (cpu, ram, storage, battery, ...)
+type TestResult struct {
+	Name            string                 `json:"name"`
+	Outcome         string                 `json:"outcome"` // passed, warning, failed, skipped
+	Measurements    map[string]interface{} `json:"measurements,omitempty"`
+	ErrorMessage    string                 `json:"error_message,omitempty"`
+	DurationSeconds float64                `json:"duration_seconds"`
+}
+
+// IsValidTestOutcome indique si outcome fait partie de TestOutcomes
+func IsValidTestOutcome(outcome string) bool {
+	for _, o := range TestOutcomes {
+		if o == outcome {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusFromTests déduit le statut global des étapes : failed si toutes les
+// étapes exécutées ont échoué, partial si au moins une a échoué, success sinon
+func StatusFromTests(tests []TestResult) string {
+	executed, failed := 0, 0
+	for _, t := range tests {
+		if t.Outcome == TestOutcomeSkipped {
+			continue
+		}
+		executed++
+		if t.Outcome == TestOutcomeFailed {
+			failed++
+		}
+	}
+
+	switch {
+	case executed > 0 && failed == executed:
+		return "failed"
+	case failed > 0:
+		return "partial"
+	default:
+		return "success"
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// FutureTolerance est l'avance tolérée sur l'heure du serveur avant de signaler un horodatage
+	FutureTolerance = 5 * time.Minute
+	// MaxFutureOffset est l'avance au-delà de laquelle un horodatage est rejeté
+	MaxFutureOffset = 24 * time.Hour
+)
+
+// Valeurs possibles de TimestampFlag
+const (
+	TimestampFlagFuture = "future" // horodatage client dans le futur, ramené à la réception
+)
+
+// minPlausibleCapture rejette les horloges non initialisées (1970, 2001, ...)
+var minPlausibleCapture = time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ApplyReceipt calcule l'horodatage effectif du diagnostic à partir de
+// l'heure de capture et de l'heure d'envoi du client, et de l'heure de
+// réception du serveur :
+//
+//   - sans captured_at, le diagnostic est daté de sa réception ;
+//   - avec sent_at, le décalage d'horloge du client (réception - envoi) est
+//     enregistré et sert à corriger captured_at, avant tout contrôle : une
+//     horloge très en avance ou non initialisée est ainsi compensée ;
+//   - un horodatage corrigé dans le futur est signalé et ramené à la
+//     réception, ou rejeté s'il dépasse MaxFutureOffset.
+func (d *DiagnosticRequest) ApplyReceipt(receivedAt time.Time) error {
+	d.ReceivedAt = receivedAt
+	d.Timestamp = receivedAt
+	d.ClockSkewSeconds = nil
+	d.TimestampFlag = ""
+
+	if d.SentAt != nil {
+		skew := receivedAt.Sub(*d.SentAt).Seconds()
+		skew = math.Round(skew*1000) / 1000
+		d.ClockSkewSeconds = &skew
+	}
+
+	if d.CapturedAt == nil {
+		return nil
+	}
+
+	// Corriger l'heure de capture avec le décalage d'horloge mesuré
+	captured := *d.CapturedAt
+	if d.ClockSkewSeconds != nil {
+		captured = captured.Add(time.Duration(*d.ClockSkewSeconds * float64(time.Second)))
+	}
+
+	if captured.Before(minPlausibleCapture) {
+		return fmt.Errorf("captured_at n'est pas plausible (%s)", d.CapturedAt.Format(time.RFC3339))
+	}
+	if captured.Sub(receivedAt) > MaxFutureOffset {
+		return fmt.Errorf("captured_at est trop loin dans le futur (%s)", d.CapturedAt.Format(time.RFC3339))
+	}
+
+	if captured.Sub(receivedAt) > FutureTolerance {
+		d.TimestampFlag = TimestampFlagFuture
+		return nil
+	}
+	if captured.After(receivedAt) {
+		captured = receivedAt
+	}
+
+	d.Timestamp = captured
+	return nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// TestApplyReceipt vérifie l'horodatage effectif : le décalage mesuré par
+// sent_at corrige captured_at avant les contrôles de plausibilité
+func TestApplyReceipt(t *testing.T) {
+	received := time.Date(2024, 4, 12, 9, 30, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		v := received.Add(d)
+		return &v
+	}
+	epoch := time.Unix(0, 0).UTC()
+
+	tests := []struct {
+		name     string
+		captured *time.Time
+		sent     *time.Time
+		want     time.Time
+		flag     string
+		wantErr  bool
+	}{
+		{"sans captured_at", nil, nil, received, "", false},
+		{"capture passée", at(-time.Hour), nil, received.Add(-time.Hour), "", false},
+		{"horloge en avance de 30 h, corrigée", at(29 * time.Hour), at(30 * time.Hour), received.Add(-time.Hour), "", false},
+		{"horloge non initialisée, corrigée", &epoch, &epoch, received, "", false},
+		{"avance tolérée, ramenée à la réception", at(2 * time.Minute), nil, received, "", false},
+		{"avance signalée", at(time.Hour), nil, received, TimestampFlagFuture, false},
+		{"avance de 30 h sans sent_at", at(30 * time.Hour), nil, time.Time{}, "", true},
+		{"horloge non initialisée sans sent_at", &epoch, nil, time.Time{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DiagnosticRequest{CapturedAt: tt.captured, SentAt: tt.sent}
+			err := d.ApplyReceipt(received)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("erreur %v, attendue %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !d.Timestamp.Equal(tt.want) || d.TimestampFlag != tt.flag {
+				t.Errorf("timestamp = %s, flag = %q ; attendu %s, %q", d.Timestamp, d.TimestampFlag, tt.want, tt.flag)
+			}
+		})
+	}
+}
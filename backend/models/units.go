@@ -0,0 +1,150 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Les capacités sont stockées en octets. Comme l'application Swift
+// (qui divise par 1 073 741 824), on considère qu'un "GB" vaut 1024³ octets :
+// KB/MB/GB/TB et KiB/MiB/GiB/TiB sont donc équivalents. Les unités
+// françaises (Ko, Mo, Go, To) sont aussi acceptées. L'unité est obligatoire :
+// "16" seul est ambigu (16 octets ou 16 GB ?) et reste sans valeur numérique.
+var byteUnits = map[string]float64{
+	"b":   1,
+	"o":   1,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"ko":  1 << 10,
+	"k":   1 << 10,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"mo":  1 << 20,
+	"m":   1 << 20,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+	"go":  1 << 30,
+	"g":   1 << 30,
+	"tb":  1 << 40,
+	"tib": 1 << 40,
+	"to":  1 << 40,
+	"t":   1 << 40,
+}
+
+// splitNumber sépare "512.5 GB" en (512.5, "gb")
+func splitNumber(s string) (float64, string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, "", fmt.Errorf("valeur vide")
+	}
+
+	// Trouver la fin de la partie numérique
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.' || s[end] == ',' || (end == 0 && s[end] == '-')) {
+		end++
+	}
+
+	// Accepter la virgule décimale française ("1,5 To")
+	num := strings.Replace(s[:end], ",", ".", 1)
+	value, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("nombre invalide dans %q", s)
+	}
+
+	unit := strings.ToLower(strings.TrimSpace(s[end:]))
+	return value, unit, nil
+}
+
+// ParseBytes convertit une capacité formatée ("512 GB", "1 TB", "16.00 GB")
+// en nombre d'octets
+func ParseBytes(s string) (int64, error) {
+	value, unit, err := splitNumber(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if unit == "" {
+		return 0, fmt.Errorf("unité manquante dans %q (ex: \"16 GB\")", s)
+	}
+	multiplier, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unité inconnue %q dans %q", unit, s)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("capacité négative: %q", s)
+	}
+
+	return int64(math.Round(value * multiplier)), nil
+}
+
+// ParsePercent convertit un pourcentage ("85%", "85 %", "85") en nombre
+func ParsePercent(s string) (float64, error) {
+	value, unit, err := splitNumber(s)
+	if err != nil {
+		return 0, err
+	}
+	if unit != "" && unit != "%" {
+		return 0, fmt.Errorf("pourcentage invalide: %q", s)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("pourcentage négatif: %q", s)
+	}
+	return value, nil
+}
+
+// GBToBytes convertit des GB (au sens de l'application Swift) en octets
+func GBToBytes(gb float64) int64 {
+	return int64(math.Round(gb * (1 << 30)))
+}
+
+// parseBytesPtr retourne nil si la valeur ne peut pas être interprétée
+func parseBytesPtr(s string) *int64 {
+	v, err := ParseBytes(s)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// parsePercentPtr retourne nil si la valeur ne peut pas être interprétée
+func parsePercentPtr(s string) *float64 {
+	v, err := ParsePercent(s)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// FillNumericValues complète les valeurs numériques à partir des chaînes
+// affichables quand le client ne les a pas fournies. Les chaînes
+// non interprétables ("N/A", ...) laissent la valeur numérique vide.
+func (d *DiagnosticRequest) FillNumericValues() {
+	if d.RAM.TotalBytes == nil {
+		d.RAM.TotalBytes = parseBytesPtr(d.RAM.Total)
+	}
+	if d.RAM.UsedBytes == nil {
+		d.RAM.UsedBytes = parseBytesPtr(d.RAM.Used)
+	}
+	if d.RAM.AvailableBytes == nil {
+		d.RAM.AvailableBytes = parseBytesPtr(d.RAM.Available)
+	}
+
+	if d.Storage.CapacityBytes == nil {
+		d.Storage.CapacityBytes = parseBytesPtr(d.Storage.Capacity)
+	}
+	if d.Storage.UsedBytes == nil {
+		d.Storage.UsedBytes = parseBytesPtr(d.Storage.Used)
+	}
+	if d.Storage.AvailableBytes == nil {
+		d.Storage.AvailableBytes = parseBytesPtr(d.Storage.Available)
+	}
+
+	if d.Battery.CapacityPercent == nil {
+		d.Battery.CapacityPercent = parsePercentPtr(d.Battery.Capacity)
+	}
+	if d.Battery.MaxCapacityPercent == nil {
+		d.Battery.MaxCapacityPercent = parsePercentPtr(d.Battery.MaxCapacity)
+	}
+}
@@ -0,0 +1,80 @@
+package models
+
+import "testing"
+
+// TestParseBytes vérifie la conversion des capacités affichables en octets
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"16 GB", 16 << 30, false},
+		{"16.00 GB", 16 << 30, false},
+		{"512GiB", 512 << 30, false},
+		{"1,5 To", 3 << 39, false},
+		{"256 Mo", 256 << 20, false},
+		{"1024 B", 1024, false},
+		{"16", 0, true}, // unité manquante : octets ou GB ?
+		{"  16  ", 0, true},
+		{"16 XB", 0, true},
+		{"-1 GB", 0, true},
+		{"N/A", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseBytes(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBytes(%q) : erreur %v, attendue %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBytes(%q) = %d, attendu %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSwiftAvailableBytes vérifie que l'espace disponible du format Swift
+// n'est jamais négatif
+func TestSwiftAvailableBytes(t *testing.T) {
+	tests := []struct {
+		name      string
+		totalGB   float64
+		usedGB    float64
+		available string
+		bytes     *int64
+	}{
+		{"cohérent", 512, 200, "312.00 GB", int64Ptr(312 << 30)},
+		{"plein", 512, 512, "0.00 GB", int64Ptr(0)},
+		{"utilisé supérieur au total", 16, 18.5, "N/A", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			swift := SwiftDiagnosticRequest{
+				RAMTotalGB: tt.totalGB, RAMUsedGB: tt.usedGB,
+				StorageTotalGB: tt.totalGB, StorageUsedGB: tt.usedGB,
+			}
+			req := swift.ToStandardRequest()
+
+			for _, got := range []struct {
+				field     string
+				available string
+				bytes     *int64
+			}{
+				{"ram", req.RAM.Available, req.RAM.AvailableBytes},
+				{"storage", req.Storage.Available, req.Storage.AvailableBytes},
+			} {
+				if got.available != tt.available {
+					t.Errorf("%s.available = %q, attendu %q", got.field, got.available, tt.available)
+				}
+				switch {
+				case tt.bytes == nil && got.bytes != nil:
+					t.Errorf("%s.available_bytes = %d, attendu nul", got.field, *got.bytes)
+				case tt.bytes != nil && (got.bytes == nil || *got.bytes != *tt.bytes):
+					t.Errorf("%s.available_bytes = %v, attendu %d", got.field, got.bytes, *tt.bytes)
+				}
+			}
+		})
+	}
+}
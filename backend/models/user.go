@@ -0,0 +1,107 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rôles des utilisateurs
+const (
+	RoleTechnician = "technician" // envoie des diagnostics et consulte les siens
+	RoleManager    = "manager"    // consulte tous les diagnostics et les statistiques
+	RoleAdmin      = "admin"      // supprime des diagnostics et gère clés et utilisateurs
+)
+
+// Roles liste les rôles acceptés
+var Roles = []string{RoleTechnician, RoleManager, RoleAdmin}
+
+// Permission est une action contrôlée par route
+type Permission string
+
+// Permissions vérifiées par le middleware des routes
+const (
+	PermSubmitDiagnostics  Permission = "diagnostics:submit"
+	PermReadOwnDiagnostics Permission = "diagnostics:read:own" // seulement les runs de l'utilisateur
+	PermReadDiagnostics    Permission = "diagnostics:read"     // tous les runs et le registre des machines
+	PermReadStatistics     Permission = "statistics:read"
+	PermDeleteDiagnostics  Permission = "diagnostics:delete"
+	PermImportDiagnostics  Permission = "diagnostics:import" // import CSV de diagnostics historiques
+	PermManageAccess       Permission = "access:manage"      // clés d'API et utilisateurs
+	PermVerifyChain        Permission = "chain:verify"       // vérification de la chaîne de hachage
+	PermReadMetrics        Permission = "metrics:read"       // métriques Prometheus (/metrics)
+)
+
+// RolePermissions associe à chaque rôle ses permissions
+var RolePermissions = map[string][]Permission{
+	RoleTechnician: {PermSubmitDiagnostics, PermReadOwnDiagnostics},
+	RoleManager: {PermSubmitDiagnostics, PermReadOwnDiagnostics, PermReadDiagnostics, PermReadStatistics,
+		PermVerifyChain, PermReadMetrics},
+	RoleAdmin: {PermSubmitDiagnostics, PermReadOwnDiagnostics, PermReadDiagnostics, PermReadStatistics,
+		PermVerifyChain, PermReadMetrics, PermDeleteDiagnostics, PermImportDiagnostics, PermManageAccess},
+}
+
+// ScopePermissions associe à chaque portée de clé d'API les permissions qu'elle autorise
+var ScopePermissions = map[string][]Permission{
+	ScopeIngest: {PermSubmitDiagnostics},
+	ScopeRead:   {PermReadOwnDiagnostics, PermReadDiagnostics, PermReadStatistics, PermVerifyChain, PermReadMetrics},
+	ScopeAdmin:  RolePermissions[RoleAdmin],
+}
+
+// AnonymousPermissions sont celles d'une requête sans clé quand
+// l'authentification est facultative (comportement historique de l'API)
+var AnonymousPermissions = []Permission{PermSubmitDiagnostics, PermReadDiagnostics, PermReadStatistics}
+
+// IsValidRole indique si le rôle est connu
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// ParseRole valide un rôle ("Manager" -> "manager")
+func ParseRole(role string) (string, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if !IsValidRole(role) {
+		return "", fmt.Errorf("rôle inconnu %q (valeurs possibles: %s)", role, strings.Join(Roles, ", "))
+	}
+	return role, nil
+}
+
+// User représente un utilisateur de l'API
+type User struct {
+	ID          int64      `json:"id"`
+	Username    string     `json:"username"`
+	DisplayName string     `json:"display_name,omitempty"`
+	Role        string     `json:"role"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DisabledAt  *time.Time `json:"disabled_at,omitempty"`
+}
+
+// UserRequest représente la création d'un utilisateur ou le changement de son rôle
+type UserRequest struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name,omitempty"`
+	Role        string `json:"role"`
+}
+
+// UserResponse représente la réponse après création ou modification d'un utilisateur
+type UserResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	User    *User  `json:"user,omitempty"`
+}
+
+// UsersListResponse représente la liste des utilisateurs
+type UsersListResponse struct {
+	Success bool   `json:"success"`
+	Count   int    `json:"count"`
+	Users   []User `json:"users"`
+}
+
+// ErrorResponse est le corps des refus d'accès (401, 403), identique sur toutes les routes
+type ErrorResponse struct {
+	Success    bool       `json:"success"`
+	Error      string     `json:"error"` // unauthorized, forbidden, invalid_signature, replayed_signature
+	Message    string     `json:"message"`
+	Permission Permission `json:"required_permission,omitempty"`
+}
@@ -0,0 +1,21 @@
+package report
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+//go:embed templates/report.html
+var templates embed.FS
+
+// htmlTemplate est compilé au démarrage : un modèle invalide fait échouer le lancement
+var htmlTemplate = template.Must(template.ParseFS(templates, "templates/report.html"))
+
+// WriteHTML écrit la fiche du diagnostic en HTML, prête à imprimer
+func WriteHTML(w io.Writer, d models.Diagnostic, now time.Time) error {
+	return htmlTemplate.Execute(w, NewSheet(d, now))
+}
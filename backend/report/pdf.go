@@ -0,0 +1,249 @@
+package report
+
+import (
+	"io"
+	"time"
+
+	"diagnostic-backend/models"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Mise en page A4 (mm)
+const (
+	pageWidth    = 210.0
+	pageHeight   = 297.0
+	pageMargin   = 15.0
+	contentWidth = pageWidth - 2*pageMargin
+	columnGap    = 6.0
+	columnWidth  = (contentWidth - columnGap) / 2
+	rowHeight    = 5.5
+	sectionHead  = 9.0
+	labelWidth   = 36.0
+)
+
+// badgeColors donne la couleur de fond (RVB) de chaque sorte de badge
+var badgeColors = map[string][3]int{
+	badgePass: {30, 142, 62},
+	badgeWarn: {227, 116, 0},
+	badgeFail: {217, 48, 37},
+	badgeSkip: {128, 134, 139},
+}
+
+// pdfWriter dessine une fiche avec fpdf ; les polices standard du PDF sont
+// en Windows-1252, d'où la traduction des textes (accents, œ, —)
+type pdfWriter struct {
+	pdf *fpdf.Fpdf
+	tr  func(string) string
+}
+
+// WritePDF écrit la fiche du diagnostic en PDF (A4)
+func WritePDF(w io.Writer, d models.Diagnostic, now time.Time) error {
+	sheet := NewSheet(d, now)
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(false, pageMargin)
+	pdf.SetTitle(sheet.Title, true)
+	pdf.SetCreator("diagnostic-backend", true)
+	pdf.SetCreationDate(now.UTC())
+	pdf.AddPage()
+
+	p := &pdfWriter{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+	p.header(sheet)
+	p.sections(sheet.Sections)
+	p.tests(sheet.Tests)
+	p.footer(sheet)
+
+	return pdf.Output(w)
+}
+
+// header dessine le titre, la machine, la date et le statut global
+func (p *pdfWriter) header(sheet Sheet) {
+	pdf := p.pdf
+
+	pdf.SetTextColor(29, 29, 31)
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(contentWidth, 9, p.tr(sheet.Title), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(contentWidth, 6, p.tr(sheet.Machine), "", 1, "L", false, 0, "")
+	p.muted(9)
+	pdf.CellFormat(contentWidth, 5, p.tr("Diagnostic du "+sheet.Date), "", 1, "L", false, 0, "")
+	y := pdf.GetY() + 2
+
+	// Statut global en haut à droite
+	pdf.SetFont("Helvetica", "B", 13)
+	width := pdf.GetStringWidth(p.tr(sheet.Status.Label)) + 10
+	p.badge(pageWidth-pageMargin-width, pageMargin+1, width, 9, sheet.Status)
+
+	pdf.SetDrawColor(29, 29, 31)
+	pdf.SetLineWidth(0.6)
+	pdf.Line(pageMargin, y, pageWidth-pageMargin, y)
+	pdf.SetY(y + 5)
+}
+
+// sections dessine les sections par paires, sur deux colonnes de même hauteur
+func (p *pdfWriter) sections(sections []Section) {
+	pdf := p.pdf
+
+	for i := 0; i < len(sections); i += 2 {
+		pair := sections[i:min(i+2, len(sections))]
+
+		rows := 0
+		for _, s := range pair {
+			rows = max(rows, len(s.Rows))
+		}
+		height := sectionHead + float64(rows)*rowHeight + 3
+		y := p.ensureSpace(height)
+
+		for col, s := range pair {
+			p.section(pageMargin+float64(col)*(columnWidth+columnGap), y, height, s)
+		}
+		pdf.SetY(y + height + 4)
+	}
+}
+
+// section dessine le cadre d'une section, son titre, son badge et ses lignes
+func (p *pdfWriter) section(x, y, height float64, s Section) {
+	pdf := p.pdf
+
+	pdf.SetDrawColor(210, 210, 215)
+	pdf.SetLineWidth(0.3)
+	pdf.RoundedRect(x, y, columnWidth, height, 2, "1234", "D")
+
+	pdf.SetTextColor(29, 29, 31)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.SetXY(x+3, y+2)
+	pdf.CellFormat(columnWidth-6, 6, p.tr(s.Title), "", 0, "L", false, 0, "")
+	if s.Badge != nil {
+		pdf.SetFont("Helvetica", "B", 8)
+		width := pdf.GetStringWidth(p.tr(s.Badge.Label)) + 6
+		p.badge(x+columnWidth-3-width, y+2.5, width, 5, *s.Badge)
+	}
+
+	for i, row := range s.Rows {
+		rowY := y + sectionHead + float64(i)*rowHeight
+		pdf.SetXY(x+3, rowY)
+		p.muted(9)
+		pdf.CellFormat(labelWidth, rowHeight, p.tr(row.Label), "", 0, "L", false, 0, "")
+		pdf.SetTextColor(29, 29, 31)
+		pdf.SetFont("Helvetica", "", 10)
+		valueWidth := columnWidth - 6 - labelWidth
+		pdf.CellFormat(valueWidth, rowHeight, p.fit(row.Value, valueWidth), "", 0, "L", false, 0, "")
+	}
+}
+
+// tests dessine le tableau des étapes de test
+func (p *pdfWriter) tests(tests []TestLine) {
+	if len(tests) == 0 {
+		return
+	}
+	pdf := p.pdf
+	widths := []float64{45, 32, 20, contentWidth - 97}
+
+	y := p.ensureSpace(2*rowHeight + 2)
+	pdf.SetXY(pageMargin, y)
+	p.muted(9)
+	for i, title := range []string{"Étape", "Résultat", "Durée", "Détail"} {
+		pdf.CellFormat(widths[i], rowHeight, p.tr(title), "B", 0, "L", false, 0, "")
+	}
+	pdf.Ln(rowHeight + 1)
+
+	for _, t := range tests {
+		y := p.ensureSpace(rowHeight + 1)
+		pdf.SetXY(pageMargin, y)
+
+		pdf.SetTextColor(29, 29, 31)
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(widths[0], rowHeight, p.fit(t.Name, widths[0]-2), "", 0, "L", false, 0, "")
+
+		pdf.SetFont("Helvetica", "B", 8)
+		width := pdf.GetStringWidth(p.tr(t.Badge.Label)) + 6
+		p.badge(pageMargin+widths[0], y+0.5, width, rowHeight-1, t.Badge)
+
+		pdf.SetXY(pageMargin+widths[0]+widths[1], y)
+		pdf.SetTextColor(29, 29, 31)
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(widths[2], rowHeight, p.tr(t.Duration), "", 0, "L", false, 0, "")
+		pdf.SetTextColor(217, 48, 37)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(widths[3], rowHeight, p.fit(t.Error, widths[3]), "", 0, "L", false, 0, "")
+
+		pdf.Ln(rowHeight + 1)
+	}
+	pdf.Ln(3)
+}
+
+// footer dessine les références du run, l'empreinte et la date de génération
+func (p *pdfWriter) footer(sheet Sheet) {
+	pdf := p.pdf
+
+	lines := len(sheet.Reference) + 2
+	if sheet.Hash != "" {
+		lines++
+	}
+	y := p.ensureSpace(float64(lines)*4.5 + 4)
+
+	pdf.SetDrawColor(210, 210, 215)
+	pdf.SetLineWidth(0.3)
+	pdf.Line(pageMargin, y, pageWidth-pageMargin, y)
+	pdf.SetXY(pageMargin, y+2)
+
+	for _, row := range sheet.Reference {
+		p.muted(8)
+		pdf.CellFormat(40, 4.5, p.tr(row.Label), "", 0, "L", false, 0, "")
+		pdf.CellFormat(contentWidth-40, 4.5, p.tr(row.Value), "", 1, "L", false, 0, "")
+	}
+	if sheet.Hash != "" {
+		p.muted(8)
+		pdf.CellFormat(40, 4.5, "Empreinte", "", 0, "L", false, 0, "")
+		pdf.SetFont("Courier", "", 7)
+		pdf.CellFormat(contentWidth-40, 4.5, sheet.Hash, "", 1, "L", false, 0, "")
+	}
+	p.muted(8)
+	pdf.CellFormat(contentWidth, 4.5, p.tr("Fiche générée le "+sheet.GeneratedAt), "", 1, "L", false, 0, "")
+}
+
+// badge dessine une pastille colorée avec son libellé centré en blanc
+// (la police doit déjà être choisie)
+func (p *pdfWriter) badge(x, y, width, height float64, b Badge) {
+	pdf := p.pdf
+
+	color, ok := badgeColors[b.Kind]
+	if !ok {
+		color = badgeColors[badgeSkip]
+	}
+	pdf.SetFillColor(color[0], color[1], color[2])
+	pdf.RoundedRect(x, y, width, height, height/2, "1234", "F")
+
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetXY(x, y)
+	pdf.CellFormat(width, height, p.tr(b.Label), "", 0, "C", false, 0, "")
+}
+
+// muted choisit le texte secondaire (gris)
+func (p *pdfWriter) muted(size float64) {
+	p.pdf.SetTextColor(110, 110, 115)
+	p.pdf.SetFont("Helvetica", "", size)
+}
+
+// fit traduit le texte et le tronque (…) pour qu'il tienne dans width
+func (p *pdfWriter) fit(text string, width float64) string {
+	s := p.tr(text)
+	if p.pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	ellipsis := p.tr("…")
+	for len(s) > 0 && p.pdf.GetStringWidth(s+ellipsis) > width {
+		s = s[:len(s)-1]
+	}
+	return s + ellipsis
+}
+
+// ensureSpace passe à la page suivante si height ne tient plus et retourne l'ordonnée courante
+func (p *pdfWriter) ensureSpace(height float64) float64 {
+	if p.pdf.GetY()+height > pageHeight-pageMargin {
+		p.pdf.AddPage()
+	}
+	return p.pdf.GetY()
+}
@@ -0,0 +1,228 @@
+// Package report produit la fiche imprimable d'un diagnostic, à coller sur
+// la machine ou à joindre à une annonce : en HTML (modèle embarqué dans le
+// binaire) ou en PDF (généré en Go, sans dépendance système).
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+// Sortes de badge, utilisées comme classes CSS et pour les couleurs du PDF
+const (
+	badgePass = "pass"
+	badgeWarn = "warn"
+	badgeFail = "fail"
+	badgeSkip = "skip"
+)
+
+// Badge est une pastille de résultat (réussi, avertissement, échoué, ignoré)
+type Badge struct {
+	Label string
+	Kind  string
+}
+
+// Row est une ligne "libellé : valeur" d'une section
+type Row struct {
+	Label string
+	Value string
+}
+
+// Section regroupe les informations d'un composant et le résultat de son test
+type Section struct {
+	Title string
+	Badge *Badge // nil si aucune étape de test ne porte sur le composant
+	Rows  []Row
+}
+
+// TestLine est une étape de test de la fiche
+type TestLine struct {
+	Name     string
+	Badge    Badge
+	Duration string
+	Error    string
+}
+
+// Sheet est le contenu de la fiche, commun au HTML et au PDF
+type Sheet struct {
+	ID          int64
+	Title       string
+	Machine     string
+	Date        string
+	Status      Badge
+	Sections    []Section
+	Tests       []TestLine
+	Reference   []Row  // identifiants permettant de retrouver le run
+	Hash        string // empreinte de la chaîne de hachage, reprise par le certificat
+	GeneratedAt string
+}
+
+// dateLayout est le format des dates affichées (toujours en UTC)
+const dateLayout = "02/01/2006 15:04 MST"
+
+// NewSheet prépare la fiche d'un diagnostic
+func NewSheet(d models.Diagnostic, now time.Time) Sheet {
+	outcomes := make(map[string]string, len(d.Tests))
+	for _, t := range d.Tests {
+		outcomes[strings.ToLower(t.Name)] = t.Outcome
+	}
+	sectionBadge := func(test string) *Badge {
+		outcome, ok := outcomes[test]
+		if !ok {
+			return nil
+		}
+		b := outcomeBadge(outcome)
+		return &b
+	}
+
+	sheet := Sheet{
+		ID:          d.ID,
+		Title:       fmt.Sprintf("Fiche de diagnostic n°%d", d.ID),
+		Machine:     joinNonEmpty(" — ", d.SystemInfo.Model, d.SystemInfo.SerialNumber),
+		Date:        d.Timestamp.UTC().Format(dateLayout),
+		Status:      statusBadge(d.Status),
+		GeneratedAt: now.UTC().Format(dateLayout),
+	}
+
+	sheet.Sections = []Section{
+		{
+			Title: "Système",
+			Rows: rows(
+				"Nom", d.SystemInfo.MachineName,
+				"Numéro de série", d.SystemInfo.SerialNumber,
+				"Modèle", d.SystemInfo.Model,
+				"Système", d.SystemInfo.OSVersion,
+				"Version macOS", d.SystemInfo.MacOSVersion,
+			),
+		},
+		{
+			Title: "Processeur",
+			Badge: sectionBadge("cpu"),
+			Rows: rows(
+				"Modèle", d.CPU.Model,
+				"Cœurs", strconv.Itoa(d.CPU.Cores),
+				"Fréquence", d.CPU.Frequency,
+				"Température", d.CPU.Temperature,
+			),
+		},
+		{
+			Title: "Mémoire",
+			Badge: sectionBadge("ram"),
+			Rows: rows(
+				"Totale", d.RAM.Total,
+				"Utilisée", d.RAM.Used,
+				"Disponible", d.RAM.Available,
+				"Type", d.RAM.Type,
+			),
+		},
+		{
+			Title: "Stockage",
+			Badge: sectionBadge("storage"),
+			Rows: rows(
+				"Type", d.Storage.Type,
+				"Capacité", d.Storage.Capacity,
+				"Utilisé", d.Storage.Used,
+				"Disponible", d.Storage.Available,
+				"Santé", d.Storage.Health,
+				"Disque", d.Storage.DeviceName,
+			),
+		},
+		{
+			Title: "Batterie",
+			Badge: sectionBadge("battery"),
+			Rows: rows(
+				"Cycles", strconv.Itoa(d.Battery.CycleCount),
+				"Santé", d.Battery.Health,
+				"Capacité", d.Battery.Capacity,
+				"Capacité maximale", d.Battery.MaxCapacity,
+				"État", d.Battery.Condition,
+				"En charge", yesNo(d.Battery.IsCharging),
+				"Adaptateur", d.Battery.PowerAdapter,
+			),
+		},
+	}
+
+	for _, t := range d.Tests {
+		sheet.Tests = append(sheet.Tests, TestLine{
+			Name:     t.Name,
+			Badge:    outcomeBadge(t.Outcome),
+			Duration: formatSeconds(t.DurationSeconds),
+			Error:    t.ErrorMessage,
+		})
+	}
+
+	sheet.Reference = rows(
+		"Durée du diagnostic", formatSeconds(d.Duration),
+		"Run", d.RunID,
+	)
+	sheet.Hash = d.Hash
+
+	return sheet
+}
+
+// rows construit les lignes à partir de paires libellé / valeur, en omettant les valeurs vides
+func rows(pairs ...string) []Row {
+	var out []Row
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if value := strings.TrimSpace(pairs[i+1]); value != "" {
+			out = append(out, Row{Label: pairs[i], Value: value})
+		}
+	}
+	return out
+}
+
+// statusBadge traduit le statut global du diagnostic
+func statusBadge(status string) Badge {
+	switch status {
+	case "success":
+		return Badge{Label: "Réussi", Kind: badgePass}
+	case "partial":
+		return Badge{Label: "Partiel", Kind: badgeWarn}
+	case "failed":
+		return Badge{Label: "Échoué", Kind: badgeFail}
+	default:
+		return Badge{Label: status, Kind: badgeSkip}
+	}
+}
+
+// outcomeBadge traduit le résultat d'une étape de test
+func outcomeBadge(outcome string) Badge {
+	switch outcome {
+	case models.TestOutcomePassed:
+		return Badge{Label: "Réussi", Kind: badgePass}
+	case models.TestOutcomeWarning:
+		return Badge{Label: "Avertissement", Kind: badgeWarn}
+	case models.TestOutcomeFailed:
+		return Badge{Label: "Échoué", Kind: badgeFail}
+	default:
+		return Badge{Label: "Ignoré", Kind: badgeSkip}
+	}
+}
+
+// joinNonEmpty joint les valeurs non vides
+func joinNonEmpty(sep string, values ...string) string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return strings.Join(out, sep)
+}
+
+// yesNo affiche un booléen en toutes lettres
+func yesNo(b bool) string {
+	if b {
+		return "Oui"
+	}
+	return "Non"
+}
+
+// formatSeconds affiche une durée en secondes avec la virgule décimale
+func formatSeconds(s float64) string {
+	return strings.Replace(strconv.FormatFloat(s, 'f', 1, 64), ".", ",", 1) + " s"
+}
@@ -0,0 +1,67 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"diagnostic-backend/models"
+)
+
+// testDiagnostic retourne un diagnostic complet, avec une étape échouée
+// dont le message doit être échappé en HTML
+func testDiagnostic() models.Diagnostic {
+	at := time.Date(2024, 4, 12, 9, 30, 0, 0, time.UTC)
+	return models.Diagnostic{
+		ID: 42,
+		SystemInfo: models.SystemInfo{
+			MachineName:  "MacBook Pro de l'atelier",
+			SerialNumber: "C02REPORT",
+			Model:        "MacBookPro18,3",
+			OSVersion:    "macOS 14.4.1",
+		},
+		CPU:     models.CPUInfo{Model: "Apple M1 Pro", Cores: 8},
+		RAM:     models.RAMInfo{Total: "16 GB", Used: "8 GB", Available: "8 GB"},
+		Storage: models.StorageInfo{Type: "SSD", Capacity: "512 GB", Used: "200 GB", Available: "312 GB"},
+		Battery: models.BatteryInfo{CycleCount: 120, Health: "Normal", MaxCapacity: "90%"},
+		Status:  "partial",
+		Tests: []models.TestResult{
+			{Name: "cpu", Outcome: "passed", DurationSeconds: 1.5},
+			{Name: "battery", Outcome: "failed", ErrorMessage: "<capacité> insuffisante"},
+		},
+		Timestamp:  at,
+		CreatedAt:  at,
+		ReceivedAt: at,
+	}
+}
+
+// TestWriteHTML vérifie que la fiche HTML reprend la machine et les étapes,
+// et échappe les valeurs envoyées par le client
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, testDiagnostic(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+
+	for _, want := range []string{"C02REPORT", "MacBookPro18,3", "battery", "&lt;capacité&gt; insuffisante"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("fiche HTML sans %q", want)
+		}
+	}
+	if strings.Contains(html, "<capacité>") {
+		t.Error("message d'erreur non échappé")
+	}
+}
+
+// TestWritePDF vérifie que la fiche PDF est produite
+func TestWritePDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, testDiagnostic(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() < 1000 || !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Errorf("PDF de %d octets, début %q", buf.Len(), buf.Bytes()[:min(buf.Len(), 8)])
+	}
+}
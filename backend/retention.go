@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"diagnostic-backend/config"
+	"diagnostic-backend/database"
+)
+
+// runRetention purge les données plus anciennes que la rétention configurée,
+// au démarrage puis à chaque intervalle, jusqu'à l'annulation de ctx
+func runRetention(ctx context.Context, store *database.SQLiteStore, retention config.RetentionConfig) {
+	if !retention.Enabled() {
+		return
+	}
+
+	slog.Info("Purge périodique activée",
+		"diagnostics", retention.Diagnostics.String(),
+		"idempotency_keys", retention.IdempotencyKeys.String(),
+		"interval", retention.Interval.String())
+
+	ticker := time.NewTicker(retention.Interval.D())
+	defer ticker.Stop()
+
+	for {
+		purgeOnce(ctx, store, retention)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeOnce calcule les dates limites et lance une purge
+func purgeOnce(ctx context.Context, store *database.SQLiteStore, retention config.RetentionConfig) {
+	now := time.Now()
+
+	var diagnosticsBefore, keysBefore time.Time
+	if retention.Diagnostics > 0 {
+		diagnosticsBefore = now.Add(-retention.Diagnostics.D())
+	}
+	if retention.IdempotencyKeys > 0 {
+		keysBefore = now.Add(-retention.IdempotencyKeys.D())
+	}
+
+	result, err := store.Purge(ctx, diagnosticsBefore, keysBefore)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Purge des anciennes données échouée", "error", err)
+		}
+		return
+	}
+
+	if result.Diagnostics > 0 || result.IdempotencyKeys > 0 {
+		slog.Info("Anciennes données purgées",
+			"diagnostics", result.Diagnostics, "idempotency_keys", result.IdempotencyKeys)
+	}
+}
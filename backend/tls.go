@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"diagnostic-backend/config"
+	"diagnostic-backend/metrics"
+)
+
+// clientAuthTypes associe tls.client_auth à la vérification des certificats
+// clients. "require" ne l'exige pas à la poignée de main TLS, qui couvrirait
+// tout l'écouteur (/livez, /readyz, /metrics, consultation) : le certificat
+// est exigé par les routes d'envoi (handlers.Options.ClientCertRequired).
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":     tls.NoClientCert,
+	"optional": tls.VerifyClientCertIfGiven,
+	"require":  tls.VerifyClientCertIfGiven,
+}
+
+// certReloader sert le certificat du serveur et la CA des stations lus sur
+// disque, rechargés à chaud quand leurs fichiers changent
+type certReloader struct {
+	cfg config.TLSConfig
+
+	mu        sync.RWMutex
+	cert      tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+// newCertReloader charge les certificats ; une erreur empêche le démarrage
+func newCertReloader(cfg config.TLSConfig) (*certReloader, error) {
+	r := &certReloader{cfg: cfg}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// files retourne les fichiers surveillés
+func (r *certReloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+// reload relit les fichiers ; en cas d'erreur, les certificats en service sont conservés
+func (r *certReloader) reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	cert.Leaf = leaf
+
+	var clientCAs *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		data, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("%s: aucun certificat PEM trouvé", r.cfg.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCAs, r.modTimes = cert, clientCAs, modTimes
+	r.mu.Unlock()
+
+	metrics.TLSCertificateNotAfter.Set(float64(leaf.NotAfter.Unix()))
+	return nil
+}
+
+// changed indique si un fichier surveillé a été modifié depuis le dernier chargement
+func (r *certReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		// Fichier en cours de remplacement : réessayer à l'intervalle suivant
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch recharge les certificats modifiés sur disque, jusqu'à l'annulation de ctx
+func (r *certReloader) watch(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.ReloadInterval.D())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !r.changed() {
+			continue
+		}
+		if err := r.reload(); err != nil {
+			metrics.TLSReloads.Inc("error")
+			slog.Error("Rechargement des certificats TLS échoué, certificats précédents conservés", "error", err)
+			continue
+		}
+		metrics.TLSReloads.Inc("success")
+
+		r.mu.RLock()
+		notAfter := r.cert.Leaf.NotAfter
+		r.mu.RUnlock()
+		slog.Info("Certificats TLS rechargés", "cert_file", r.cfg.CertFile, "not_after", notAfter)
+	}
+}
+
+// tlsConfig retourne la configuration TLS du serveur : chaque nouvelle
+// connexion utilise les derniers certificats chargés
+func (r *certReloader) tlsConfig() *tls.Config {
+	clientAuth := clientAuthTypes[r.cfg.ClientAuth]
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				NextProtos:   []string{"h2", "http/1.1"},
+				Certificates: []tls.Certificate{r.cert},
+				ClientAuth:   clientAuth,
+				ClientCAs:    r.clientCAs,
+			}, nil
+		},
+	}
+}